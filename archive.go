@@ -0,0 +1,524 @@
+package fileutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	S "strings"
+)
+
+// ArchiveKind identifies a supported archive format.
+type ArchiveKind string
+
+const (
+	ArchiveNone  ArchiveKind = ""
+	ArchiveZip   ArchiveKind = "zip"
+	ArchiveTar   ArchiveKind = "tar"
+	ArchiveTarGz ArchiveKind = "tar.gz"
+)
+
+// ErrNotAnArchive is wrapped (in a [fs.PathError]) when
+// a file is not recognised as a supported archive.
+var ErrNotAnArchive = errors.New("not a zip or tar archive")
+
+// ArchiveLimits bound how much of a tar is read into memory when
+// it is opened (a zip is read on demand, and its entries are then
+// subject to [ContentLimits]), so that a "tar bomb" (or a "gzip
+// bomb") fails with [ErrTooLarge] rather than exhausting memory.
+// Sizes are after decompression. Zero (or less) means no limit.
+type ArchiveLimits struct {
+	// MaxEntrySize is the largest entry that may be read.
+	MaxEntrySize int64
+	// MaxTotalSize is for all of the entries together.
+	MaxTotalSize int64
+}
+
+// DefaultArchiveLimits is what [OpenArchive], [OpenAsArchive]
+// and [NewArchiveFromReaderAt] use. The entry limit is the same
+// as for [DefaultContentLimits].
+var DefaultArchiveLimits = ArchiveLimits{
+	MaxEntrySize: MAX_FILE_SIZE, MaxTotalSize: 10 * MAX_FILE_SIZE}
+
+// ArchiveKindOf identifies an archive by its file extension.
+// Note that zip-based formats like EPUB and OOXML (.docx etc.)
+// are not included here, but they can be opened explicitly
+// with [NewArchiveFromReaderAt].
+func ArchiveKindOf(name string) ArchiveKind {
+	var s = S.ToLower(name)
+	switch {
+	case S.HasSuffix(s, ".zip"):
+		return ArchiveZip
+	case S.HasSuffix(s, ".tar"):
+		return ArchiveTar
+	case S.HasSuffix(s, ".tar.gz"), S.HasSuffix(s, ".tgz"):
+		return ArchiveTarGz
+	}
+	return ArchiveNone
+}
+
+// sniffArchiveKind identifies an archive by its magic number,
+// for when the extension is missing or misleading. It needs
+// at least 262 bytes to recognise an (uncompressed) tar.
+func sniffArchiveKind(head []byte) ArchiveKind {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")),
+		bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return ArchiveZip
+	case bytes.HasPrefix(head, []byte("\x1f\x8b")):
+		return ArchiveTarGz
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return ArchiveTar
+	}
+	return ArchiveNone
+}
+
+// Archive is a zip or tar(.gz) file that is browsable as an
+// [fs.FS], so that its entries can be turned into FSObjects
+// with [NewFSObjectFromFS] (or [Archive.NewFSObject]), listed
+// with [ReadDirFS], and gathered with [GatherDirTreeListFS].
+// Paths of entries are relative to the root of the archive.
+//
+// A zip is read on demand, but a tar is read into memory when
+// it is opened, because a (compressed) tar cannot be accessed
+// randomly (see [ArchiveLimits]). Close the Archive when done
+// with it.
+// .
+type Archive struct {
+	fs.FS
+	Kind ArchiveKind
+	// Name is the archive's own path, for use in messages.
+	Name   string
+	closer io.Closer
+}
+
+// OpenArchive opens an archive on the host file system,
+// with the [DefaultArchiveLimits].
+func OpenArchive(aPath string) (*Archive, error) {
+	return OpenArchiveLimited(aPath, DefaultArchiveLimits)
+}
+
+// OpenArchiveLimited is [OpenArchive] with
+// caller-supplied [ArchiveLimits].
+func OpenArchiveLimited(aPath string, lim ArchiveLimits) (*Archive, error) {
+	var kind = ArchiveKindOf(aPath)
+	pF, e := os.Open(aPath)
+	if e != nil {
+		return nil, &fs.PathError{Op: "fu.openarchive:os.open", Path: aPath, Err: e}
+	}
+	fi, e := pF.Stat()
+	if e != nil {
+		pF.Close()
+		return nil, &fs.PathError{Op: "fu.openarchive:stat", Path: aPath, Err: e}
+	}
+	pA, e := newArchive(pF, fi.Size(), kind, aPath, lim)
+	if e != nil {
+		pF.Close()
+		return nil, e
+	}
+	pA.closer = pF
+	return pA, nil
+}
+
+// NewArchiveFromReaderAt opens an archive that is already
+// open (or in memory). If kind is [ArchiveNone], it is
+// detected from the magic number. Closing the Archive
+// does not close r.
+func NewArchiveFromReaderAt(r io.ReaderAt, size int64, kind ArchiveKind, name string) (*Archive, error) {
+	return newArchive(r, size, kind, name, DefaultArchiveLimits)
+}
+
+func newArchive(r io.ReaderAt, size int64, kind ArchiveKind, name string, lim ArchiveLimits) (*Archive, error) {
+	if kind == ArchiveNone {
+		var head = make([]byte, 512)
+		n, _ := r.ReadAt(head, 0)
+		kind = sniffArchiveKind(head[:n])
+	}
+	var pA = &Archive{Kind: kind, Name: name}
+	switch kind {
+	case ArchiveZip:
+		zr, e := zip.NewReader(r, size)
+		if e != nil {
+			return nil, &fs.PathError{Op: "fu.openarchive:zip", Path: name, Err: e}
+		}
+		pA.FS = zr
+	case ArchiveTar, ArchiveTarGz:
+		var rdr io.Reader = io.NewSectionReader(r, 0, size)
+		if kind == ArchiveTarGz {
+			gz, e := gzip.NewReader(rdr)
+			if e != nil {
+				return nil, &fs.PathError{Op: "fu.openarchive:gzip", Path: name, Err: e}
+			}
+			defer gz.Close()
+			rdr = gz
+		}
+		tfs, e := newTarFS(rdr, lim)
+		if e != nil {
+			return nil, &fs.PathError{Op: "fu.openarchive:tar", Path: name, Err: e}
+		}
+		pA.FS = tfs
+	default:
+		return nil, &fs.PathError{Op: "fu.openarchive", Path: name, Err: ErrNotAnArchive}
+	}
+	return pA, nil
+}
+
+// OpenAsArchive opens a file-type FSObject as an [Archive], so
+// that an archive stored inside a directory tree (or inside an
+// fs.FS, including inside another Archive) can be browsed as a
+// sub-tree. The kind is taken from the extension if possible,
+// or else from the magic number. It applies the
+// [DefaultArchiveLimits].
+// .
+func (p *FSObject) OpenAsArchive() (*Archive, error) {
+	return p.OpenAsArchiveLimited(DefaultArchiveLimits)
+}
+
+// OpenAsArchiveLimited is [FSObject.OpenAsArchive] with
+// caller-supplied [ArchiveLimits]. An archive in an fs.FS
+// that cannot be read in place is read into memory, so it
+// may be at most MaxTotalSize bytes (as it is stored), else
+// the error is [ErrTooLarge].
+// .
+func (p *FSObject) OpenAsArchiveLimited(lim ArchiveLimits) (*Archive, error) {
+	var kind = ArchiveKindOf(p.FPs.RelFP)
+	if p.fsys == nil {
+		return OpenArchiveLimited(p.FPs.AbsFP, lim)
+	}
+	rsc, e := p.OpenContent(ContentLimits{MaxSize: lim.MaxTotalSize})
+	if e != nil {
+		return nil, e
+	}
+	var r io.ReaderAt
+	var size int64
+	if ra, ok := rsc.(io.ReaderAt); ok {
+		r = ra
+		size, e = rsc.Seek(0, io.SeekEnd)
+	} else {
+		var rdr io.Reader = rsc
+		if lim.MaxTotalSize > 0 {
+			rdr = io.LimitReader(rsc, lim.MaxTotalSize+1)
+		}
+		var bb []byte
+		bb, e = io.ReadAll(rdr)
+		r, size = bytes.NewReader(bb), int64(len(bb))
+	}
+	if e == nil && lim.MaxTotalSize > 0 && size > lim.MaxTotalSize {
+		e = fmt.Errorf("%w: %d > %d", ErrTooLarge, size, lim.MaxTotalSize)
+	}
+	if e != nil {
+		rsc.Close()
+		return nil, &fs.PathError{Op: "fso.openasarchive",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	pA, e := newArchive(r, size, kind, p.FPs.ShortFP, lim)
+	if e != nil {
+		rsc.Close()
+		return nil, e
+	}
+	pA.closer = rsc
+	return pA, nil
+}
+
+// Close releases the archive file, if the Archive opened it.
+func (p *Archive) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	var e = p.closer.Close()
+	p.closer = nil
+	return e
+}
+
+// NewFSObject returns the entry as an FSObject. Its RelFP is
+// relative to the archive, and its ShortFP is prefixed by the
+// archive's name (as "name!/entry") for use in messages.
+func (p *Archive) NewFSObject(name string) *FSObject {
+	var pFSO = NewFSObjectFromFS(p, name)
+	if p.Name != "" {
+		pFSO.FPs.ShortFP = p.Name + "!/" + pFSO.FPs.RelFP
+	}
+	return pFSO
+}
+
+// Lstat implements [fs.ReadLinkFS], so that [NewFSObjectFromFS]
+// sees symlinks in a tar as symlinks. For a zip it is the
+// same as Stat, since zip entries are not followed anyway.
+func (p *Archive) Lstat(name string) (fs.FileInfo, error) {
+	if rl, ok := p.FS.(fs.ReadLinkFS); ok {
+		return rl.Lstat(name)
+	}
+	return fs.Stat(p.FS, name)
+}
+
+// ReadLink implements [fs.ReadLinkFS].
+func (p *Archive) ReadLink(name string) (string, error) {
+	return fs.ReadLink(p.FS, name)
+}
+
+// Root returns the archive's root directory.
+func (p *Archive) Root() *FSObject {
+	return p.NewFSObject(".")
+}
+
+// ReadDir is [ReadDirFS] for a directory in the archive.
+func (p *Archive) ReadDir(dir string) ([]FSObject, error) {
+	FSIs, e := ReadDirFS(p, dir)
+	for i := range FSIs {
+		if p.Name != "" {
+			FSIs[i].FPs.ShortFP = p.Name + "!/" + FSIs[i].FPs.RelFP
+		}
+	}
+	return FSIs, e
+}
+
+// GatherTreeList is [GatherDirTreeListFS] for the whole archive.
+func (p *Archive) GatherTreeList() []string {
+	return GatherDirTreeListFS(p, ".")
+}
+
+// ============
+//  tar as FS
+// ============
+
+// tarFS is a read-only, in-memory [fs.FS] of a tar's entries.
+// Missing parent directories are synthesized. Entries whose
+// names are not valid (e.g. with "..") are skipped.
+type tarFS struct {
+	entries map[string]*tarEntry
+}
+
+type tarEntry struct {
+	fi       fs.FileInfo
+	target   string // of a symlink
+	data     []byte
+	children map[string]*tarEntry
+}
+
+func newTarFS(r io.Reader, lim ArchiveLimits) (*tarFS, error) {
+	var p = &tarFS{entries: make(map[string]*tarEntry)}
+	p.entries["."] = &tarEntry{fi: (&tar.Header{Name: "./",
+		Typeflag: tar.TypeDir, Mode: 0755}).FileInfo(),
+		children: make(map[string]*tarEntry)}
+	var tr = tar.NewReader(r)
+	var total int64
+	for {
+		hdr, e := tr.Next()
+		if e == io.EOF {
+			break
+		}
+		if e != nil {
+			return nil, e
+		}
+		var name = path.Clean(S.TrimPrefix(hdr.Name, "/"))
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+		var pE = &tarEntry{fi: hdr.FileInfo()}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			pE.children = make(map[string]*tarEntry)
+			// Keep children that were seen first
+			if old, ok := p.entries[name]; ok && old.children != nil {
+				pE.children = old.children
+			}
+		case tar.TypeSymlink:
+			pE.target = hdr.Linkname
+		case tar.TypeLink:
+			// A hard link shares an earlier entry's content
+			if old, ok := p.entries[path.Clean(hdr.Linkname)]; ok {
+				pE.data = old.data
+				hdr.Size = int64(len(old.data))
+				hdr.Typeflag = tar.TypeReg
+				pE.fi = hdr.FileInfo()
+			}
+		default:
+			pE.data, e = readTarEntry(tr, hdr, lim, total)
+			if e != nil {
+				return nil, e
+			}
+			total += int64(len(pE.data))
+		}
+		p.add(name, pE)
+	}
+	return p, nil
+}
+
+// readTarEntry reads an entry's content, but no more than
+// the [ArchiveLimits] allow (given total, the bytes read so
+// far), because the header's size cannot be trusted.
+func readTarEntry(tr *tar.Reader, hdr *tar.Header, lim ArchiveLimits, total int64) ([]byte, error) {
+	var max int64 = -1
+	if lim.MaxEntrySize > 0 {
+		max = lim.MaxEntrySize
+	}
+	if lim.MaxTotalSize > 0 && (max < 0 || lim.MaxTotalSize-total < max) {
+		max = lim.MaxTotalSize - total
+	}
+	if max < 0 {
+		return io.ReadAll(tr)
+	}
+	var tooBig = &fs.PathError{Op: "tar:read", Path: hdr.Name,
+		Err: fmt.Errorf("%w: entry size %d, limits %d/%d (total so far %d)",
+			ErrTooLarge, hdr.Size, lim.MaxEntrySize, lim.MaxTotalSize, total)}
+	if hdr.Size > max {
+		return nil, tooBig
+	}
+	bb, e := io.ReadAll(io.LimitReader(tr, max+1))
+	if e != nil {
+		return nil, e
+	}
+	if int64(len(bb)) > max {
+		return nil, tooBig
+	}
+	return bb, nil
+}
+
+// add links an entry into its parent, creating parents as needed.
+func (p *tarFS) add(name string, pE *tarEntry) {
+	p.entries[name] = pE
+	var dir, base = path.Split(name)
+	dir = path.Clean(dir)
+	if dir == "" {
+		dir = "."
+	}
+	parent, ok := p.entries[dir]
+	if !ok || parent.children == nil {
+		parent = &tarEntry{fi: (&tar.Header{Name: dir + "/",
+			Typeflag: tar.TypeDir, Mode: 0755}).FileInfo(),
+			children: make(map[string]*tarEntry)}
+		p.add(dir, parent)
+	}
+	parent.children[base] = pE
+}
+
+// lookup follows symlinks (within the FS) if asked to.
+func (p *tarFS) lookup(op, name string, follow bool) (*tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	for i := 0; i < 40; i++ {
+		pE, ok := p.entries[name]
+		if !ok {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if !follow || pE.fi.Mode()&fs.ModeSymlink == 0 {
+			return pE, nil
+		}
+		var tgt = pE.target
+		if !path.IsAbs(tgt) {
+			tgt = path.Join(path.Dir(name), tgt)
+		}
+		name = path.Clean(S.TrimPrefix(tgt, "/"))
+		if !fs.ValidPath(name) {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name,
+		Err: errors.New("too many levels of symbolic links")}
+}
+
+// Open implements [fs.FS].
+func (p *tarFS) Open(name string) (fs.File, error) {
+	pE, e := p.lookup("open", name, true)
+	if e != nil {
+		return nil, e
+	}
+	return &tarFile{tarEntry: pE, Reader: bytes.NewReader(pE.data)}, nil
+}
+
+// Stat implements [fs.StatFS].
+func (p *tarFS) Stat(name string) (fs.FileInfo, error) {
+	pE, e := p.lookup("stat", name, true)
+	if e != nil {
+		return nil, e
+	}
+	return pE.fi, nil
+}
+
+// Lstat implements [fs.ReadLinkFS].
+func (p *tarFS) Lstat(name string) (fs.FileInfo, error) {
+	pE, e := p.lookup("lstat", name, false)
+	if e != nil {
+		return nil, e
+	}
+	return pE.fi, nil
+}
+
+// ReadLink implements [fs.ReadLinkFS].
+func (p *tarFS) ReadLink(name string) (string, error) {
+	pE, e := p.lookup("readlink", name, false)
+	if e != nil {
+		return "", e
+	}
+	if pE.fi.Mode()&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return pE.target, nil
+}
+
+// ReadDir implements [fs.ReadDirFS], sorted by name.
+func (p *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	pE, e := p.lookup("readdir", name, true)
+	if e != nil {
+		return nil, e
+	}
+	if pE.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name,
+			Err: errors.New("not a directory")}
+	}
+	return pE.dirEntries(), nil
+}
+
+func (pE *tarEntry) dirEntries() []fs.DirEntry {
+	var des []fs.DirEntry
+	for _, c := range pE.children {
+		des = append(des, fs.FileInfoToDirEntry(c.fi))
+	}
+	sort.Slice(des, func(i, j int) bool {
+		return des[i].Name() < des[j].Name()
+	})
+	return des
+}
+
+// tarFile is an open entry. It can Seek, and
+// (being a bytes.Reader) it is an io.ReaderAt.
+type tarFile struct {
+	*tarEntry
+	*bytes.Reader
+	dirPos int
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.fi, nil }
+func (f *tarFile) Close() error               { return nil }
+
+// ReadDir implements [fs.ReadDirFile].
+func (f *tarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if f.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: f.fi.Name(),
+			Err: errors.New("not a directory")}
+	}
+	var des = f.dirEntries()[f.dirPos:]
+	if n > 0 {
+		if len(des) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(des) {
+			des = des[:n]
+		}
+	}
+	f.dirPos += len(des)
+	return des, nil
+}
+
+// String is for debugging.
+func (p *Archive) String() string {
+	return fmt.Sprintf("Archive(%s)%s", p.Kind, p.Name)
+}
@@ -0,0 +1,334 @@
+package fileutils
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// testTarEntry is a tar header's essentials.
+type testTarEntry struct {
+	name, body string
+	typ        byte
+	link       string
+}
+
+var testTarEntries = []testTarEntry{
+	{name: "top.txt", body: "top\n"},
+	// Its parent dir is not in the tar, so it is synthesized.
+	{name: "a/b/c.xml", body: "<c/>\n"},
+	{name: "a/", typ: tar.TypeDir},
+	{name: "a/link", typ: tar.TypeSymlink, link: "b/c.xml"},
+	{name: "a/hard", typ: tar.TypeLink, link: "top.txt"},
+	// Not valid, so skipped.
+	{name: "../evil.txt", body: "evil"},
+}
+
+func makeTar(t *testing.T, entries []testTarEntry, gz bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var tw *tar.Writer
+	var zw *gzip.Writer
+	if gz {
+		zw = gzip.NewWriter(&buf)
+		tw = tar.NewWriter(zw)
+	} else {
+		tw = tar.NewWriter(&buf)
+	}
+	for _, te := range entries {
+		var hdr = &tar.Header{Name: te.name, Mode: 0644,
+			Typeflag: te.typ, Linkname: te.link}
+		switch te.typ {
+		case 0:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(te.body))
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		}
+		if e := tw.WriteHeader(hdr); e != nil {
+			t.Fatal(e)
+		}
+		if hdr.Size > 0 {
+			tw.Write([]byte(te.body))
+		}
+	}
+	if e := tw.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if gz {
+		zw.Close()
+	}
+	return buf.Bytes()
+}
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw = zip.NewWriter(&buf)
+	for name, body := range files {
+		w, e := zw.Create(name)
+		if e != nil {
+			t.Fatal(e)
+		}
+		w.Write([]byte(body))
+	}
+	if e := zw.Close(); e != nil {
+		t.Fatal(e)
+	}
+	return buf.Bytes()
+}
+
+func TestArchiveKindOf(t *testing.T) {
+	var tests = []struct {
+		name string
+		want ArchiveKind
+	}{
+		{"x.zip", ArchiveZip},
+		{"X.ZIP", ArchiveZip},
+		{"x.tar", ArchiveTar},
+		{"x.tar.gz", ArchiveTarGz},
+		{"x.tgz", ArchiveTarGz},
+		{"x.gz", ArchiveNone},
+		{"x.epub", ArchiveNone},
+		{"zip", ArchiveNone},
+	}
+	for _, tc := range tests {
+		if got := ArchiveKindOf(tc.name); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSniffArchiveKind(t *testing.T) {
+	var tests = []struct {
+		name string
+		head []byte
+		want ArchiveKind
+	}{
+		{"zip", makeZip(t, map[string]string{"f": "x"}), ArchiveZip},
+		{"tar", makeTar(t, testTarEntries[:1], false), ArchiveTar},
+		{"tar.gz", makeTar(t, testTarEntries[:1], true), ArchiveTarGz},
+		{"text", []byte("just some text"), ArchiveNone},
+		{"empty", nil, ArchiveNone},
+	}
+	for _, tc := range tests {
+		if got := sniffArchiveKind(tc.head); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestTarArchive(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		var bb = makeTar(t, testTarEntries, gz)
+		// The kind is sniffed.
+		pA, e := NewArchiveFromReaderAt(bytes.NewReader(bb),
+			int64(len(bb)), ArchiveNone, "t.tar")
+		if e != nil {
+			t.Fatal(e)
+		}
+		if e = fstest.TestFS(pA, "top.txt", "a/b/c.xml",
+			"a/link", "a/hard"); e != nil {
+			t.Errorf("gz %v: %v", gz, e)
+		}
+		var p = pA.NewFSObject("a/link")
+		if p.FSO_type != FSO_type_SYML {
+			t.Errorf("gz %v: a/link is %s", gz, p.FSO_type)
+		}
+		if p.FPs.ShortFP != "t.tar!/a/link" {
+			t.Errorf("gz %v: ShortFP %q", gz, p.FPs.ShortFP)
+		}
+		if s, e := fs.ReadFile(pA, "a/link"); e != nil || string(s) != "<c/>\n" {
+			t.Errorf("gz %v: followed link: %q, %v", gz, s, e)
+		}
+		if s, e := pA.NewFSObject("a/hard").Contents(); e != nil || s != "top\n" {
+			t.Errorf("gz %v: hard link: %q, %v", gz, s, e)
+		}
+		if _, e := fs.Stat(pA, "evil.txt"); !errors.Is(e, fs.ErrNotExist) {
+			t.Errorf("gz %v: invalid name was kept", gz)
+		}
+		pp, e := pA.ReadDir("a")
+		if e != nil || len(pp) != 3 {
+			t.Errorf("gz %v: ReadDir: %d, %v", gz, len(pp), e)
+		}
+	}
+}
+
+func TestTarLimits(t *testing.T) {
+	var big = string(make([]byte, 10000))
+	var bb = makeTar(t, []testTarEntry{
+		{name: "one", body: big[:4000]},
+		{name: "two", body: big[:4000]},
+		{name: "three", body: big[:4000]},
+	}, true)
+	var tests = []struct {
+		name string
+		lim  ArchiveLimits
+		ok   bool
+	}{
+		{"none", ArchiveLimits{}, true},
+		{"default", DefaultArchiveLimits, true},
+		{"entry fits", ArchiveLimits{MaxEntrySize: 4000}, true},
+		{"entry too big", ArchiveLimits{MaxEntrySize: 3999}, false},
+		{"total fits", ArchiveLimits{MaxTotalSize: 12000}, true},
+		{"total too big", ArchiveLimits{MaxTotalSize: 11999}, false},
+		{"both", ArchiveLimits{MaxEntrySize: 5000, MaxTotalSize: 9000}, false},
+	}
+	var dir = t.TempDir()
+	var path = FP.Join(dir, "bomb.tgz")
+	if e := os.WriteFile(path, bb, 0644); e != nil {
+		t.Fatal(e)
+	}
+	for _, tc := range tests {
+		pA, e := OpenArchiveLimited(path, tc.lim)
+		if tc.ok {
+			if e != nil {
+				t.Errorf("%s: %v", tc.name, e)
+			} else {
+				pA.Close()
+			}
+		} else if !errors.Is(e, ErrTooLarge) {
+			t.Errorf("%s: got %v, want ErrTooLarge", tc.name, e)
+		}
+	}
+}
+
+func TestZipArchive(t *testing.T) {
+	var files = map[string]string{
+		"doc/a.md":  "# A\n",
+		"doc/b.txt": "bee\n",
+		"c.xml":     "<c/>\n",
+	}
+	var dir = t.TempDir()
+	var path = FP.Join(dir, "z.zip")
+	if e := os.WriteFile(path, makeZip(t, files), 0644); e != nil {
+		t.Fatal(e)
+	}
+	pA, e := OpenArchive(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pA.Close()
+	if pA.Kind != ArchiveZip {
+		t.Errorf("kind %q", pA.Kind)
+	}
+	for name, want := range files {
+		if s, e := pA.NewFSObject(name).Contents(); e != nil || s != want {
+			t.Errorf("%s: got %q, %v", name, s, e)
+		}
+	}
+	if !pA.Root().IsDir() {
+		t.Error("root is not a dir")
+	}
+	var list = pA.GatherTreeList()
+	if len(list) < len(files) {
+		t.Errorf("GatherTreeList: %v", list)
+	}
+}
+
+func TestOpenAsArchive(t *testing.T) {
+	// A zip inside a tar inside the host file system.
+	var inner = makeZip(t, map[string]string{"in.txt": "inner\n"})
+	var outer = makeTar(t, []testTarEntry{{name: "x/in.zip",
+		body: string(inner)}}, false)
+	var path = writeTestFile(t, t.TempDir(), "outer.tar", string(outer))
+
+	pOuter, e := NewFSObject(path).OpenAsArchive()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pOuter.Close()
+	pInner, e := pOuter.NewFSObject("x/in.zip").OpenAsArchive()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pInner.Close()
+	if s, e := pInner.NewFSObject("in.txt").Contents(); e != nil || s != "inner\n" {
+		t.Errorf("got %q, %v", s, e)
+	}
+
+	var txt = writeTestFile(t, t.TempDir(), "plain", "not an archive")
+	if _, e = NewFSObject(txt).OpenAsArchive(); !errors.Is(e, ErrNotAnArchive) {
+		t.Errorf("got %v, want ErrNotAnArchive", e)
+	}
+}
+
+// streamFS is an fs.FS whose files can seek but not ReadAt, and
+// which says that every file is empty, so that only a bounded
+// read can catch a file that is too big.
+type streamFS struct{ fstest.MapFS }
+
+type streamFile struct {
+	fs.File
+	io.Seeker
+}
+
+func (f streamFile) Stat() (fs.FileInfo, error) {
+	fi, e := f.File.Stat()
+	if e != nil {
+		return nil, e
+	}
+	return &synthFileInfo{name: fi.Name(), mode: fi.Mode(), modTime: fi.ModTime()}, nil
+}
+
+func (p streamFS) Open(name string) (fs.File, error) {
+	f, e := p.MapFS.Open(name)
+	if e != nil || name == "." {
+		return f, e
+	}
+	return streamFile{File: f, Seeker: f.(io.Seeker)}, nil
+}
+
+// TestOpenAsArchiveLimits checks that the limits apply to an
+// archive inside an archive (or inside any fs.FS), both to the
+// archive as it is stored and to its entries.
+func TestOpenAsArchiveLimits(t *testing.T) {
+	var big = string(make([]byte, 4000))
+	var bomb = makeTar(t, []testTarEntry{{name: "one", body: big},
+		{name: "two", body: big}, {name: "three", body: big}}, true)
+	var outer = makeTar(t, []testTarEntry{{name: "in.tgz", body: string(bomb)}}, false)
+	var path = writeTestFile(t, t.TempDir(), "outer.tar", string(outer))
+	pOuter, e := NewFSObject(path).OpenAsArchive()
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pOuter.Close()
+	var pIn = pOuter.NewFSObject("in.tgz")
+	var stored = int64(len(bomb))
+	for _, tc := range []struct {
+		name string
+		lim  ArchiveLimits
+		ok   bool
+	}{
+		{"default", DefaultArchiveLimits, true},
+		{"entries too big", ArchiveLimits{MaxEntrySize: 3999}, false},
+		{"total too big", ArchiveLimits{MaxTotalSize: 11999}, false},
+		{"stored too big", ArchiveLimits{MaxTotalSize: stored - 1}, false},
+	} {
+		pA, e := pIn.OpenAsArchiveLimited(tc.lim)
+		if tc.ok && e == nil {
+			pA.Close()
+		} else if tc.ok || !errors.Is(e, ErrTooLarge) {
+			t.Errorf("%s: got %v", tc.name, e)
+		}
+	}
+	// A file that is bigger than it says is caught while it is read.
+	var sfs = streamFS{fstest.MapFS{"in.tgz": {Data: bomb}}}
+	pIn = NewFSObjectFromFS(sfs, "in.tgz")
+	if _, e = pIn.OpenAsArchiveLimited(ArchiveLimits{MaxTotalSize: stored - 1}); !errors.Is(e, ErrTooLarge) {
+		t.Errorf("stream: got %v", e)
+	}
+	pA, e := pIn.OpenAsArchiveLimited(ArchiveLimits{MaxTotalSize: 12000})
+	if e != nil {
+		t.Fatalf("stream: %v", e)
+	}
+	pA.Close()
+}
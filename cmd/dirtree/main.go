@@ -5,16 +5,11 @@ import (
 	"os"
 	// FP "path/filepath"
 	FU "github.com/fbaube/fileutils"
-	SU "github.com/fbaube/stringutils"
 )
 
-var pre = []string { "#", ".git", ".DS_Store" }
-var mid = []string { "/#", "/." } // incl .git, .DS_Store 
-var pst = []string { "~" }
-
 func main() {
      if len(os.Args) == 1 {
-     	println("Provide an argument")
+     	println("Provide an argument (and optionally, an exclusions file)")
 	os.Exit(1)
 	}
      arg := os.Args[1]
@@ -25,13 +20,33 @@ func main() {
 	}
      fmt.Printf("%s :: total %d BEFORE filtering \n", arg, len(FileSlc))
 
-     fmt.Printf("===\nFILTERS: \n\t pre|%#v| \n\t mid|%#v| " +
-     			      "\n\tpost|%#v| \n===\n", pre, mid, pst)
+     // The exclusions are the preset, unless a file is given
+     var px = FU.HiddenFilesExcluder()
+     if len(os.Args) > 2 {
+     	var e error
+	px, e = FU.LoadPathExcluder(os.Args[2])
+	if e != nil {
+	   println(e.Error())
+	   os.Exit(1)
+	   }
+	}
+     fmt.Printf("===\nFILTERS: \n")
+     for _, r := range px.Rules() {
+     	 fmt.Printf("\t %s:%s %s \n", r.Kind, r.Target, r.Pattern)
+	 }
+     fmt.Printf("===\n")
      // Filter it
-     FileSlc = SU.FilterStringList(FileSlc, pre, mid, pst) 
+     var kept []string
+     for _, f := range FileSlc {
+     	 if excl, why := px.Exclude(f); excl {
+	    fmt.Printf("%s excluded: %s: %s\n", arg, f, why)
+	    } else {
+	    kept = append(kept, f)
+	    }
+	 }
+     FileSlc = kept
      for i, f := range FileSlc {
 	fmt.Printf("%s [%02d] %s \n", arg, i, f)
 	}
      fmt.Printf("%s :: total %d AFTER filtering \n", arg, len(FileSlc))
 }
-
@@ -11,8 +11,9 @@ package fileutils
 // in a map, hashes must be the values, not the keys. 
 
 // NOTE 1b: We cannot define our own comparison function 
-// for hashes (which are arrays, [16]byte), so instead we
-// store them as hex strings. 
+// for hashes (which are byte slices, of a length that 
+// depends on the [HashAlg]), so instead we store them 
+// as hex strings. 
 
 // NOTE 2: We distinguish between "contentful" names (names 
 // that are files that have content length greater than zero, 
@@ -23,7 +24,6 @@ import (
 	"fmt"
 	"os"
 	"io/fs"
-	"encoding/hex"
 )
 
 // DirectoryDetails should NOT follow (or enforce) the convention 
@@ -35,6 +35,8 @@ type DirectoryDetails struct {
 	DirFileInfo	fs.FileInfo
 	NamesToItems	map[string]*FSObject
 	NamesToHashes	map[string]string
+	// HashAlg is the algorithm used for NamesToHashes.
+	HashAlg		HashAlg
 	ContentfulFileCount,
 	ContentlessFileCount,
 	DirCount, MiscCount  int 
@@ -42,7 +44,14 @@ type DirectoryDetails struct {
 
 // ReadDirectoryDetails includes "Read" in its name because it works 
 // kinda like ReadDir: it does not recurse down into subdirectories.
+// It hashes with the primary algorithm in [DefaultHashAlgs].
 func ReadDirectoryDetails(aPath string) (*DirectoryDetails, error) {
+	return ReadDirectoryDetailsHashed(aPath, DefaultHashAlgs[0])
+}
+
+// ReadDirectoryDetailsHashed is [ReadDirectoryDetails] 
+// but with the selected content hash algorithm. 
+func ReadDirectoryDetailsHashed(aPath string, alg HashAlg) (*DirectoryDetails, error) {
 
 	var e error
 	var anFI fs.FileInfo
@@ -57,6 +66,7 @@ func ReadDirectoryDetails(aPath string) (*DirectoryDetails, error) {
 	var pDD = new(DirectoryDetails)
 	pDD.DirName = aPath
 	pDD.DirFileInfo = anFI
+	pDD.HashAlg = alg
 	// ======================
 	//  Read directory items 
 	// ======================
@@ -74,6 +84,7 @@ func ReadDirectoryDetails(aPath string) (*DirectoryDetails, error) {
 		if !p.Mode().IsRegular() { pDD.MiscCount++ } else
 		if 0 == int(p.Size())    { pDD.ContentlessFileCount++ } else
 		     			 { pDD.ContentfulFileCount++ } 
+		p.HashAlgs = []HashAlg{ alg }
 		_, e = p.Contents()
 		// permissions problem ? 
 		if e != nil {	return nil, &fs.PathError { 
@@ -85,12 +96,12 @@ func ReadDirectoryDetails(aPath string) (*DirectoryDetails, error) {
 	// =====================
 	//  Map names to hashes
 	// =====================
-	pDD.NamesToHashes = mapNamesToHashes(pDD.NamesToItems)
+	pDD.NamesToHashes = mapNamesToHashes(pDD.NamesToItems, alg)
 	
 	return pDD, nil 
 }
 
-func mapNamesToHashes(inMap map[string]*FSObject) map[string]string {
+func mapNamesToHashes(inMap map[string]*FSObject, alg HashAlg) map[string]string {
 	var  outMap   map[string]string
 	outMap = make(map[string]string)
 	for inName,pFSI := range inMap {
@@ -98,7 +109,10 @@ func mapNamesToHashes(inMap map[string]*FSObject) map[string]string {
 			inName); continue  }
 		if pFSI.TypedRaw == nil { fmt.Printf("no Raw: %s \n",
 			inName); continue  }
-		hashAsString := hex.EncodeToString(pFSI.TypedRaw.Hash[0:15])
+		d, e := pFSI.DigestOf(alg)
+		if e != nil || d.IsZero() { fmt.Printf("no %s hash: %s \n",
+			alg, inName); continue  }
+		hashAsString := d.Hex()
 		outMap[inName] = hashAsString
 		fmt.Printf("%s: len[%d] Hash:%s \n",
 			inName, pFSI.Size(), hashAsString)
@@ -0,0 +1,256 @@
+package fileutils
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	FP "path/filepath"
+	"sort"
+	S "strings"
+)
+
+// DirTree is a directory tree in memory, as a hierarchy of
+// [DirTreeNode]s, so that (unlike the flat results of
+// [GatherDirTreeList], [ReadDir] and [WalkTree]) parents and
+// children can be navigated without re-deriving them from path
+// strings. It is built by [NewDirTree] or [DirTreeOf], and is
+// not changed after that, so it is safe for concurrent reads.
+// .
+type DirTree struct {
+	// Root is the node for the root directory, whose Rel is ".".
+	Root *DirTreeNode
+	// Result is what the tree was built from,
+	// which has the walk's errors and symlinks.
+	Result *WalkResult
+	// index is every node, by Rel.
+	index map[string]*DirTreeNode
+}
+
+// DirTreeNode is a node of a [DirTree]. Its Children are sorted by
+// Name (bytewise, as for [ReadDir]), so their order is stable.
+//
+// FSO is nil for a directory that was not in the walk results but
+// that has descendants that were, such as the root (or a directory
+// that does not match) for [GlobTree], or the root if it was
+// skipped (see [WalkOptions.SkipRoot]).
+// .
+type DirTreeNode struct {
+	FSO *FSObject
+	// Rel is the slash-separated path relative
+	// to the root of the tree, or "." for the root.
+	Rel string
+	// Name is the last component of the path
+	// (for the root, of the path it was walked as).
+	Name     string
+	Depth    int
+	Parent   *DirTreeNode
+	Children []*DirTreeNode
+	// stats and size are for the subtree.
+	stats FSObjectSummaryStats
+	size  int64
+}
+
+// NewDirTree walks the tree at root (see [WalkTree]) and builds a
+// [DirTree] from the results. Any [WalkOptions.Yield] is ignored,
+// because the tree needs every item. If the walk fails, the tree
+// is built from what it found, and is returned with the error.
+func NewDirTree(ctx context.Context, root string, opts *WalkOptions) (*DirTree, error) {
+	var o WalkOptions
+	if opts != nil {
+		o = *opts
+	}
+	o.Yield = nil
+	pWR, e := WalkTree(ctx, root, &o)
+	if pWR == nil {
+		return nil, e
+	}
+	return DirTreeOf(pWR), e
+}
+
+// DirTreeOf builds a [DirTree] from the results of [WalkTree] (or
+// [GlobTree]). An item that is not below the root is ignored.
+// .
+func DirTreeOf(pWR *WalkResult) *DirTree {
+	var t = &DirTree{Result: pWR, index: make(map[string]*DirTreeNode)}
+	t.Root = &DirTreeNode{Rel: ".", Name: FP.Base(pWR.Root)}
+	t.index["."] = t.Root
+	var absRoot, e = FP.Abs(pWR.Root)
+	if e != nil {
+		absRoot = FP.Clean(pWR.Root)
+	}
+	// An item with an error might have no AbsFP,
+	// but its path is in its WalkError.
+	var errPaths = make(map[*FSObject]string)
+	for _, we := range pWR.Errors {
+		if we.Item != nil {
+			errPaths[we.Item] = we.Path
+		}
+	}
+	for _, p := range pWR.Items {
+		var fp = p.FPs.AbsFP
+		if fp == "" {
+			if fp = errPaths[p]; fp == "" {
+				continue
+			}
+			if fp, e = FP.Abs(fp); e != nil {
+				continue
+			}
+		}
+		rel, e := FP.Rel(absRoot, fp)
+		if e != nil || rel == ".." ||
+			S.HasPrefix(rel, ".."+string(FP.Separator)) {
+			continue
+		}
+		t.node(FP.ToSlash(rel)).FSO = p
+	}
+	t.Root.finish(0)
+	return t
+}
+
+// node returns the node for rel, making it (and
+// any of its ancestors that are missing) if need be.
+func (t *DirTree) node(rel string) *DirTreeNode {
+	if n, ok := t.index[rel]; ok {
+		return n
+	}
+	var dir, name = path.Split(rel)
+	var parent = t.node(path.Clean(dir))
+	var n = &DirTreeNode{Rel: rel, Name: name, Parent: parent}
+	parent.Children = append(parent.Children, n)
+	t.index[rel] = n
+	return n
+}
+
+// finish sorts the children and sets the depths and
+// the aggregates, bottom up.
+func (n *DirTreeNode) finish(depth int) {
+	n.Depth = depth
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	if n.FSO != nil {
+		n.stats.AddIn(n.FSO)
+		if n.FSO.FileInfo != nil && n.FSO.IsFile() {
+			n.size = n.FSO.Size()
+		}
+	}
+	for _, c := range n.Children {
+		c.finish(depth + 1)
+		n.size += c.size
+		n.stats.Add(c.stats)
+	}
+}
+
+// Lookup returns the node at rel (a path relative to the root,
+// with slashes or [os.PathSeparator]), or nil if there is none.
+// "", "." and "/" are the root.
+func (t *DirTree) Lookup(rel string) *DirTreeNode {
+	rel = path.Clean("/" + FP.ToSlash(rel))
+	if rel == "/" {
+		return t.Root
+	}
+	return t.index[rel[1:]]
+}
+
+// Len is the number of nodes in the tree.
+func (t *DirTree) Len() int {
+	return len(t.index)
+}
+
+// Walk calls fn for every node of the tree,
+// in depth-first order; see [DirTreeNode.Walk].
+func (t *DirTree) Walk(fn func(*DirTreeNode) error) error {
+	return t.Root.Walk(fn)
+}
+
+// Walk calls fn for the node and then (in order) for each of its
+// descendants, parents before children. If fn returns [fs.SkipDir]
+// the node's children are skipped, and if it returns [fs.SkipAll]
+// the walk stops and Walk returns nil. Any other error stops the
+// walk and is returned.
+// .
+func (n *DirTreeNode) Walk(fn func(*DirTreeNode) error) error {
+	if e := n.walk(fn); e != nil && e != fs.SkipAll {
+		return e
+	}
+	return nil
+}
+
+func (n *DirTreeNode) walk(fn func(*DirTreeNode) error) error {
+	if e := fn(n); e != nil {
+		if e == fs.SkipDir {
+			return nil
+		}
+		return e
+	}
+	for _, c := range n.Children {
+		if e := c.walk(fn); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// WalkPost is like [DirTreeNode.Walk], except that children
+// come before parents, so [fs.SkipDir] has no effect.
+func (n *DirTreeNode) WalkPost(fn func(*DirTreeNode) error) error {
+	if e := n.walkPost(fn); e != nil && e != fs.SkipAll {
+		return e
+	}
+	return nil
+}
+
+func (n *DirTreeNode) walkPost(fn func(*DirTreeNode) error) error {
+	for _, c := range n.Children {
+		if e := c.walkPost(fn); e != nil {
+			return e
+		}
+	}
+	if e := fn(n); e != nil && e != fs.SkipDir {
+		return e
+	}
+	return nil
+}
+
+// IsRoot is true for the root of the tree.
+func (n *DirTreeNode) IsRoot() bool {
+	return n.Parent == nil
+}
+
+// Child returns the child with the name, or nil.
+func (n *DirTreeNode) Child(name string) *DirTreeNode {
+	var i = sort.Search(len(n.Children), func(i int) bool {
+		return n.Children[i].Name >= name
+	})
+	if i < len(n.Children) && n.Children[i].Name == name {
+		return n.Children[i]
+	}
+	return nil
+}
+
+// Ancestors returns the node's parent, its parent, and
+// so on up to the root (so it is empty for the root).
+func (n *DirTreeNode) Ancestors() []*DirTreeNode {
+	var out []*DirTreeNode
+	for p := n.Parent; p != nil; p = p.Parent {
+		out = append(out, p)
+	}
+	return out
+}
+
+// Stats counts the items in the subtree, including the node
+// itself (but not a node whose FSO is nil). An item with an
+// error is counted as for [FSObjectSummaryStats.AddIn].
+func (n *DirTreeNode) Stats() FSObjectSummaryStats {
+	return n.stats
+}
+
+// Size is the total size of the regular files in the subtree
+// (so it does not include directories, or symlink targets).
+func (n *DirTreeNode) Size() int64 {
+	return n.size
+}
+
+func (n *DirTreeNode) String() string {
+	return n.Rel
+}
@@ -0,0 +1,252 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	FP "path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// sizedTreeFiles is a tree whose file sizes are known: 14
+// bytes in all, 13 of them in a/, and 10 of those in a/y/.
+var sizedTreeFiles = map[string]string{"a/x.txt": "xyz",
+	"a/y/z.txt": "0123456789", "b.txt": "b", "c/": "", "link -> a": ""}
+
+// dirTreeRels is the Rel of each node, in the order visited.
+func dirTreeRels(t *testing.T, walk func(func(*DirTreeNode) error) error,
+	fn func(*DirTreeNode) error) ([]string, error) {
+	t.Helper()
+	var rels []string
+	var e = walk(func(n *DirTreeNode) error {
+		rels = append(rels, n.Rel)
+		if fn != nil {
+			return fn(n)
+		}
+		return nil
+	})
+	return rels, e
+}
+
+func TestNewDirTree(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	tree, e := NewDirTree(context.Background(), root, &WalkOptions{Workers: 4})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if tree.Len() != 8 || tree.Root.Rel != "." || tree.Root.Name != FP.Base(root) ||
+		!tree.Root.IsRoot() || tree.Root.FSO == nil || tree.Result.Root != root {
+		t.Errorf("root: %d nodes, %+v", tree.Len(), tree.Root)
+	}
+	var names []string
+	for _, c := range tree.Root.Children {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b.txt", "c", "link"}) {
+		t.Errorf("children %v", names)
+	}
+	var z = tree.Root.Child("a").Child("y").Child("z.txt")
+	if z == nil || z != tree.Lookup("a/y/z.txt") || z.Depth != 3 || z.IsRoot() ||
+		!z.FSO.IsFile() || z.String() != "a/y/z.txt" || z.Parent.Rel != "a/y" {
+		t.Fatalf("z: %+v", z)
+	}
+	var anc []string
+	for _, n := range z.Ancestors() {
+		anc = append(anc, n.Rel)
+	}
+	if !reflect.DeepEqual(anc, []string{"a/y", "a", "."}) || tree.Root.Ancestors() != nil {
+		t.Errorf("ancestors %v", anc)
+	}
+	if tree.Root.Child("zz") != nil || tree.Root.Child("") != nil {
+		t.Error("Child of a missing name")
+	}
+	if l := tree.Lookup("link"); l == nil || !l.FSO.IsSymlink() || l.Children != nil {
+		t.Errorf("link: %+v", l)
+	}
+}
+
+func TestDirTreeLookup(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	tree, e := NewDirTree(context.Background(), root, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for rel, want := range map[string]string{
+		"": ".", ".": ".", "/": ".", "a/y": "a/y", "a/y/": "a/y", "./a//y": "a/y",
+		FP.Join("a", "y"): "a/y", "a/y/../x.txt": "a/x.txt",
+		"nope": "", "../a": "a", "a/y/z.txt/x": "",
+	} {
+		var n = tree.Lookup(rel)
+		if (n == nil) != (want == "") || (n != nil && n.Rel != want) {
+			t.Errorf("%q: got %v, want %q", rel, n, want)
+		}
+	}
+}
+
+func TestDirTreeAggregates(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	tree, e := NewDirTree(context.Background(), root, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var tests = []struct {
+		rel                     string
+		size                    int64
+		items, dirs, files, sls int
+	}{
+		{".", 14, 8, 4, 3, 1},
+		{"a", 13, 4, 2, 2, 0},
+		{"a/y", 10, 2, 1, 1, 0},
+		{"a/x.txt", 3, 1, 0, 1, 0},
+		{"c", 0, 1, 1, 0, 0},
+		// A symlink's target is not counted.
+		{"link", 0, 1, 0, 0, 1},
+	}
+	for _, tc := range tests {
+		var n = tree.Lookup(tc.rel)
+		var st = n.Stats()
+		if n.Size() != tc.size || st.NrItems != tc.items || st.NrDirs != tc.dirs ||
+			st.NrFiles != tc.files || st.NrSymLs != tc.sls {
+			t.Errorf("%s: size %d, stats %+v", tc.rel, n.Size(), st)
+		}
+	}
+	if tree.Root.Stats() != tree.Result.Stats {
+		t.Errorf("root %+v, walk %+v", tree.Root.Stats(), tree.Result.Stats)
+	}
+}
+
+func TestDirTreeWalk(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	tree, e := NewDirTree(context.Background(), root, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var errStop = errors.New("stop")
+	var tests = []struct {
+		name   string
+		post   bool
+		stopAt string
+		stop   error
+		want   []string
+		err    error
+	}{
+		{"pre", false, "", nil, []string{".", "a", "a/x.txt", "a/y", "a/y/z.txt",
+			"b.txt", "c", "link"}, nil},
+		{"skip dir", false, "a", fs.SkipDir, []string{".", "a", "b.txt", "c", "link"}, nil},
+		{"skip all", false, "a/y", fs.SkipAll, []string{".", "a", "a/x.txt", "a/y"}, nil},
+		{"error", false, "b.txt", errStop, []string{".", "a", "a/x.txt", "a/y",
+			"a/y/z.txt", "b.txt"}, errStop},
+		{"post", true, "", nil, []string{"a/x.txt", "a/y/z.txt", "a/y", "a",
+			"b.txt", "c", "link", "."}, nil},
+		// SkipDir is too late to skip anything.
+		{"post skip dir", true, "a/y", fs.SkipDir, []string{"a/x.txt", "a/y/z.txt",
+			"a/y", "a", "b.txt", "c", "link", "."}, nil},
+		{"post skip all", true, "a", fs.SkipAll, []string{"a/x.txt", "a/y/z.txt",
+			"a/y", "a"}, nil},
+		{"post error", true, "a/y", errStop, []string{"a/x.txt", "a/y/z.txt", "a/y"},
+			errStop},
+	}
+	for _, tc := range tests {
+		var walk = tree.Walk
+		if tc.post {
+			walk = tree.Root.WalkPost
+		}
+		got, e := dirTreeRels(t, walk, func(n *DirTreeNode) error {
+			if n.Rel == tc.stopAt {
+				return tc.stop
+			}
+			return nil
+		})
+		if e != tc.err || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, %v", tc.name, got, e)
+		}
+	}
+	// A subtree can be walked by itself.
+	got, _ := dirTreeRels(t, tree.Lookup("a").Walk, nil)
+	if !reflect.DeepEqual(got, []string{"a", "a/x.txt", "a/y", "a/y/z.txt"}) {
+		t.Errorf("subtree: got %v", got)
+	}
+}
+
+// TestDirTreeOf checks a tree made from results that do not have
+// every directory, so that some nodes have no FSObject.
+func TestDirTreeOf(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	pWR, e := GlobTree(context.Background(), root, "**/*.txt", &WalkOptions{Ordered: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	var tree = DirTreeOf(pWR)
+	got, _ := dirTreeRels(t, tree.Walk, nil)
+	if !reflect.DeepEqual(got, []string{".", "a", "a/x.txt", "a/y", "a/y/z.txt", "b.txt"}) {
+		t.Errorf("got %v", got)
+	}
+	for _, rel := range []string{".", "a", "a/y"} {
+		if tree.Lookup(rel).FSO != nil {
+			t.Errorf("%s has an FSObject", rel)
+		}
+	}
+	// Nodes with no FSObject are not counted.
+	if st := tree.Root.Stats(); st.NrItems != 3 || st.NrDirs != 0 || tree.Root.Size() != 14 {
+		t.Errorf("stats %+v, size %d", st, tree.Root.Size())
+	}
+	// An item with an error (and no paths) is put by its error's path.
+	root, pWR, e = walkWithFailures(t, WalkContinue)
+	if e != nil {
+		t.Fatal(e)
+	}
+	tree = DirTreeOf(pWR)
+	var b = tree.Lookup("b.txt")
+	if b == nil || b.FSO == nil || !b.FSO.HasError() || tree.Root.Stats().NrErrors != 2 {
+		t.Errorf("b.txt: %+v, stats %+v", b, tree.Root.Stats())
+	}
+	// An item from outside the root is ignored.
+	pWR.Items = append(pWR.Items, NewFSObject(FP.Dir(root)))
+	if DirTreeOf(pWR).Len() != tree.Len() {
+		t.Error("item outside root")
+	}
+}
+
+func TestNewDirTreeErrors(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	if tree, e := NewDirTree(context.Background(), "", nil); tree != nil || e == nil {
+		t.Errorf("empty root: got %v, %v", tree, e)
+	}
+	// Yield is ignored, because the tree needs every item.
+	tree, e := NewDirTree(context.Background(), root, &WalkOptions{
+		Yield: func(*FSObject) error { return errors.New("no") }})
+	if e != nil || tree.Len() != 8 {
+		t.Errorf("Yield: got %v, %v", tree, e)
+	}
+	// A walk that fails part way gives a tree of what it found.
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if tree, e = NewDirTree(ctx, root, nil); tree == nil ||
+		!errors.Is(e, context.Canceled) {
+		t.Errorf("cancelled: got %v, %v", tree, e)
+	}
+}
+
+// TestDirTreeConcurrent is for the race detector.
+func TestDirTreeConcurrent(t *testing.T) {
+	var root = writeTestTree(t, "", sizedTreeFiles)
+	tree, e := NewDirTree(context.Background(), root, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			tree.Walk(func(*DirTreeNode) error { n++; return nil })
+			if n != 8 || tree.Lookup("a/y/z.txt") == nil || tree.Root.Size() != 14 {
+				t.Errorf("got %d nodes", n)
+			}
+		}()
+	}
+	wg.Wait()
+}
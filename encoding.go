@@ -0,0 +1,461 @@
+package fileutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	S "strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// TextEncoding is a character encoding, named by its
+// (lower case) IANA label, e.g. "utf-16le".
+type TextEncoding string
+
+const (
+	EncUnknown     TextEncoding = ""
+	EncUTF8        TextEncoding = "utf-8"
+	EncUTF16LE     TextEncoding = "utf-16le"
+	EncUTF16BE     TextEncoding = "utf-16be"
+	EncUTF32LE     TextEncoding = "utf-32le"
+	EncUTF32BE     TextEncoding = "utf-32be"
+	EncLatin1      TextEncoding = "iso-8859-1"
+	EncWindows1252 TextEncoding = "windows-1252"
+)
+
+// ErrUnsupportedEncoding is returned when asked to transcode
+// an encoding that we can detect (or that is declared) but
+// cannot convert.
+var ErrUnsupportedEncoding = errors.New("unsupported text encoding")
+
+// ErrUnencodable is returned when text contains a character
+// that the target encoding cannot represent.
+var ErrUnencodable = errors.New("character not representable in encoding")
+
+// EncodingSource says how a [TextEncoding] was determined.
+type EncodingSource string
+
+const (
+	EncFromBOM      EncodingSource = "bom"
+	EncFromPattern  EncodingSource = "pattern"  // UTF-16/32 w/o BOM
+	EncFromXMLDecl  EncodingSource = "xml-decl" // encoding="..."
+	EncFromHTMLMeta EncodingSource = "html-meta"
+	EncFromUTF8     EncodingSource = "valid-utf8"
+	EncFromDefault  EncodingSource = "default" // not UTF-8, so Latin-1
+)
+
+// EncodingInfo is the result of [DetectEncoding], and is
+// recorded in an FSObject when its content is loaded, so
+// that the content can be written back the same way.
+type EncodingInfo struct {
+	Encoding TextEncoding   `json:"encoding"`
+	Source   EncodingSource `json:"source"`
+	// BOM is set if the content starts with a byte order mark.
+	BOM bool `json:"bom,omitempty"`
+	// Declared is the label in an XML declaration or HTML
+	// meta tag, as written, even if it was not used (it
+	// can be overridden by a BOM).
+	Declared string `json:"declared,omitempty"`
+	// Transcoded is set in an FSObject whose content was
+	// converted to UTF-8 when loaded (see
+	// [ContentLimits.TranscodeToUTF8]), and so must be
+	// converted back when saved.
+	Transcoded bool `json:"transcoded,omitempty"`
+}
+
+func (ei EncodingInfo) String() string {
+	if ei.Encoding == EncUnknown {
+		return "unknown"
+	}
+	var s = string(ei.Encoding) + " (" + string(ei.Source)
+	if ei.BOM {
+		s += ", BOM"
+	}
+	return s + ")"
+}
+
+// IsUnicode is true for UTF-8, -16, -32.
+func (enc TextEncoding) IsUnicode() bool {
+	switch enc {
+	case EncUTF8, EncUTF16LE, EncUTF16BE, EncUTF32LE, EncUTF32BE:
+		return true
+	}
+	return false
+}
+
+// isWide is true for UTF-16 and UTF-32, which are
+// not ASCII-compatible.
+func (enc TextEncoding) isWide() bool {
+	return enc.IsUnicode() && enc != EncUTF8
+}
+
+var boms = []struct {
+	bom string
+	enc TextEncoding
+}{
+	// UTF-32LE first, cos it starts with the UTF-16LE BOM.
+	{"\xFF\xFE\x00\x00", EncUTF32LE},
+	{"\x00\x00\xFE\xFF", EncUTF32BE},
+	{"\xEF\xBB\xBF", EncUTF8},
+	{"\xFF\xFE", EncUTF16LE},
+	{"\xFE\xFF", EncUTF16BE},
+}
+
+// bomFor returns the BOM for the encoding, or "".
+func bomFor(enc TextEncoding) string {
+	for _, b := range boms {
+		if b.enc == enc {
+			return b.bom
+		}
+	}
+	return ""
+}
+
+var (
+	rxXMLDeclEnc = regexp.MustCompile(
+		`^<\?xml\s[^>]*?encoding\s*=\s*["']([A-Za-z0-9._:-]+)["']`)
+	rxMetaCharset = regexp.MustCompile(
+		`(?i)<meta\s[^>]*?charset\s*=\s*["']?\s*([A-Za-z0-9._:-]+)`)
+)
+
+// DetectEncoding determines the encoding of the start of some text.
+// In order, it checks for:
+//   - a byte order mark (UTF-8, UTF-16, UTF-32);
+//   - UTF-16 or UTF-32 without a BOM, by the pattern of NUL bytes
+//     (e.g. "<\x00?\x00" is UTF-16LE, as in the XML spec, App. F);
+//   - an XML declaration's encoding="..." (which must be at the
+//     very start), and an HTML <meta charset> (in the first 1024
+//     bytes, as in the HTML spec's prescan);
+//   - valid UTF-8 (which includes plain ASCII);
+//
+// and if none of those, it is assumed to be Latin-1. But if it has
+// NUL bytes (which text in UTF-8 or Latin-1 does not), and is not
+// UTF-16 or UTF-32, it is [EncUnknown] (i.e. probably not text).
+//
+// Note that it does not check for binary formats; for that, sniff
+// the MIME type first (see [SniffMimeType]), as is done when an
+// FSObject's content is loaded.
+// .
+func DetectEncoding(head []byte) EncodingInfo {
+	var ei EncodingInfo
+	for _, b := range boms {
+		if bytes.HasPrefix(head, []byte(b.bom)) {
+			ei.Encoding, ei.Source, ei.BOM = b.enc, EncFromBOM, true
+			break
+		}
+	}
+	if ei.Encoding == EncUnknown {
+		if enc := detectWide(head); enc != EncUnknown {
+			ei.Encoding, ei.Source = enc, EncFromPattern
+		}
+	}
+	// The declarations can only be read if ASCII-compatible.
+	if ei.Encoding == EncUnknown || ei.Encoding == EncUTF8 {
+		var s = string(bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")))
+		var src EncodingSource
+		if m := rxXMLDeclEnc.FindStringSubmatch(s); m != nil {
+			ei.Declared, src = m[1], EncFromXMLDecl
+		} else if m := rxMetaCharset.FindStringSubmatch(
+			s[:min(len(s), 1024)]); m != nil {
+			ei.Declared, src = m[1], EncFromHTMLMeta
+		}
+		if ei.Declared != "" && ei.Encoding == EncUnknown {
+			if enc := EncodingForLabel(ei.Declared); enc != EncUnknown &&
+				!enc.isWide() {
+				ei.Encoding, ei.Source = enc, src
+			}
+		}
+	}
+	if ei.Encoding == EncUnknown && bytes.IndexByte(head, 0) < 0 {
+		if utf8.Valid(trimPartialRune(head)) {
+			ei.Encoding, ei.Source = EncUTF8, EncFromUTF8
+		} else {
+			ei.Encoding, ei.Source = EncLatin1, EncFromDefault
+		}
+	}
+	return ei
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence at the end,
+// which is normal when the input is only the start of the text.
+func trimPartialRune(bb []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(bb); i++ {
+		var c = bb[len(bb)-i]
+		if c < 0x80 {
+			return bb
+		}
+		if utf8.RuneStart(c) {
+			if !utf8.FullRune(bb[len(bb)-i:]) {
+				return bb[:len(bb)-i]
+			}
+			return bb
+		}
+	}
+	return bb
+}
+
+// detectWide looks for UTF-16/32 without a BOM. Text is assumed
+// to be mostly Latin script (e.g. markup), which in UTF-16 has a
+// NUL in every other byte. Binary data can have a similar pattern
+// of NULs, so the result must also decode to plausible text.
+func detectWide(head []byte) TextEncoding {
+	var n = min(len(head), 1024) &^ 3
+	if n < 4 {
+		return EncUnknown
+	}
+	var zeros [4]int
+	for i := 0; i < n; i++ {
+		if head[i] == 0 {
+			zeros[i%4]++
+		}
+	}
+	var q = n / 4 // chars per column
+	var most = func(c int) bool { return zeros[c] > q*3/4 }
+	var few = func(c int) bool { return zeros[c] < q/4 }
+	var enc = EncUnknown
+	switch {
+	case most(0) && most(1) && most(2) && few(3):
+		enc = EncUTF32BE
+	case few(0) && most(1) && most(2) && most(3):
+		enc = EncUTF32LE
+	case most(0) && few(1) && most(2) && few(3):
+		enc = EncUTF16BE
+	case few(0) && most(1) && few(2) && most(3):
+		enc = EncUTF16LE
+	}
+	if enc == EncUnknown || !plausibleText(head[:n], enc) {
+		return EncUnknown
+	}
+	return enc
+}
+
+// plausibleText is true if the bytes decode (in the encoding)
+// to text with no control characters other than white space,
+// no NULs, and no invalid sequences (except at the very end,
+// where a character might have been cut in two).
+func plausibleText(bb []byte, enc TextEncoding) bool {
+	s, e := DecodeToUTF8(bb, enc)
+	if e != nil {
+		return false
+	}
+	s = S.TrimSuffix(s, string(utf8.RuneError))
+	for _, r := range s {
+		switch {
+		case r == '\t', r == '\n', r == '\r', r == '\f':
+		case r < 0x20, r >= 0x7F && r < 0xA0, r == utf8.RuneError:
+			return false
+		}
+	}
+	return true
+}
+
+// EncodingForLabel maps a label (as used in XML and HTML) to a
+// TextEncoding, or EncUnknown. As in the HTML spec, "latin1" and
+// "iso-8859-1" are both treated as Latin-1, and "us-ascii" as UTF-8.
+// Labels that are not supported are returned as is (lower case).
+// .
+func EncodingForLabel(label string) TextEncoding {
+	var s = S.ToLower(S.TrimSpace(label))
+	switch s {
+	case "":
+		return EncUnknown
+	case "utf-8", "utf8", "unicode-1-1-utf-8", "us-ascii", "ascii":
+		return EncUTF8
+	case "utf-16le":
+		return EncUTF16LE
+	case "utf-16be", "utf-16", "unicode", "ucs-2":
+		// Without a BOM, UTF-16 is big-endian.
+		return EncUTF16BE
+	case "utf-32le":
+		return EncUTF32LE
+	case "utf-32be", "utf-32":
+		return EncUTF32BE
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1",
+		"cp819", "ibm819":
+		return EncLatin1
+	case "windows-1252", "cp1252", "x-cp1252":
+		return EncWindows1252
+	}
+	return TextEncoding(s)
+}
+
+// windows1252 is the characters for 0x80..0x9F; the
+// rest of the range is the same as Latin-1. The five
+// undefined positions are mapped to the C1 controls.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// DecodeToUTF8 converts text in the encoding to UTF-8, dropping
+// any BOM. Invalid sequences become U+FFFD. UTF-8 is only checked
+// for a BOM, not validated.
+// .
+func DecodeToUTF8(bb []byte, enc TextEncoding) (string, error) {
+	if bom := bomFor(enc); bom != "" {
+		bb = bytes.TrimPrefix(bb, []byte(bom))
+	}
+	switch enc {
+	case EncUTF8, EncUnknown:
+		return string(bb), nil
+	case EncLatin1, EncWindows1252:
+		var sb S.Builder
+		sb.Grow(len(bb) + len(bb)/8)
+		for _, c := range bb {
+			if enc == EncWindows1252 && c >= 0x80 && c <= 0x9F {
+				sb.WriteRune(windows1252[c-0x80])
+			} else {
+				sb.WriteRune(rune(c))
+			}
+		}
+		return sb.String(), nil
+	case EncUTF16LE, EncUTF16BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == EncUTF16BE {
+			order = binary.BigEndian
+		}
+		var uu = make([]uint16, len(bb)/2)
+		for i := range uu {
+			uu[i] = order.Uint16(bb[2*i:])
+		}
+		var s = string(utf16.Decode(uu))
+		if len(bb)%2 != 0 {
+			s += string(utf8.RuneError)
+		}
+		return s, nil
+	case EncUTF32LE, EncUTF32BE:
+		var order binary.ByteOrder = binary.LittleEndian
+		if enc == EncUTF32BE {
+			order = binary.BigEndian
+		}
+		var sb S.Builder
+		sb.Grow(len(bb) / 2)
+		for i := 0; i+4 <= len(bb); i += 4 {
+			var r = rune(order.Uint32(bb[i:]))
+			if !utf8.ValidRune(r) {
+				r = utf8.RuneError
+			}
+			sb.WriteRune(r)
+		}
+		if len(bb)%4 != 0 {
+			sb.WriteRune(utf8.RuneError)
+		}
+		return sb.String(), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedEncoding, enc)
+}
+
+// EncodeFromUTF8 is the inverse of [DecodeToUTF8]: it converts
+// UTF-8 text to the encoding, optionally with a BOM. It fails
+// (with [ErrUnencodable]) if a character cannot be represented.
+// .
+func EncodeFromUTF8(s string, enc TextEncoding, bom bool) ([]byte, error) {
+	var out []byte
+	if bom {
+		out = append(out, bomFor(enc)...)
+	}
+	switch enc {
+	case EncUTF8, EncUnknown:
+		return append(out, s...), nil
+	case EncLatin1, EncWindows1252:
+		for i, r := range s {
+			switch {
+			case r < 0x80 || (r >= 0xA0 && r <= 0xFF) ||
+				(enc == EncLatin1 && r <= 0xFF):
+				out = append(out, byte(r))
+			case enc == EncWindows1252:
+				var c = indexRune1252(r)
+				if c < 0 {
+					return nil, fmt.Errorf("%w: %s: %U at byte %d",
+						ErrUnencodable, enc, r, i)
+				}
+				out = append(out, byte(0x80+c))
+			default:
+				return nil, fmt.Errorf("%w: %s: %U at byte %d",
+					ErrUnencodable, enc, r, i)
+			}
+		}
+		return out, nil
+	case EncUTF16LE, EncUTF16BE:
+		var order binary.AppendByteOrder = binary.LittleEndian
+		if enc == EncUTF16BE {
+			order = binary.BigEndian
+		}
+		for _, u := range utf16.Encode([]rune(s)) {
+			out = order.AppendUint16(out, u)
+		}
+		return out, nil
+	case EncUTF32LE, EncUTF32BE:
+		var order binary.AppendByteOrder = binary.LittleEndian
+		if enc == EncUTF32BE {
+			order = binary.BigEndian
+		}
+		for _, r := range s {
+			out = order.AppendUint32(out, uint32(r))
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedEncoding, enc)
+}
+
+func indexRune1252(r rune) int {
+	for i, w := range windows1252 {
+		if w == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyEncoding is for content that was just loaded. It sniffs
+// the MIME type first, and then (unless that found a binary
+// format) detects the encoding, and records them in the fields
+// [MimeType] and [Encoding]. Then, if so requested, and if the
+// content is text, it returns it converted to UTF-8 (and true).
+//
+// Content that is not text, that has stray NULs, or whose
+// encoding is declared but is not supported, is left as is.
+// .
+func (p *FSObject) applyEncoding(bb []byte, lim ContentLimits) (string, bool, error) {
+	var head = bb[:min(len(bb), SniffLen)]
+	p.Encoding = EncodingInfo{}
+	if mt := MimeBase(SniffMimeType(head)); mt == MimeOctetStream ||
+		IsTextMimeType(mt) {
+		p.Encoding = DetectEncoding(head)
+	}
+	// This uses the encoding, for UTF-16/32 without a BOM.
+	p.setMimeType(head)
+	if !lim.TranscodeToUTF8 || !IsTextMimeType(p.MimeType) {
+		return "", false, nil
+	}
+	var enc = p.Encoding.Encoding
+	// Nothing to do ? Or not text after all ?
+	if (enc == EncUTF8 && !p.Encoding.BOM) || enc == EncUnknown ||
+		(!enc.isWide() && bytes.IndexByte(bb, 0) >= 0) {
+		return "", false, nil
+	}
+	s, e := DecodeToUTF8(bb, enc)
+	if errors.Is(e, ErrUnsupportedEncoding) {
+		return "", false, nil
+	}
+	if e != nil {
+		return "", false, e
+	}
+	if S.IndexByte(s, 0) >= 0 {
+		return "", false, nil
+	}
+	p.Encoding.Transcoded = true
+	return s, true, nil
+}
+
+// encodeForSave converts the content back to its original
+// encoding, if it was transcoded when it was loaded.
+func (p *FSObject) encodeForSave(s string) ([]byte, error) {
+	if !p.Encoding.Transcoded {
+		return []byte(s), nil
+	}
+	return EncodeFromUTF8(s, p.Encoding.Encoding, p.Encoding.BOM)
+}
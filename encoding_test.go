@@ -0,0 +1,184 @@
+package fileutils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// utf16BEnoBOM encodes s as UTF-16BE, without a BOM.
+func utf16BEnoBOM(t *testing.T, s string) []byte {
+	t.Helper()
+	bb, e := EncodeFromUTF8(s, EncUTF16BE, false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return bb
+}
+
+func TestDetectEncoding(t *testing.T) {
+	var xml = `<?xml version="1.0"?><topic id="t"><title>Title</title></topic>`
+	var tests = []struct {
+		name string
+		head []byte
+		enc  TextEncoding
+		src  EncodingSource
+		bom  bool
+		decl string
+	}{
+		{"ascii", []byte("plain"), EncUTF8, EncFromUTF8, false, ""},
+		{"utf-8", []byte("naïve café"), EncUTF8, EncFromUTF8, false, ""},
+		// The last character is cut in two.
+		{"utf-8 cut", []byte("café")[:4], EncUTF8, EncFromUTF8, false, ""},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFhi"), EncUTF8, EncFromBOM, true, ""},
+		{"utf-16le bom", utf16LE("hi"), EncUTF16LE, EncFromBOM, true, ""},
+		{"utf-16be bom", []byte("\xFE\xFF\x00h\x00i"), EncUTF16BE, EncFromBOM, true, ""},
+		{"utf-32le bom", []byte("\xFF\xFE\x00\x00h\x00\x00\x00"), EncUTF32LE, EncFromBOM, true, ""},
+		{"utf-16be pattern", utf16BEnoBOM(t, xml), EncUTF16BE, EncFromPattern, false, ""},
+		{"utf-16le pattern", utf16LE(xml)[2:], EncUTF16LE, EncFromPattern, false, ""},
+		{"latin-1", []byte("caf\xE9 au lait"), EncLatin1, EncFromDefault, false, ""},
+		{"xml decl", []byte(`<?xml version="1.0" encoding="windows-1252"?><a>` + "\x93"),
+			EncWindows1252, EncFromXMLDecl, false, "windows-1252"},
+		{"html meta", []byte(`<html><head><meta charset="ISO-8859-1"></head>`),
+			EncLatin1, EncFromHTMLMeta, false, "ISO-8859-1"},
+		// A BOM beats a declaration, which is still recorded.
+		{"bom and decl", []byte("\xEF\xBB\xBF" + `<?xml version="1.0" encoding="latin1"?>`),
+			EncUTF8, EncFromBOM, true, "latin1"},
+		// Declared but not supported, so it is reported as is.
+		{"shift_jis", []byte(`<?xml version="1.0" encoding="Shift_JIS"?>`),
+			"shift_jis", EncFromXMLDecl, false, "Shift_JIS"},
+		// NULs, but no UTF-16 pattern.
+		{"binary", []byte("\x00\x01\x02\x03abc\x00\x00\xFF"), EncUnknown, "", false, ""},
+	}
+	for _, tc := range tests {
+		var ei = DetectEncoding(tc.head)
+		if ei.Encoding != tc.enc || ei.Source != tc.src || ei.BOM != tc.bom ||
+			ei.Declared != tc.decl {
+			t.Errorf("%s: got %+v", tc.name, ei)
+		}
+	}
+}
+
+func TestEncodingForLabel(t *testing.T) {
+	for label, want := range map[string]TextEncoding{
+		"UTF-8": EncUTF8, "us-ascii": EncUTF8, "utf-16": EncUTF16BE,
+		"UTF-16LE": EncUTF16LE, "utf-32": EncUTF32BE, "Latin1": EncLatin1,
+		" cp1252 ": EncWindows1252, "": EncUnknown, "EUC-JP": "euc-jp",
+	} {
+		if got := EncodingForLabel(label); got != want {
+			t.Errorf("%q: got %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	var text = "Grüße, “quotes” – and € 5 😀"
+	var encs = []TextEncoding{EncUTF8, EncUTF16LE, EncUTF16BE, EncUTF32LE, EncUTF32BE}
+	for _, enc := range encs {
+		for _, bom := range []bool{false, true} {
+			bb, e := EncodeFromUTF8(text, enc, bom)
+			if e != nil {
+				t.Fatalf("%s: %v", enc, e)
+			}
+			if bom != bytes.HasPrefix(bb, []byte(bomFor(enc))) {
+				t.Errorf("%s: BOM %v", enc, bom)
+			}
+			if s, e := DecodeToUTF8(bb, enc); e != nil || s != text {
+				t.Errorf("%s, BOM %v: got %q, %v", enc, bom, s, e)
+			}
+		}
+	}
+	// Only some characters fit in the 8-bit encodings.
+	var text8 = "Grüße, “quotes” – and € 5"
+	if bb, e := EncodeFromUTF8(text8, EncWindows1252, false); e != nil {
+		t.Error(e)
+	} else if s, _ := DecodeToUTF8(bb, EncWindows1252); s != text8 {
+		t.Errorf("windows-1252: got %q", s)
+	}
+	if _, e := EncodeFromUTF8(text8, EncLatin1, false); !errors.Is(e, ErrUnencodable) {
+		t.Errorf("latin-1: got %v, want ErrUnencodable", e)
+	}
+	if _, e := EncodeFromUTF8("x", "shift_jis", false); !errors.Is(e, ErrUnsupportedEncoding) {
+		t.Errorf("shift_jis: got %v, want ErrUnsupportedEncoding", e)
+	}
+}
+
+// TestTranscodeAndSave checks that content is converted to UTF-8
+// when it is loaded, and back again when it is saved, so that an
+// unchanged file is saved byte for byte.
+func TestTranscodeAndSave(t *testing.T) {
+	var text = "<?xml version=\"1.0\"?>\n<p>Ça coûte 5 €.</p>\n"
+	var lim = DefaultContentLimits
+	lim.TranscodeToUTF8 = true
+	var tests = []struct {
+		name string
+		enc  TextEncoding
+		bom  bool
+	}{
+		{"utf-16le", EncUTF16LE, true},
+		{"utf-16be", EncUTF16BE, false},
+		{"windows-1252", EncWindows1252, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var src = text
+			if tc.enc == EncWindows1252 {
+				src = strings.Replace(text, `"1.0"?`,
+					`"1.0" encoding="windows-1252"?`, 1)
+			}
+			bb, e := EncodeFromUTF8(src, tc.enc, tc.bom)
+			if e != nil {
+				t.Fatal(e)
+			}
+			var path = writeTestFile(t, t.TempDir(), "f.xml", string(bb))
+			var p = NewFSObject(path)
+			s, e := p.ContentsLimited(lim)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if s != src || !p.Encoding.Transcoded || p.Encoding.Encoding != tc.enc {
+				t.Fatalf("got %q, %v", s, p.Encoding)
+			}
+			if e = p.SetContents(s); e != nil {
+				t.Fatal(e)
+			}
+			if e = p.Save(); e != nil {
+				t.Fatal(e)
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, bb) {
+				t.Errorf("saved %q, want %q", got, bb)
+			}
+		})
+	}
+}
+
+// TestTranscodeLeavesAlone checks the content that must NOT
+// be transcoded, even when that is asked for.
+func TestTranscodeLeavesAlone(t *testing.T) {
+	var lim = DefaultContentLimits
+	lim.TranscodeToUTF8 = true
+	var png = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xE9\xE9\xE9"
+	var tests = []struct {
+		name, content string
+		enc           TextEncoding
+	}{
+		{"utf-8", "already UTF-8: café\n", EncUTF8},
+		{"png", png, EncUnknown},
+		{"binary", "\x00\x01\x02\x03\x04text\x00\x00\xE9\xFF", EncUnknown},
+		{"shift_jis", `<?xml version="1.0" encoding="Shift_JIS"?><a>` +
+			"\x82\xa0</a>", "shift_jis"},
+	}
+	for _, tc := range tests {
+		var path = writeTestFile(t, t.TempDir(), "f", tc.content)
+		var p = NewFSObject(path)
+		s, e := p.ContentsLimited(lim)
+		if e != nil {
+			t.Fatalf("%s: %v", tc.name, e)
+		}
+		if s != tc.content || p.Encoding.Transcoded || p.Encoding.Encoding != tc.enc {
+			t.Errorf("%s: got %q, %v", tc.name, s, p.Encoding)
+		}
+	}
+}
@@ -6,3 +6,24 @@ var filterPrefixes = []string { "#", ".git", ".DS_Store" }
 var filterMidfixes = []string { "/#", "/." } // incl .git, .DS_Store 
 var filterSuffixes = []string { "~" }
 
+// HiddenFilesExcluder is the preset [PathExcluder] made from 
+// the lists filterPrefixes etc.: it excludes paths that start 
+// with "#", ".git" or ".DS_Store", paths that have a component 
+// (after the first) that starts with "#" or ".", and paths that 
+// end with "~" (i.e. editor backups).
+func HiddenFilesExcluder() *PathExcluder {
+	var px = new(PathExcluder)
+	for _, pfx := range filterPrefixes {
+		px.rules = append(px.rules, ExcludeRule{
+			Kind: ExcludePrefix, Target: TargetPath, Pattern: pfx})
+	}
+	for _, mfx := range filterMidfixes {
+		px.rules = append(px.rules, ExcludeRule{
+			Kind: ExcludeContains, Target: TargetPath, Pattern: mfx})
+	}
+	for _, sfx := range filterSuffixes {
+		px.rules = append(px.rules, ExcludeRule{
+			Kind: ExcludeSuffix, Target: TargetPath, Pattern: sfx})
+	}
+	return px
+}
@@ -0,0 +1,25 @@
+package fileutils
+
+import (
+	"io/fs"
+	"time"
+)
+
+// synthFileInfo is an [fs.FileInfo] that is not the result of
+// a call to [os.Lstat], but is instead synthesized, for content
+// that lives only in memory (see [NewFSObjectFromContent]), and
+// for FSObjects that are rebuilt from a stored description.
+// Its Sys() is always nil.
+type synthFileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi *synthFileInfo) Name() string       { return fi.name }
+func (fi *synthFileInfo) Size() int64        { return fi.size }
+func (fi *synthFileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *synthFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *synthFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *synthFileInfo) Sys() any           { return nil }
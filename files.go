@@ -8,8 +8,6 @@ import (
 	"os"
 	FP "path/filepath"
 	S "strings"
-
-	"github.com/mgutz/str"
 )
 
 // AbsWRT is like "filepath.Abs(..)"": it can convert a possibly-relative
@@ -113,38 +111,20 @@ func IsNonEmpty(path string) bool {
 	return (err == nil && fi.Mode().IsRegular() && fi.Size() > 0)
 }
 
-// IsXML returns true *iff* the file exists *and*
-// appears to be XML. The check is simple though.
+// IsXML returns true *iff* the file exists *and* appears to be
+// XML (including XHTML), per [ClassifyMarkupFile], which applies
+// the rules in xml-doc.txt; so HTML (with or without a DOCTYPE)
+// is not XML. Markup that no rule can decide (such as a root
+// element with no DOCTYPE and no xmlns) is assumed to be XML.
 func IsXML(path string) bool {
 	if !IsNonEmpty(path) {
 		return false
 	}
-	file, e := os.Open(path)
-	if e != nil {
-		panic("fu.IsXML.os.Open<" + path + ">")
-	}
-	var bb []byte
-	bb = make([]byte, 256)
-	nRedd, e := file.Read(bb)
+	mc, e := ClassifyMarkupFile(path)
 	if e != nil {
-		panic("fu.IsXML")
-	}
-	// the minimum valid XML file is " <x/> "
-	if nRedd < 4 {
 		return false
 	}
-	s := S.TrimSpace(string(bb))
-	if !S.HasPrefix(s, "<") {
-		return false
-	}
-	OKprefixes := []string{"<?", "<!", "<--"} // and tags!
-	for _, ss := range OKprefixes {
-		if S.HasPrefix(s, ss) {
-			return true
-		}
-	}
-	// We require valid XML tags to begin with A-Za-z
-	return str.IsAlpha(str.CharAt(s, 1))
+	return mc.Dialect.IsXML() || mc.Dialect == MarkupUnknown
 }
 
 // CopyFileGreedily reads the entire file into memory,
@@ -163,15 +143,28 @@ func CopyFileGreedily(src string, dst string) error {
 	return nil
 }
 
-// CopyFileFromTo copies a single file from src to dst.
-func CopyFileFromTo(src, dst string) error {
+// CopyOption modifies the behavior of [CopyFileFromTo].
+type CopyOption int
+
+const (
+	// CopyWithXattrs also copies the extended attributes
+	// that a normal user can set (see [CopyXattrs]). If dst
+	// does not support them but src has some, the copy
+	// returns an error that wraps [ErrXattrUnsupported].
+	CopyWithXattrs CopyOption = iota + 1
+)
+
+// CopyFileFromTo copies a single file from src to dst,
+// plus its permissions, and per the [CopyOption]s.
+func CopyFileFromTo(src, dst string, opts ...CopyOption) error {
 	var err error
 	var srcfd *os.File
 	var dstfd *os.File
 	var srcinfo os.FileInfo
 
-	if srcfd, err = os.Open(src); err != nil {
-		return err
+	// Do not hang on a named pipe.
+	if srcfd, _, err = openRegular(src); err != nil {
+		return &os.PathError{Op: "copyfile", Path: src, Err: err}
 	}
 	defer srcfd.Close()
 
@@ -187,7 +180,17 @@ func CopyFileFromTo(src, dst string) error {
 	if srcinfo, err = os.Stat(src); err != nil { 
 		return err
 	}
-	return os.Chmod(dst, srcinfo.Mode())
+	if err = os.Chmod(dst, srcinfo.Mode()); err != nil {
+		return err
+	}
+	for _, opt := range opts {
+		if opt == CopyWithXattrs {
+			if err = CopyXattrs(src, dst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func AppendToFileBaseName(name, toAppend string) string {
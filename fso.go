@@ -2,20 +2,20 @@ package fileutils
 
 import (
 	"fmt"
-	"errors"
 	"io"
 	"os"
 	"io/fs"
-	"crypto/md5"
 	CT "github.com/fbaube/ctoken"
 	SU "github.com/fbaube/stringutils"
 	L "github.com/fbaube/mlog"
 )
 
 // MAX_FILE_SIZE is set (arbitrarily) to 100 megabytes.
+// It is the default upper limit in [DefaultContentLimits].
 const MAX_FILE_SIZE = 100_000_000
 // MIN_FILE_SIZE is the minimum that can be analysed for
 // MIME/content type, and is set (arbitrarily) to 6 bytes.
+// It is the default lower limit in [DefaultContentLimits].
 const MIN_FILE_SIZE = 6
 
 func init() {
@@ -120,10 +120,48 @@ type FSObject struct { // this has (Typed) Raw
 	// info, which is part of the motivstion for this too-large struct. 
 	FPs Filepaths
 
+	// HashAlgs selects the content hash algorithms; the 
+	// first is the primary one. If nil, [DefaultHashAlgs]. 
+	HashAlgs []HashAlg
+	// Digests is set when the content is loaded (or
+	// streamed), one per algorithm in HashAlgs.
+	Digests ContentDigests
+	// fsys is set if the object was found in an [fs.FS] 
+	// (see [NewFSObjectFromFS]) rather than on the host. 
+	fsys fs.FS
+	// inMemory is set for content that has no file (yet);
+	// see [NewFSObjectFromContent].
+	inMemory bool
+	// loaded is the state of the file on disk as of when
+	// its content was loaded; see [Save].
+	loaded contentBaseline
+
+	// MimeType is sniffed from the content when it is
+	// loaded (see [SniffMimeType]), or by [DetectMimeType].
+	MimeType string
+	// MimeExtMismatch is set if the file extension
+	// contradicts MimeType (see [MimeExtMismatch]).
+	MimeExtMismatch bool
+
+	// MType is what kind of markup document the content is
+	// (see [ClassifyMType]). It is set when the content is
+	// loaded, or by [DetectMType]; its markup syntax is also
+	// recorded as the Raw_type in the TypedRaw.
+	MType MType
+
+	// Encoding is detected when the content is loaded 
+	// (see [DetectEncoding]); it says whether the content
+	// was transcoded to UTF-8, so that [Save] can undo it.
+	Encoding EncodingInfo
+
 	// Perms is UNIX-style "rwx" user/group/world
 	Perms string	
-	// Inode and NLinks are for hard link detection. 
+	// Inode and NLinks are for hard link detection,
+	// and are set only if NLinks > 1 (and not a symlink). 
 	Inode, NLinks int // uint64
+	// Stat is the rest of the stat(2) info, where the
+	// OS (or the [fs.FS]) provides it; see [StatInfo]. 
+	Stat StatInfo
 	// Errer provides an NPE-proof error field
 	Errer
 }
@@ -133,25 +171,55 @@ type FSObject struct { // this has (Typed) Raw
 //  - it IS a file, and 
 //  - it has not been read in yet
 // and then it
-//  - calculates & stores file file's hash, and 
+//  - calculates & stores file file's hash(es) (see 
+//    [HashAlgs]), and 
+//  - detects the character encoding (see [Encoding]), 
+//    and if requested, transcodes it to UTF-8, and 
+//  - sniffs the MIME type (see [MimeType]), and 
+//  - classifies the markup (see [MType]), and 
 //  - quickly checks for XML and HTML5 declarations
 //
 // Contents should always be fresh, even when files
-// are active, so we first fetch a new [os.FileInfo]
-// to check for changes. If no changes are indicated,
-// and the content has not been changed programmatically
-// (check flag [ContentIsDirty]), do a fast return.
+// are active, so we first call [Refresh] to check 
+// for changes. If no changes are indicated, do a 
+// fast return. If the content has been changed
+// programmatically (see [SetContents] and flag 
+// [ContentInMemoryIsDirty]), the content in memory
+// is returned, and it is not reloaded from disk.
 // 
 // It is tolerant about non-files, non-existent 
 // objects, and empty files, returning nil error.
+// It never opens a named pipe, socket or device
+// (see [FSO_type]), because that might block.
 //
-// NOTE The call to [os.Open] defaults to R/W mode,
-// even tho R/O might often suffice.
+// It applies [DefaultContentLimits]; for other limits
+// use [ContentsLimited], and for files that are too big
+// to hold in memory use [OpenContent] or [ReadChunks], or
+// [MapContents], whose mapping is not copied into memory
+// and must be released by [MappedContent.Close].
 // .
 func (p *FSObject) Contents() (string, error) {
+	return p.ContentsLimited(DefaultContentLimits)
+}
+
+// ContentsLimited is [Contents] with caller-supplied
+// [ContentLimits]. The reading is done by [OpenContent],
+// and the hash is taken while reading. 
+// .
+func (p *FSObject) ContentsLimited(lim ContentLimits) (string, error) {
+	// Lives only in memory ? 
+	if p.inMemory {
+	   if p.TypedRaw == nil { return "", nil }
+	   return p.TypedRaw.S(), nil
+	}
+	// Changed in memory and not yet saved ? 
+	if p.FPs.ContentInMemoryIsDirty && p.TypedRaw != nil {
+	   return p.TypedRaw.S(), nil
+	}
 	// Exists ?
 	if p.FPs.DoesNotExist {
-	   return "", fmt.Errorf("fso.contents(%s): %w", os.ErrNotExist) 
+	   return "", fmt.Errorf("fso.contents(%s): %w",
+	   	  p.FPs.ShortFP, os.ErrNotExist) 
 	}
 	// Not a file ? 
 	if !p.IsFile() {
@@ -160,26 +228,27 @@ func (p *FSObject) Contents() (string, error) {
 		return "", nil
 	}
      	var e error
-	var hasChanged bool 
-	var newFI os.FileInfo
+	var kind ChangeKind
 	var shortFP = p.FPs.ShortFP
 
-	// Get a fresh FileInfo
-	newFI, e = os.Lstat(p.FPs.AbsFP)
+	// Get a fresh FileInfo, and see what (if 
+	// anything) has changed. Changed content 
+	// is dropped from memory by Refresh. 
+	kind, e = p.Refresh(false)
 	if e != nil {
-	   return "", fmt.Errorf("fso.contents(%s): %w", p.FPs.AbsFP, e)
+	   return "", fmt.Errorf("fso.contents(%s): %w", shortFP, e)
 	}
-	// The content has previously been fetched
-	// but the object has changed somehow ? 
-	if p.FPs.ContentInMemoryIsDirty ||
-	  (p.FileInfo.ModTime() != newFI.ModTime() &&
-	  !p.FileInfo.ModTime().IsZero()) {
-	   p.FileInfo = newFI
-	   hasChanged = true	   
+	if kind == ChangeDeleted {
+	   return "", fmt.Errorf("fso.contents(%s): %w",
+	   	  shortFP, os.ErrNotExist) 
+	}
+	// Replaced by something that is not a file ? 
+	if !p.IsFile() {
+		return "", nil
 	}
 	// If the object hasn't changed and we
 	// already have the contents, return now
-	if p.TypedRaw != nil && !hasChanged {
+	if p.TypedRaw != nil && kind < ChangeContent {
 		return p.TypedRaw.S(), nil
 	}
 	// Allocate this now to prevent NPEs
@@ -189,38 +258,53 @@ func (p *FSObject) Contents() (string, error) {
 	   // No-op
 	   // This might be repetitive
 	   p.TypedRaw.Raw_type = SU.Raw_type_NIL
+	   p.Digests = nil
+	   p.setBaseline()
 	   return "", nil
 	} else if // Suspiciously tiny ?
-	        p.Size() < MIN_FILE_SIZE { 
+	        p.Size() < lim.MinSize { 
 		L.L.Warning("fso.contents: tiny "+
 			"file [%d]: %s", p.Size(), shortFP)
 		p.TypedRaw.Raw_type = SU.Raw_type_NIL
 	}
-	// println("LoadContents: chkpt 2")
 	// If it's too big, BARF!
-	if p.Size() > MAX_FILE_SIZE {
+	if lim.exceeds(p.Size()) {
 		return "[TOO BIG]", &fs.PathError {
-		        Op:"fso.contents", Err:errors.New(fmt.Sprintf(
-		       "file too large: %d", p.Size())), Path:shortFP}
+		        Op:"fso.contents", Err:fmt.Errorf("%w: %d",
+			ErrTooLarge, p.Size()), Path:shortFP}
+	}
+	// Big enough to map, rather than copy ? 
+	if lim.MmapAbove > 0 && p.Size() > lim.MmapAbove {
+		return p.loadMapped(lim)
 	}
-	// println("LoadContents: chkpt 3")
-	// Open it, just to check (and then immediately close it)
-	var pF *os.File
 	// NOTE FIXME This might fail in a RootFS
-	pF, e = os.Open(p.FPs.AbsFP)
-	defer pF.Close()
+	var rc io.ReadCloser
+	rc, e = p.OpenContent(lim)
 	if e != nil {
 		// We could check for file non-existence here.
 		// And we could panic if it happens, altho a race
 		// for a just-deleted file is also conceivable.
-		return "", &fs.PathError{Op:"fso.content:os.open",
-		       Err:e, Path:shortFP }
+		return "", e
 	}
+	defer rc.Close()
 	// -------------------
 	//  NOW READ THE FILE
 	// -------------------
 	var bb []byte
-	bb, e = io.ReadAll(pF)
+	var mh *MultiHasher
+	mh, e = p.newHasher()
+	if e != nil {
+		return "", &fs.PathError{
+		       Op:"fso.contents:hash", Err:e,Path:shortFP }
+	}
+	// Read at most one byte more than the limit, so that
+	// a file that has grown since the size check is caught
+	// without reading all of it into memory.
+	var r io.Reader = io.TeeReader(rc, mh)
+	if lim.MaxSize > 0 {
+		r = io.LimitReader(r, lim.MaxSize+1)
+	}
+	bb, e = io.ReadAll(r)
 	if e != nil {
 		return "", &fs.PathError{
 		       Op:"fso.contents:io.readall", Err:e,Path:shortFP }
@@ -237,15 +321,34 @@ func (p *FSObject) Contents() (string, error) {
 	if len(bb) == 0 {
 		panic("==> empty file?!: " + shortFP)
 	}
+	if lim.exceeds(int64(len(bb))) {
+		return "[TOO BIG]", &fs.PathError {
+		        Op:"fso.contents", Err:fmt.Errorf("%w: %d",
+			ErrTooLarge, len(bb)), Path:shortFP}
+	}
 	// println("LoadContents: Allocating!")
 	p.TypedRaw = new(CT.TypedRaw)
-	p.Raw = CT.Raw(string(bb))
-	// Take the hash and set the field.
-	// p.Hash = *new([16]byte)
-        p.Hash = md5.Sum(bb)
-
-	// TODO: Try to set CT.RawMT?
+	// Set the hashes, which were taken while reading,
+	// so they are of the bytes on disk, even if the 
+	// content is transcoded. 
+	p.setDigests(mh.Digests())
+	p.setBaseline()
+	// There is no CT.RawMT, so the MIME type goes in 
+	// the FSObject, and binary content is flagged in 
+	// the TypedRaw as Raw_type_BIN. It is sniffed (in
+	// applyEncoding) before any transcoding is done. 
+	s, transcoded, e := p.applyEncoding(bb, lim)
+	if e != nil {
+		p.TypedRaw = nil
+		return "", &fs.PathError{ 
+		       Op:"fso.contents:transcode", Err:e,Path:shortFP }
+	}
+	if transcoded {
+		p.Raw = CT.Raw(s)
+	} else {
+		p.Raw = CT.Raw(string(bb))
+	}
+	p.setMType()
 	
 	return p.TypedRaw.S(), nil
 }
-
@@ -35,6 +35,12 @@ func (p *FSObject) IsSymlink() bool {
      	return (0 != (p.FileInfo.Mode() & os.ModeSymlink))
 }
 
+// IsSpecial is true for a named pipe, socket, or device
+// (see [FSO_type]), which must not be opened for reading.
+func (p *FSObject) IsSpecial() bool {
+	return p.FileInfo != nil && FSO_typeOf(p.Mode()).IsSpecial()
+}
+
 func (p *FSObject) HasMultiHardlinks() bool {
 	return (p.NLinks > 1)
 }
@@ -61,15 +67,17 @@ func (p *FSObject) IsEmpty() bool {
 }
 
 func (p *FSObject) ModTime() time.Time {
-     return p.ModTime()
+     return p.FileInfo.ModTime()
 }
 
+// Info implements [fs.DirEntry]. 
 func (p *FSObject) Info() (fs.FileInfo, error) {
-     return p.Info()
+     return p.FileInfo, nil
 }
 
 // ResolveSymlinks will follow links until it finds
 // something else. NOTE that this can be a SECURITY HOLE. 
+// To follow links safely in a walk, see [WalkOptions.FollowSymlinks].
 func (p *FSObject) ResolveSymlinks() *FSObject {
 	if !p.IsSymlink() {
 		return nil
@@ -91,7 +99,6 @@ func (p *FSObject) ResolveSymlinks() *FSObject {
 		p = NewFSObject(newPath)
 		if p.HasError() {
 			panic(p.GetError())
-			return nil
 		}
 		// CHECK IT
 	}
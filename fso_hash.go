@@ -0,0 +1,265 @@
+package fileutils
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	S "strings"
+)
+
+// HashAlg names a content hash algorithm. Only
+// algorithms in the standard library are supported.
+type HashAlg string
+
+const (
+	HashMD5    HashAlg = "md5"
+	HashSHA256 HashAlg = "sha256"
+	HashSHA512 HashAlg = "sha512"
+	// HashCRC32C is CRC-32 using the Castagnoli polynomial.
+	// It is fast but it is not a cryptographic hash.
+	HashCRC32C HashAlg = "crc32c"
+)
+
+// DefaultHashAlgs is used when an [FSObject] has not had
+// its field [HashAlgs] set. The first entry is the primary
+// (i.e. selected) algorithm, as returned by [FSObject.Digest].
+//
+// Note that MD5 is always computed too, because it
+// is what [ctoken.TypedRaw.Hash] is defined to hold.
+var DefaultHashAlgs = []HashAlg{HashMD5}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// New returns a new [hash.Hash] for the algorithm.
+func (a HashAlg) New() (hash.Hash, error) {
+	switch a {
+	case HashMD5:
+		return md5.New(), nil
+	case HashSHA256:
+		return sha256.New(), nil
+	case HashSHA512:
+		return sha512.New(), nil
+	case HashCRC32C:
+		return crc32.New(crc32cTable), nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm: %q", string(a))
+}
+
+// ContentDigest is a hash value plus a record
+// of which algorithm it was produced by.
+type ContentDigest struct {
+	Alg HashAlg
+	Sum []byte
+}
+
+// Hex returns the digest as a lower case hex string,
+// which is what is used as a map value for comparisons.
+func (d ContentDigest) Hex() string {
+	return hex.EncodeToString(d.Sum)
+}
+
+// String implements [fmt.Stringer], as "alg:hex".
+func (d ContentDigest) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return string(d.Alg) + ":" + d.Hex()
+}
+
+// IsZero is true if no digest has been computed.
+func (d ContentDigest) IsZero() bool {
+	return d.Alg == "" || len(d.Sum) == 0
+}
+
+// ContentDigests is a set of digests of the same content,
+// in the order in which their algorithms were requested.
+type ContentDigests []ContentDigest
+
+// Get returns the digest for the algorithm, or a zero
+// value (check [ContentDigest.IsZero]) if there isn't one.
+func (dd ContentDigests) Get(alg HashAlg) ContentDigest {
+	for _, d := range dd {
+		if d.Alg == alg {
+			return d
+		}
+	}
+	return ContentDigest{}
+}
+
+// MultiHasher computes several hashes in one pass. It is
+// a [hash.Hash], so it can be used with [io.MultiWriter],
+// [io.TeeReader], or [NewHashingReader]; as a hash.Hash,
+// it is its first algorithm (see [MultiHasher.Sum]).
+type MultiHasher struct {
+	algs   []HashAlg
+	hashes []hash.Hash
+}
+
+// NewMultiHasher returns an error for an unknown algorithm.
+// Duplicate algorithms are silently dropped.
+func NewMultiHasher(algs ...HashAlg) (*MultiHasher, error) {
+	var p = new(MultiHasher)
+	for _, a := range algs {
+		if p.has(a) {
+			continue
+		}
+		h, e := a.New()
+		if e != nil {
+			return nil, e
+		}
+		p.algs = append(p.algs, a)
+		p.hashes = append(p.hashes, h)
+	}
+	return p, nil
+}
+
+func (p *MultiHasher) has(alg HashAlg) bool {
+	for _, a := range p.algs {
+		if a == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// Write implements [io.Writer]. It never returns an error.
+func (p *MultiHasher) Write(bb []byte) (int, error) {
+	for _, h := range p.hashes {
+		h.Write(bb)
+	}
+	return len(bb), nil
+}
+
+// Sum appends the sum of the first algorithm to b.
+// Use [MultiHasher.Digests] to get all of the sums.
+func (p *MultiHasher) Sum(b []byte) []byte {
+	if len(p.hashes) == 0 {
+		return b
+	}
+	return p.hashes[0].Sum(b)
+}
+
+// Reset resets every hash.
+func (p *MultiHasher) Reset() {
+	for _, h := range p.hashes {
+		h.Reset()
+	}
+}
+
+// Size is that of the first algorithm.
+func (p *MultiHasher) Size() int {
+	if len(p.hashes) == 0 {
+		return 0
+	}
+	return p.hashes[0].Size()
+}
+
+// BlockSize is that of the first algorithm.
+func (p *MultiHasher) BlockSize() int {
+	if len(p.hashes) == 0 {
+		return 1
+	}
+	return p.hashes[0].BlockSize()
+}
+
+// Digests returns the current sums.
+func (p *MultiHasher) Digests() ContentDigests {
+	var dd = make(ContentDigests, len(p.algs))
+	for i, a := range p.algs {
+		dd[i] = ContentDigest{Alg: a, Sum: p.hashes[i].Sum(nil)}
+	}
+	return dd
+}
+
+// HashReader reads r to EOF and returns its digests
+// for every requested algorithm, plus the byte count.
+func HashReader(r io.Reader, algs ...HashAlg) (ContentDigests, int64, error) {
+	mh, e := NewMultiHasher(algs...)
+	if e != nil {
+		return nil, 0, e
+	}
+	n, e := io.Copy(mh, r)
+	if e != nil {
+		return nil, n, e
+	}
+	return mh.Digests(), n, nil
+}
+
+// hashAlgs returns the algorithms to use for this
+// FSObject, always including MD5 (see [DefaultHashAlgs]).
+func (p *FSObject) hashAlgs() []HashAlg {
+	var algs = p.HashAlgs
+	if len(algs) == 0 {
+		algs = DefaultHashAlgs
+	}
+	return append(append([]HashAlg{}, algs...), HashMD5)
+}
+
+// newHasher returns a MultiHasher for this FSObject.
+func (p *FSObject) newHasher() (*MultiHasher, error) {
+	return NewMultiHasher(p.hashAlgs()...)
+}
+
+// setDigests stores the digests, and also
+// the md5 in the embedded [ctoken.TypedRaw].
+func (p *FSObject) setDigests(dd ContentDigests) {
+	p.Digests = dd
+	if p.TypedRaw != nil {
+		copy(p.Hash[:], dd.Get(HashMD5).Sum)
+	}
+}
+
+// Digest returns the digest for the primary (selected)
+// algorithm, which is the first entry in [HashAlgs] (or
+// [DefaultHashAlgs]). It is a zero value (check with
+// [ContentDigest.IsZero]) if the content has not been
+// loaded or streamed yet.
+// .
+func (p *FSObject) Digest() ContentDigest {
+	var algs = p.hashAlgs()
+	return p.Digests.Get(algs[0])
+}
+
+// DigestOf returns the digest for any algorithm. If it was
+// not computed when the content was loaded, it is computed
+// now, and is then remembered. Like the digests that are taken
+// while loading, it is of the file's bytes as they are stored
+// (before any transcoding, see [EncodingInfo]), so the file is
+// read again (see [OpenContent]) rather than the content in
+// memory being used - unless that content has unsaved changes
+// (see [SetContents]), in which case it is of that content.
+// An object that is not a file has no digest.
+// .
+func (p *FSObject) DigestOf(alg HashAlg) (ContentDigest, error) {
+	if d := p.Digests.Get(alg); !d.IsZero() {
+		return d, nil
+	}
+	var r io.Reader
+	if p.FPs.ContentInMemoryIsDirty && p.TypedRaw != nil {
+		r = S.NewReader(p.TypedRaw.S())
+	} else {
+		if !p.inMemory && (p.FileInfo == nil || !p.IsFile()) {
+			return ContentDigest{}, nil
+		}
+		rc, e := p.OpenContent(NoContentLimits)
+		if e != nil {
+			return ContentDigest{}, e
+		}
+		defer rc.Close()
+		r = rc
+	}
+	dd, _, e := HashReader(r, alg)
+	if e != nil {
+		return ContentDigest{}, &fs.PathError{Op: "fso.digestof",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	var d = dd.Get(alg)
+	p.Digests = append(p.Digests, d)
+	return d, nil
+}
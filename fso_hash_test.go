@@ -0,0 +1,128 @@
+package fileutils
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestHashReader(t *testing.T) {
+	var sha512abc = sha512.Sum512([]byte("abc"))
+	var tests = []struct {
+		alg  HashAlg
+		want string
+	}{
+		{HashMD5, "900150983cd24fb0d6963f7d28e17f72"},
+		{HashSHA256, "ba7816bf8f01cfea414140de5dae2223" +
+			"b00361a396177a9cb410ff61f20015ad"},
+		{HashSHA512, hex.EncodeToString(sha512abc[:])},
+		{HashCRC32C, "364b3fb7"},
+	}
+	var algs []HashAlg
+	for _, tc := range tests {
+		algs = append(algs, tc.alg)
+	}
+	// All at once, plus a duplicate that is dropped.
+	dd, n, e := HashReader(strings.NewReader("abc"), append(algs, HashMD5)...)
+	if e != nil || n != 3 {
+		t.Fatalf("got %d, %v", n, e)
+	}
+	if len(dd) != len(tests) {
+		t.Errorf("got %d digests, want %d", len(dd), len(tests))
+	}
+	for i, tc := range tests {
+		if dd[i].Alg != tc.alg {
+			t.Errorf("digest %d is %s, want %s", i, dd[i].Alg, tc.alg)
+		}
+		if got := dd.Get(tc.alg).Hex(); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.alg, got, tc.want)
+		}
+	}
+	if !dd.Get("whirlpool").IsZero() {
+		t.Error("Get of a missing algorithm is not zero")
+	}
+}
+
+func TestHashAlgUnknown(t *testing.T) {
+	if _, e := HashAlg("sha3").New(); e == nil {
+		t.Error("HashAlg.New: no error for an unknown algorithm")
+	}
+	if _, e := NewMultiHasher(HashMD5, "nope"); e == nil {
+		t.Error("NewMultiHasher: no error for an unknown algorithm")
+	}
+}
+
+func TestMultiHasherIsHash(t *testing.T) {
+	mh, e := NewMultiHasher(HashSHA256, HashMD5)
+	if e != nil {
+		t.Fatal(e)
+	}
+	mh.Write([]byte("junk"))
+	mh.Reset()
+	mh.Write([]byte("abc"))
+	if mh.Size() != 32 || mh.BlockSize() != 64 {
+		t.Errorf("size %d, block size %d", mh.Size(), mh.BlockSize())
+	}
+	if got := hex.EncodeToString(mh.Sum(nil)); got !=
+		mh.Digests().Get(HashSHA256).Hex() {
+		t.Errorf("Sum is not the first algorithm: %s", got)
+	}
+	if got := mh.Digests().Get(HashMD5).Hex(); got !=
+		"900150983cd24fb0d6963f7d28e17f72" {
+		t.Errorf("md5 after Reset: %s", got)
+	}
+}
+
+func TestFSObjectDigests(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "abc")
+	var p = NewFSObject(path)
+	p.HashAlgs = []HashAlg{HashSHA256}
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	// The primary is the first of HashAlgs, and MD5 is always there.
+	if d := p.Digest(); d.Alg != HashSHA256 || !strings.HasPrefix(d.Hex(), "ba7816bf") {
+		t.Errorf("Digest: got %s", d)
+	}
+	if d := p.Digests.Get(HashMD5); d.IsZero() {
+		t.Error("no md5")
+	}
+	// Not computed on load, so computed on demand, and kept.
+	d, e := p.DigestOf(HashCRC32C)
+	if e != nil || d.Hex() != "364b3fb7" {
+		t.Errorf("DigestOf: got %s, %v", d, e)
+	}
+	if p.Digests.Get(HashCRC32C).IsZero() {
+		t.Error("DigestOf did not keep the digest")
+	}
+	if s := d.String(); s != "crc32c:364b3fb7" {
+		t.Errorf("String: got %q", s)
+	}
+}
+
+// TestDigestOfFileBytes checks that a digest taken on demand is of
+// the bytes on disk, like one taken while loading, even if the
+// content was transcoded, unless the content has unsaved changes.
+func TestDigestOfFileBytes(t *testing.T) {
+	var latin1 = "caf\xe9 au lait\n"
+	var path = writeTestFile(t, t.TempDir(), "f.txt", latin1)
+	var p = NewFSObject(path)
+	var lim = DefaultContentLimits
+	lim.TranscodeToUTF8 = true
+	if s, e := p.ContentsLimited(lim); e != nil || s != "café au lait\n" {
+		t.Fatalf("got %q, %v", s, e)
+	}
+	var want = sha256.Sum256([]byte(latin1))
+	if d, e := p.DigestOf(HashSHA256); e != nil || d.Hex() != hex.EncodeToString(want[:]) {
+		t.Errorf("transcoded: got %s, %v", d, e)
+	}
+	p.SetContents("abc")
+	if d, e := p.DigestOf(HashSHA512); e != nil || !strings.HasPrefix(d.Hex(), "ddaf35a1") {
+		t.Errorf("dirty: got %s, %v", d, e)
+	}
+	if d, e := NewFSObject(t.TempDir()).DigestOf(HashMD5); e != nil || !d.IsZero() {
+		t.Errorf("dir: got %s, %v", d, e)
+	}
+}
@@ -0,0 +1,237 @@
+package fileutils
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// errJSON is the JSON form of the error in an [Errer]. The
+// error itself cannot be rebuilt, so a decoded error is just
+// the message, but a [fs.PathError] keeps its Op and Path.
+type errJSON struct {
+	Msg  string `json:"msg"`
+	Op   string `json:"op,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+func (p *Errer) toJSON() *errJSON {
+	if p.Err == nil {
+		return nil
+	}
+	// A PathError with a nil Err cannot call Error().
+	if pPE, ok := p.Err.(*fs.PathError); ok && pPE != nil {
+		var pEJ = &errJSON{Op: pPE.Op, Path: pPE.Path}
+		if pPE.Err != nil {
+			pEJ.Msg = pPE.Err.Error()
+		}
+		return pEJ
+	}
+	return &errJSON{Msg: p.Err.Error()}
+}
+
+func (p *Errer) fromJSON(pEJ *errJSON) {
+	if pEJ == nil {
+		p.ClearError()
+		return
+	}
+	var e = errors.New(pEJ.Msg)
+	if pEJ.Op != "" || pEJ.Path != "" {
+		p.SetError(&fs.PathError{Op: pEJ.Op, Path: pEJ.Path, Err: e})
+		return
+	}
+	p.SetError(e)
+}
+
+// filepathsJSON is the JSON form of [Filepaths].
+type filepathsJSON struct {
+	RelFP     string   `json:"rel,omitempty"`
+	AbsFP     string   `json:"abs,omitempty"`
+	ShortFP   string   `json:"short,omitempty"`
+	GotAbs    bool     `json:"gotAbs,omitempty"`
+	NotExist  bool     `json:"doesNotExist,omitempty"`
+	IsDir     bool     `json:"isDir,omitempty"`
+	IsFile    bool     `json:"isFile,omitempty"`
+	IsSymlink bool     `json:"isSymlink,omitempty"`
+	IsDirlike bool     `json:"isDirlike,omitempty"`
+	IsLocal   bool     `json:"isLocal,omitempty"`
+	IsValid   bool     `json:"isValid,omitempty"`
+	Dirty     bool     `json:"dirty,omitempty"`
+	Err       *errJSON `json:"error,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler]. It is needed
+// because the embedded [Errer] holds an error interface.
+// It has a value receiver, so that it is used for a field.
+func (p Filepaths) MarshalJSON() ([]byte, error) {
+	return json.Marshal(filepathsJSON{
+		RelFP: p.RelFP, AbsFP: p.AbsFP, ShortFP: p.ShortFP,
+		GotAbs: p.GotAbs, NotExist: p.DoesNotExist,
+		IsDir: p.IsDir, IsFile: p.IsFile, IsSymlink: p.IsSymlink,
+		IsDirlike: p.IsDirlike, IsLocal: p.IsLocal,
+		IsValid: p.IsValid, Dirty: p.ContentInMemoryIsDirty,
+		Err: p.Errer.toJSON(),
+	})
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. Flag
+// [Filepaths.ContentInMemoryIsDirty] is not restored,
+// because the content it refers to is not encoded.
+func (p *Filepaths) UnmarshalJSON(bb []byte) error {
+	var fj filepathsJSON
+	if e := json.Unmarshal(bb, &fj); e != nil {
+		return e
+	}
+	*p = Filepaths{
+		RelFP: fj.RelFP, AbsFP: fj.AbsFP, ShortFP: fj.ShortFP,
+		GotAbs: fj.GotAbs, DoesNotExist: fj.NotExist,
+		IsDir: fj.IsDir, IsFile: fj.IsFile, IsSymlink: fj.IsSymlink,
+		IsDirlike: fj.IsDirlike, IsLocal: fj.IsLocal,
+		IsValid: fj.IsValid,
+	}
+	p.creatPath = fj.RelFP
+	if fj.GotAbs {
+		p.creatPath = fj.AbsFP
+	}
+	p.Errer.fromJSON(fj.Err)
+	return nil
+}
+
+// digestJSON is the JSON form of a [ContentDigest].
+type digestJSON struct {
+	Alg HashAlg `json:"alg"`
+	Sum string  `json:"sum"`
+}
+
+// fsObjectJSON is the JSON form of an [FSObject]. The
+// fs.FileInfo is flattened into Name...ModTime, which
+// are all omitted if the FSObject has no FileInfo.
+type fsObjectJSON struct {
+	FPs      Filepaths     `json:"paths"`
+	FSO_type FSO_type      `json:"type,omitempty"`
+	Name     string        `json:"name,omitempty"`
+	Mode     *fs.FileMode  `json:"mode,omitempty"`
+	ModeS    string        `json:"modeString,omitempty"`
+	Perms    string        `json:"perms,omitempty"`
+	Size     int64         `json:"size,omitempty"`
+	ModTime  *time.Time    `json:"modTime,omitempty"`
+	Inode    int           `json:"inode,omitempty"`
+	NLinks   int           `json:"nlinks,omitempty"`
+	Stat     *StatInfo     `json:"stat,omitempty"`
+	Hash     string        `json:"hash,omitempty"`
+	HashAlgs []HashAlg     `json:"hashAlgs,omitempty"`
+	Digests  []digestJSON  `json:"digests,omitempty"`
+	MimeType string        `json:"mimeType,omitempty"`
+	MType    MType         `json:"mtype,omitempty"`
+	Encoding *EncodingInfo `json:"encoding,omitempty"`
+	Mismatch bool          `json:"mimeExtMismatch,omitempty"`
+	InMemory bool          `json:"inMemory,omitempty"`
+	Err      *errJSON      `json:"error,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler], which is necessary
+// because the embedded [fs.FileInfo] is an interface. It covers
+// the paths, the type, the FileInfo, the [StatInfo], the hashes,
+// the MIME type, [MType] and encoding, and the error (as a
+// string), but NOT the content.
+//
+// An object in an [fs.FS] is encoded like any other, but the
+// fs.FS itself is lost, so the decoded object cannot reload its
+// content. The same is true for an object that lives in memory.
+//
+// It has a value receiver, so that it is used for an FSObject
+// that is not addressable (e.g. a value in a map).
+// .
+func (p FSObject) MarshalJSON() ([]byte, error) {
+	var oj = fsObjectJSON{FPs: p.FPs, FSO_type: p.FSO_type,
+		Perms: p.Perms, Inode: p.Inode, NLinks: p.NLinks,
+		HashAlgs: p.HashAlgs, InMemory: p.inMemory,
+		MimeType: p.MimeType, Mismatch: p.MimeExtMismatch,
+		MType: p.MType, Err: p.Errer.toJSON()}
+	if p.FileInfo != nil {
+		var mode = p.Mode()
+		var mt = p.ModTime()
+		oj.Name = p.Name()
+		oj.Mode = &mode
+		oj.ModeS = mode.String()
+		oj.Size = p.Size()
+		oj.ModTime = &mt
+	}
+	if p.Encoding.Encoding != EncUnknown {
+		var ei = p.Encoding
+		oj.Encoding = &ei
+	}
+	if p.Stat.Valid {
+		var st = p.Stat
+		oj.Stat = &st
+	}
+	for _, d := range p.Digests {
+		oj.Digests = append(oj.Digests,
+			digestJSON{Alg: d.Alg, Sum: d.Hex()})
+	}
+	if d := p.Digests.Get(HashMD5); !d.IsZero() {
+		oj.Hash = d.Hex()
+	} else if p.TypedRaw != nil && p.Hash != [16]byte{} {
+		oj.Hash = hex.EncodeToString(p.Hash[:])
+	}
+	return json.Marshal(oj)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. The FSObject gets
+// a synthetic [fs.FileInfo], so that it answers IsDir, IsFile,
+// Size, Mode, ModTime, etc., but Sys() is nil. The content is
+// not loaded; a call to [Contents] will (if there is an AbsFP)
+// check the file on disk (see [Refresh]) and then load it.
+//
+// Since the content is not encoded, neither are the dirty flag
+// and the in-memory state restored: the decoded object is of the
+// file on disk (if any), and [Save] refuses it (see [ErrNoContent])
+// until its content is loaded or set.
+// .
+func (p *FSObject) UnmarshalJSON(bb []byte) error {
+	var oj fsObjectJSON
+	if e := json.Unmarshal(bb, &oj); e != nil {
+		return e
+	}
+	*p = FSObject{FPs: oj.FPs, FSO_type: oj.FSO_type,
+		Perms: oj.Perms, Inode: oj.Inode, NLinks: oj.NLinks,
+		HashAlgs: oj.HashAlgs,
+		MimeType: oj.MimeType, MimeExtMismatch: oj.Mismatch,
+		MType: oj.MType}
+	if oj.Mode != nil {
+		var sfi = &synthFileInfo{name: oj.Name,
+			size: oj.Size, mode: *oj.Mode}
+		if oj.ModTime != nil {
+			sfi.modTime = *oj.ModTime
+		}
+		p.FileInfo = sfi
+		if p.FSO_type == "" {
+			p.FSO_type = p.FSObjectType()
+		}
+	}
+	if oj.Stat != nil {
+		p.Stat = *oj.Stat
+	}
+	if oj.Encoding != nil {
+		p.Encoding = *oj.Encoding
+	}
+	for _, dj := range oj.Digests {
+		sum, e := hex.DecodeString(dj.Sum)
+		if e != nil {
+			return fmt.Errorf("fsobject.unmarshaljson: digest %s: %w", dj.Alg, e)
+		}
+		p.Digests = append(p.Digests, ContentDigest{Alg: dj.Alg, Sum: sum})
+	}
+	if oj.Hash != "" && p.Digests.Get(HashMD5).IsZero() {
+		sum, e := hex.DecodeString(oj.Hash)
+		if e != nil {
+			return fmt.Errorf("fsobject.unmarshaljson: hash: %w", e)
+		}
+		p.Digests = append(p.Digests, ContentDigest{Alg: HashMD5, Sum: sum})
+	}
+	p.Errer.fromJSON(oj.Err)
+	return nil
+}
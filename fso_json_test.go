@@ -0,0 +1,154 @@
+package fileutils
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestFSObjectJSONRoundTrip checks that what is encoded comes back.
+func TestFSObjectJSONRoundTrip(t *testing.T) {
+	var dir = t.TempDir()
+	var path = writeTestFile(t, dir, "doc.xml",
+		"<?xml version=\"1.0\"?>\n<topic id=\"t\"><title>T</title></topic>\n")
+	var p = NewFSObject(path)
+	p.HashAlgs = []HashAlg{HashSHA256}
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	bb, e := json.Marshal(p)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var q FSObject
+	if e = json.Unmarshal(bb, &q); e != nil {
+		t.Fatal(e)
+	}
+	if q.FPs.AbsFP != p.FPs.AbsFP || q.FPs.RelFP != p.FPs.RelFP ||
+		q.FPs.IsFile != p.FPs.IsFile {
+		t.Errorf("paths: got %+v", q.FPs)
+	}
+	if q.FSO_type != p.FSO_type || q.Name() != p.Name() || q.Size() != p.Size() ||
+		q.Mode() != p.Mode() || !q.ModTime().Equal(p.ModTime()) || !q.IsFile() {
+		t.Errorf("file info: got %s %q %d %v %v", q.FSO_type,
+			q.Name(), q.Size(), q.Mode(), q.ModTime())
+	}
+	if q.Stat.Ino != p.Stat.Ino || q.Stat.Valid != p.Stat.Valid ||
+		q.Stat.Uid != p.Stat.Uid {
+		t.Errorf("stat: got %+v", q.Stat)
+	}
+	if q.Digest().Hex() != p.Digest().Hex() ||
+		q.Digests.Get(HashMD5).Hex() != p.Digests.Get(HashMD5).Hex() {
+		t.Errorf("digests: got %v", q.Digests)
+	}
+	if q.MimeType != p.MimeType || q.MType != p.MType {
+		t.Errorf("types: got %q %q, want %q %q",
+			q.MimeType, q.MType, p.MimeType, p.MType)
+	}
+	// The content is not encoded, but it can be reloaded.
+	if q.TypedRaw != nil {
+		t.Error("content was decoded")
+	}
+	if s, e := q.Contents(); e != nil || s != p.TypedRaw.S() {
+		t.Errorf("reload: %q, %v", s, e)
+	}
+}
+
+// TestFSObjectJSONDirty checks that an object with unsaved changes
+// does not come back dirty without its content, so that saving it
+// cannot truncate the file.
+func TestFSObjectJSONDirty(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "orig\n")
+	var p = NewFSObject(path)
+	p.SetContents("mine\n")
+	bb, e := json.Marshal(p)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var q FSObject
+	if e = json.Unmarshal(bb, &q); e != nil {
+		t.Fatal(e)
+	}
+	if q.FPs.ContentInMemoryIsDirty {
+		t.Error("decoded as dirty")
+	}
+	if e = q.Save(); !errors.Is(e, ErrNoContent) {
+		t.Errorf("Save: got %v, want ErrNoContent", e)
+	}
+	if bb, _ := os.ReadFile(path); string(bb) != "orig\n" {
+		t.Errorf("on disk: %q", bb)
+	}
+	// Once it is loaded, it can be changed and saved.
+	if s, e := q.Contents(); e != nil || s != "orig\n" {
+		t.Fatalf("Contents: %q, %v", s, e)
+	}
+	q.SetContents("new\n")
+	if e = q.Save(); e != nil {
+		t.Error(e)
+	}
+	// Nor does an in-memory object come back as one.
+	p = NewFSObjectFromContent("mem\n", "")
+	bb, _ = json.Marshal(p)
+	q = FSObject{}
+	if e = json.Unmarshal(bb, &q); e != nil {
+		t.Fatal(e)
+	}
+	if q.IsInMemory() {
+		t.Error("decoded as in memory")
+	}
+	if s, e := q.Contents(); e == nil {
+		t.Errorf("in memory: got %q", s)
+	}
+}
+
+func TestFSObjectJSONError(t *testing.T) {
+	var p = NewFSObject(t.TempDir() + "/missing")
+	if !p.HasError() {
+		t.Fatal("no error")
+	}
+	bb, e := json.Marshal(p)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var q FSObject
+	if e = json.Unmarshal(bb, &q); e != nil {
+		t.Fatal(e)
+	}
+	// A PathError keeps its Op and Path.
+	var pe, qe *fs.PathError
+	if !errors.As(p.GetError(), &pe) || !errors.As(q.GetError(), &qe) ||
+		qe.Op != pe.Op || qe.Path != pe.Path {
+		t.Errorf("got %#v, want %#v", q.GetError(), p.GetError())
+	}
+	if q.FileInfo != nil {
+		t.Errorf("FileInfo %v", q.FileInfo)
+	}
+}
+
+// TestFSObjectJSONValue checks that a value (not a pointer),
+// such as an element of a slice from [ReadDir], is encoded
+// with MarshalJSON.
+func TestFSObjectJSONValue(t *testing.T) {
+	var dir = t.TempDir()
+	writeTestFile(t, dir, "a.txt", "a")
+	var m = map[string]FSObject{"a": *NewFSObject(dir + "/a.txt")}
+	bb, e := json.Marshal(m)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !strings.Contains(string(bb), `"paths":`) ||
+		strings.Contains(string(bb), `"FileInfo"`) {
+		t.Errorf("got %s", bb)
+	}
+}
+
+func TestFSObjectJSONBadDigest(t *testing.T) {
+	var q FSObject
+	var e = json.Unmarshal([]byte(`{"paths":{},"digests":[{"alg":"md5","sum":"xyz"}]}`), &q)
+	if e == nil {
+		t.Error("no error for a bad digest")
+	}
+}
@@ -0,0 +1,172 @@
+package fileutils
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+
+	CT "github.com/fbaube/ctoken"
+)
+
+// ErrMmapUnsupported is returned by [MapFile] on
+// platforms where memory-mapping is not implemented.
+var ErrMmapUnsupported = errors.New("mmap not supported on this platform")
+
+// MappedContent is a file's content that is either memory-mapped
+// read-only (see [IsMapped]) or, as a fallback, read into memory.
+// Either way the caller sees a []byte, which MUST NOT be modified,
+// and which MUST NOT be used after [Close] has been called.
+//
+// Note that if a mapped file is truncated by another process
+// while it is mapped, access to the missing pages will crash
+// the program (SIGBUS), so mapping is best kept for files that
+// are not being actively written.
+// .
+type MappedContent struct {
+	bb     []byte
+	mapped bool
+}
+
+// Bytes returns the content. It is valid until [Close].
+func (p *MappedContent) Bytes() []byte {
+	if p == nil {
+		return nil
+	}
+	return p.bb
+}
+
+// Len is the content length in bytes.
+func (p *MappedContent) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.bb)
+}
+
+// IsMapped is false if the fallback (reading) was used.
+func (p *MappedContent) IsMapped() bool {
+	return p != nil && p.mapped
+}
+
+// Close unmaps the content (if it was mapped). It is safe to
+// call more than once. After Close, Bytes returns nil.
+func (p *MappedContent) Close() error {
+	if p == nil || p.bb == nil {
+		return nil
+	}
+	var e error
+	if p.mapped {
+		e = munmap(p.bb)
+	}
+	p.bb = nil
+	p.mapped = false
+	return e
+}
+
+// MapFile memory-maps the file at the path, read-only. It does not
+// fall back to reading the file; for that, use [FSObject.MapContents].
+// An empty file cannot be mapped, and gets an empty MappedContent.
+// .
+func MapFile(path string) (*MappedContent, error) {
+	pF, fi, e := openRegular(path)
+	if e != nil {
+		return nil, &fs.PathError{Op: "fu.mapfile", Path: path, Err: e}
+	}
+	defer pF.Close()
+	if fi.Size() == 0 {
+		return &MappedContent{bb: []byte{}}, nil
+	}
+	if int64(int(fi.Size())) != fi.Size() {
+		return nil, &fs.PathError{Op: "fu.mapfile", Path: path, Err: ErrTooLarge}
+	}
+	bb, e := mmapFile(pF, int(fi.Size()))
+	if e != nil {
+		return nil, &fs.PathError{Op: "fu.mapfile:mmap", Path: path, Err: e}
+	}
+	return &MappedContent{bb: bb, mapped: true}, nil
+}
+
+// MapContents loads the file's content read-only, preferably by
+// memory-mapping it, and if that fails, by reading it. It applies
+// the [ContentLimits], but it does not store the content in field
+// [TypedRaw]. This is the mapping-backed way to get at a file's
+// content (if it is mapped, it is not copied onto the heap), and
+// the caller must release it with [MappedContent.Close]. By contrast,
+// [ContentLimits.MmapAbove] only maps a file while copying it
+// into TypedRaw.
+// .
+func (p *FSObject) MapContents(lim ContentLimits) (*MappedContent, error) {
+	rc, e := p.OpenContent(lim)
+	if e != nil {
+		return nil, e
+	}
+	// OpenContent has done the checks,
+	// and now MapFile does its own open.
+	rc.Close()
+	var pMC *MappedContent
+	e = ErrMmapUnsupported
+	// Only a file on the host file system can be mapped
+	if p.fsys == nil && !p.inMemory {
+		pMC, e = MapFile(p.FPs.AbsFP)
+	}
+	if e == nil {
+		if lim.exceeds(int64(pMC.Len())) {
+			pMC.Close()
+			return nil, &fs.PathError{Op: "fso.mapcontents",
+				Path: p.FPs.ShortFP, Err: ErrTooLarge}
+		}
+		return pMC, nil
+	}
+	// Fall back to the plain read path
+	rc, e = p.OpenContent(lim)
+	if e != nil {
+		return nil, e
+	}
+	defer rc.Close()
+	bb, e := io.ReadAll(rc)
+	if e != nil {
+		return nil, &fs.PathError{Op: "fso.mapcontents:io.readall",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	return &MappedContent{bb: bb}, nil
+}
+
+// loadMapped is used by [ContentsLimited] for files above the
+// threshold [ContentLimits.MmapAbove]. The file is read thru a
+// mapping, so the content is in memory once (in the Raw string)
+// rather than twice (in a read buffer, and then in the string),
+// but it IS in memory: it has to be copied, because a string
+// must not change, and must stay valid, and a mapping of a file
+// does neither (if the file is written or truncated, or when it
+// is unmapped). The mapping is released before returning.
+// .
+func (p *FSObject) loadMapped(lim ContentLimits) (string, error) {
+	pMC, e := p.MapContents(lim)
+	if e != nil {
+		return "", e
+	}
+	defer pMC.Close()
+	mh, e := p.newHasher()
+	if e != nil {
+		return "", &fs.PathError{Op: "fso.contents:hash",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	var bb = pMC.Bytes()
+	mh.Write(bb)
+	p.TypedRaw = new(CT.TypedRaw)
+	p.setDigests(mh.Digests())
+	p.setBaseline()
+	s, transcoded, e := p.applyEncoding(bb, lim)
+	if e != nil {
+		p.TypedRaw = nil
+		return "", &fs.PathError{Op: "fso.contents:transcode",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	if transcoded {
+		p.Raw = CT.Raw(s)
+	} else {
+		p.Raw = CT.Raw(string(bb))
+	}
+	p.setMType()
+	return p.TypedRaw.S(), nil
+}
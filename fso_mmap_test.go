@@ -0,0 +1,94 @@
+package fileutils
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMapFile(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("mmap is not implemented on " + runtime.GOOS)
+	}
+	var dir = t.TempDir()
+	var content = strings.Repeat("mapped ", 2000)
+	pMC, e := MapFile(writeTestFile(t, dir, "f.txt", content))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !pMC.IsMapped() || pMC.Len() != len(content) ||
+		string(pMC.Bytes()) != content {
+		t.Errorf("mapped %v, %d bytes", pMC.IsMapped(), pMC.Len())
+	}
+	if e = pMC.Close(); e != nil {
+		t.Error(e)
+	}
+	if e = pMC.Close(); e != nil || pMC.Bytes() != nil || pMC.IsMapped() {
+		t.Errorf("second Close: %v", e)
+	}
+
+	pMC, e = MapFile(writeTestFile(t, dir, "empty", ""))
+	if e != nil || pMC.Len() != 0 || pMC.IsMapped() {
+		t.Errorf("empty file: %v", e)
+	}
+	if _, e = MapFile(dir); !errors.Is(e, ErrNotAFile) {
+		t.Errorf("dir: got %v, want ErrNotAFile", e)
+	}
+}
+
+func TestMapContents(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "0123456789")
+	var p = NewFSObject(path)
+	if _, e := p.MapContents(ContentLimits{MaxSize: 9}); !errors.Is(e, ErrTooLarge) {
+		t.Errorf("limit: got %v, want ErrTooLarge", e)
+	}
+	pMC, e := p.MapContents(NoContentLimits)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer pMC.Close()
+	if string(pMC.Bytes()) != "0123456789" {
+		t.Errorf("got %q", pMC.Bytes())
+	}
+	if p.TypedRaw != nil {
+		t.Error("MapContents stored the content")
+	}
+	// In memory, so it falls back to reading.
+	pMC2, e := NewFSObjectFromContent("in memory", "").MapContents(NoContentLimits)
+	if e != nil || pMC2.IsMapped() || string(pMC2.Bytes()) != "in memory" {
+		t.Errorf("in memory: got %q, %v", pMC2.Bytes(), e)
+	}
+}
+
+// TestContentsMapped checks that content loaded thru a mapping is
+// the same as content that is read, and that it does not alias the
+// mapping, so that it does not change when the file is rewritten.
+func TestContentsMapped(t *testing.T) {
+	var content = strings.Repeat("The rain in Spain.\n", 500)
+	var path = writeTestFile(t, t.TempDir(), "f.txt", content)
+	var pRead, pMapped = NewFSObject(path), NewFSObject(path)
+	sRead, e := pRead.Contents()
+	if e != nil {
+		t.Fatal(e)
+	}
+	var lim = DefaultContentLimits
+	lim.MmapAbove = 1
+	sMapped, e := pMapped.ContentsLimited(lim)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if sMapped != sRead || sMapped != content {
+		t.Errorf("mapped content differs")
+	}
+	if pMapped.Digest().Hex() != pRead.Digest().Hex() {
+		t.Errorf("digest: mapped %s, read %s", pMapped.Digest(), pRead.Digest())
+	}
+	if e = os.WriteFile(path, []byte(strings.ToUpper(content)), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if pMapped.TypedRaw.S() != content {
+		t.Error("content changed when the file was rewritten")
+	}
+}
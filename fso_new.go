@@ -5,7 +5,6 @@ import (
 	"os"
 	// "fmt"
 	"errors"
-	"syscall"
 	// FP "path/filepath"
 )
 
@@ -27,8 +26,9 @@ import (
 // be empty and invalid, except (maybe!) embedded struct
 // [FPs] (a [Filepaths]). 
 //
-// Note that passing in an empty path is not OK; instead
-// create (by hand) a new pathless FSObject from the content.
+// Note that passing in an empty path is not OK; instead create 
+// a new pathless FSObject from the content, using func 
+// [NewFSObjectFromContent].
 //
 // If you wish to create a blank FSObject that has no path,
 // simply use a nil ptr instead of calling this func. 
@@ -48,7 +48,7 @@ func NewFSObject(anFP string) *FSObject {
 	
 	if pFPs.HasError() {
 	   pPE.Op = "newFPs"
-	   pPE.Err = e
+	   pPE.Err = pFPs.GetError()
 	   pEmpty.SetError(pPE)
 	   return pEmpty
 	}
@@ -63,7 +63,7 @@ func NewFSObject(anFP string) *FSObject {
 	//  Now we can proceed
 	// --------------------	
 	var fi fs.FileInfo
-	fi, e = os.Lstat(pFPs.AbsFP)
+	fi, e = lstatHost(pFPs.AbsFP)
 	// But maybe the path does not exist !
 	//  We mark this as an error. 
 	if fi == nil || e != nil {
@@ -81,6 +81,7 @@ func NewFSObject(anFP string) *FSObject {
 	pFSI = new(FSObject)
 	pFSI.FPs = *pFPs
 	pFSI.FileInfo = fi
+	pFSI.FSO_type = pFSI.FSObjectType()
 	// pFSI.Exists = true
 	// Also set the time of access
 	// pFSI.LastCheckTime = time.Now()
@@ -91,29 +92,20 @@ func NewFSObject(anFP string) *FSObject {
 	   pFSI.FPs.EnsurePathSepSuffixes()
 	   }
 	// Now we try to fetch the fields that might be OS-dependent
-	s, ok := fi.Sys().(*syscall.Stat_t)
-        if !ok {
+	if e := pFSI.setStatInfo(fi); e != nil {
 	       // Non-fatal error
 	       // FIXME: This might be difficult to debug 
-	       pe := &fs.PathError{ Op:"fs.fileinfo.sys", 
-	       	      Path:pFSI.FPs.AbsFP, Err:errors.New(
-		     "cannot convert Stat.Sys() to syscall.Stat_t " +
-		     "(should NOT be fatal!)") }
-		pFSI.SetError(pe)
-		// Do not return, from here 
+	       pFSI.SetError(&fs.PathError{ Op:"fs.fileinfo.sys", 
+	       	      Path:pFSI.FPs.AbsFP, Err:e })
+	       // Do not return, from here 
 	       }
-        var nlinks int
-        nlinks = int(s.Nlink)
-        if nlinks > 1 && (fi.Mode()&fs.ModeSymlink == 0) {
-                // The index number of this file's inode:
-                pFSI.Inode  = int(s.Ino)
-                pFSI.NLinks = int(s.Nlink)
-        }
-        // TODO: FILE PERMS
-        // inode, nlinks int64
 
 	pFSI.Perms = permString(fi)
-	
+	// Record the file as it is now, so that Save can 
+	// tell if it is changed before the content is loaded.
+	if fi.Mode().IsRegular() {
+	   pFSI.setBaseline()
+	   }
         return pFSI 
 }
 
@@ -125,12 +117,6 @@ func permStr(i int) string {
      return s
 }
 
-/*
-func NewFSObjectFromContent(s string) (*FSObject, error) {
-     return nil, nil
-}
-*/
-
 // ==================
 
 // TODO: Maybe generalize it to have the funcs MustNoContent & MustBeLeaf 
@@ -166,10 +152,6 @@ Delete the field from the struct
 // FSObjectType examines the embedded [os.FileInfo] 
 // to return a value in the set of FSO_type_* 
 func (p *FSObject) FSObjectType() FSO_type {
-     if p.Mode().IsRegular() { return FSO_type_FILE } 
-     if p.IsDir() { return FSO_type_DIRR } 
-     if 0 != (p.Mode() & fs.ModeSymlink) {
-     	      return FSO_type_SYML } 
-     return FSO_type_OTHR 
+     return FSO_typeOf(p.Mode())
 }
 
@@ -0,0 +1,112 @@
+package fileutils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	FP "path/filepath"
+	"time"
+
+	CT "github.com/fbaube/ctoken"
+	SU "github.com/fbaube/stringutils"
+)
+
+// NewFSObjectFromContent creates a pathless FSObject whose content
+// was created interactively or so far lives only in memory. It is
+// fully usable: it has a synthesized [fs.FileInfo] (a regular file,
+// mode 0644, mod time now), its FSO_type, its [TypedRaw], and its
+// hash(es) per [DefaultHashAlgs].
+//
+// The targetPath is optional, and says where the content is meant
+// to end up; it is processed lexically only (nothing is created),
+// and flag [Filepaths.DoesNotExist] says whether something is
+// already there. Until the content is written out, flag
+// [Filepaths.ContentInMemoryIsDirty] is set and [IsInMemory]
+// is true, and [Contents] returns the content in memory without
+// going to disk.
+// .
+func NewFSObjectFromContent(s string, targetPath string) *FSObject {
+	var p = new(FSObject)
+	p.inMemory = true
+	p.FileInfo = &synthFileInfo{name: FP.Base(targetPath),
+		size: int64(len(s)), mode: 0644, modTime: time.Now()}
+	if targetPath == "" {
+		p.FileInfo.(*synthFileInfo).name = ""
+	}
+	p.FSO_type = FSO_type_FILE
+	p.Perms = permString(p.FileInfo)
+	p.TypedRaw = new(CT.TypedRaw)
+	p.Raw = CT.Raw(s)
+	if len(s) < MIN_FILE_SIZE {
+		p.TypedRaw.Raw_type = SU.Raw_type_NIL
+	}
+	if mh, e := p.newHasher(); e != nil {
+		p.SetError(e)
+	} else {
+		io.WriteString(mh, s)
+		p.setDigests(mh.Digests())
+	}
+	if targetPath != "" {
+		p.FPs = *newFilepathsForTarget(targetPath)
+		if p.FPs.HasError() {
+			p.SetError(p.FPs.GetError())
+		}
+	}
+	p.FPs.IsFile = true
+	p.FPs.ContentInMemoryIsDirty = true
+	p.setMimeType([]byte(s[:min(len(s), SniffLen)]))
+	p.setMType()
+	return p
+}
+
+// NewFSObjectFromBytes is [NewFSObjectFromContent] for a []byte,
+// which is copied.
+func NewFSObjectFromBytes(bb []byte, targetPath string) *FSObject {
+	return NewFSObjectFromContent(string(bb), targetPath)
+}
+
+// newFilepathsForTarget is [NewFilepaths] for a path that
+// is not required to exist. It does no I/O except to set
+// flag DoesNotExist.
+func newFilepathsForTarget(anFP string) *Filepaths {
+	var pFPs = new(Filepaths)
+	anFP = FP.Clean(anFP)
+	pFPs.creatPath = anFP
+	pFPs.GotAbs = FP.IsAbs(anFP)
+	pFPs.IsValid = pFPs.GotAbs || FP.IsLocal(anFP)
+	pFPs.IsLocal = FP.IsLocal(anFP)
+	if pFPs.GotAbs {
+		pFPs.AbsFP = anFP
+		pFPs.RelFP = SU.Tildotted(anFP)
+	} else {
+		var e error
+		pFPs.RelFP = anFP
+		pFPs.AbsFP, e = FP.Abs(anFP)
+		if e != nil {
+			pFPs.SetError(&os.PathError{Op: "FP.Abs", Path: anFP, Err: e})
+			return pFPs
+		}
+	}
+	pFPs.ShortFP = SU.Tildotted(pFPs.AbsFP)
+	pFPs.DoesNotExist = !PathExists(pFPs.AbsFP)
+	return pFPs
+}
+
+// IsInMemory is true for an FSObject made by [NewFSObjectFromContent]
+// whose content has not (yet) been written out to disk.
+func (p *FSObject) IsInMemory() bool {
+	return p.inMemory
+}
+
+// openInMemory is [OpenContent] for an in-memory FSObject.
+func (p *FSObject) openInMemory(lim ContentLimits) (io.ReadSeekCloser, error) {
+	var s string
+	if p.TypedRaw != nil {
+		s = p.TypedRaw.S()
+	}
+	if lim.exceeds(int64(len(s))) {
+		return nil, &os.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: ErrTooLarge}
+	}
+	return bytesReadSeekCloser{bytes.NewReader([]byte(s))}, nil
+}
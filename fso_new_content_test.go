@@ -0,0 +1,87 @@
+package fileutils
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	FP "path/filepath"
+	"testing"
+)
+
+func TestNewFSObjectFromContent(t *testing.T) {
+	var content = "<?xml version=\"1.0\"?>\n<topic id=\"t\"><title>T</title></topic>\n"
+	var p = NewFSObjectFromContent(content, "")
+	if p.HasError() {
+		t.Fatal(p.GetError())
+	}
+	if !p.IsInMemory() || !p.IsFile() || p.FSO_type != FSO_type_FILE {
+		t.Errorf("in memory %v, file %v, type %s",
+			p.IsInMemory(), p.IsFile(), p.FSO_type)
+	}
+	if p.Size() != int64(len(content)) || p.Mode().Perm() != 0644 {
+		t.Errorf("size %d, mode %v", p.Size(), p.Mode())
+	}
+	if !p.FPs.ContentInMemoryIsDirty {
+		t.Error("not dirty")
+	}
+	var sum = md5.Sum([]byte(content))
+	if p.Digest().Hex() != hex.EncodeToString(sum[:]) {
+		t.Errorf("digest %s", p.Digest())
+	}
+	if s, e := p.Contents(); e != nil || s != content {
+		t.Errorf("Contents: %q, %v", s, e)
+	}
+	rsc, e := p.OpenContent(DefaultContentLimits)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if bb, _ := io.ReadAll(rsc); string(bb) != content {
+		t.Errorf("OpenContent: %q", bb)
+	}
+	if _, e = p.OpenContent(ContentLimits{MaxSize: 10}); !errors.Is(e, ErrTooLarge) {
+		t.Errorf("limit: got %v, want ErrTooLarge", e)
+	}
+	// Nowhere to save it to.
+	if e = p.Save(); !errors.Is(e, ErrNoHostPath) {
+		t.Errorf("Save: got %v, want ErrNoHostPath", e)
+	}
+}
+
+func TestNewFSObjectFromContentSave(t *testing.T) {
+	var dir = t.TempDir()
+	var target = FP.Join(dir, "new.txt")
+	var p = NewFSObjectFromBytes([]byte("new content\n"), target)
+	if !p.FPs.DoesNotExist || p.FPs.AbsFP != target || p.Name() != "new.txt" {
+		t.Errorf("DoesNotExist %v, AbsFP %q, name %q",
+			p.FPs.DoesNotExist, p.FPs.AbsFP, p.Name())
+	}
+	if e := p.Save(); e != nil {
+		t.Fatal(e)
+	}
+	if bb, e := os.ReadFile(target); e != nil || string(bb) != "new content\n" {
+		t.Errorf("on disk: %q, %v", bb, e)
+	}
+	if p.IsInMemory() || p.FPs.DoesNotExist || p.FPs.ContentInMemoryIsDirty {
+		t.Errorf("after Save: in memory %v, does not exist %v, dirty %v",
+			p.IsInMemory(), p.FPs.DoesNotExist, p.FPs.ContentInMemoryIsDirty)
+	}
+	if !p.Stat.Valid {
+		t.Error("no stat info after Save")
+	}
+
+	// Someone else creates the target first.
+	var target2 = FP.Join(dir, "raced.txt")
+	p = NewFSObjectFromContent("mine", target2)
+	writeTestFile(t, dir, "raced.txt", "theirs")
+	if e := p.Save(); !errors.Is(e, ErrSaveConflict) {
+		t.Errorf("got %v, want ErrSaveConflict", e)
+	}
+	if e := p.ForceSave(); e != nil {
+		t.Fatal(e)
+	}
+	if bb, _ := os.ReadFile(target2); string(bb) != "mine" {
+		t.Errorf("after ForceSave: %q", bb)
+	}
+}
@@ -0,0 +1,184 @@
+package fileutils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	FP "path/filepath"
+)
+
+// NewFSObjectFromFS is like [NewFSObject] but the object is
+// found in an [fs.FS] rather than in the host file system, so
+// it works for (e.g.) [embed.FS], [testing/fstest.MapFS], and
+// archive readers, as well as [os.DirFS]. The name must satisfy
+// [fs.ValidPath], except that a trailing slash is tolerated.
+//
+// It does not follow symlinks if the fs.FS implements
+// [fs.ReadLinkFS]; otherwise it has no choice.
+//
+// The [Filepaths] are filled in relative to the root of the
+// fs.FS: RelFP and ShortFP are the slash-separated name (with
+// a trailing slash for a directory), and AbsFP is empty ("")
+// because the fs.FS might not correspond to anything on disk.
+// The content (see [Contents]) is read thru the fs.FS.
+//
+// As for NewFSObject, the return value is always non-nil,
+// and any error is in embedded struct [Errer].
+// .
+func NewFSObjectFromFS(fsys fs.FS, name string) *FSObject {
+	var pEmpty = new(FSObject)
+	pEmpty.fsys = fsys
+	if fsys == nil || name == "" {
+		pEmpty.SetError(errors.New("newfsobjectfromfs: nil fs or empty path"))
+		return pEmpty
+	}
+	var pPE = &fs.PathError{Op: "newfsobjectfromfs", Path: name}
+	name = trimPathSepSuffix(name)
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		pPE.Err = fs.ErrInvalid
+		pEmpty.SetError(pPE)
+		return pEmpty
+	}
+	pEmpty.FPs = *newFilepathsInFS(name)
+	fi, e := fs.Lstat(fsys, name)
+	if fi == nil || e != nil {
+		pPE.Op = "fs.lstat"
+		pPE.Err = e
+		pEmpty.SetError(pPE)
+		if e != nil && errors.Is(e, fs.ErrNotExist) {
+			pEmpty.FPs.DoesNotExist = true
+		}
+		return pEmpty
+	}
+	var pFSI = new(FSObject)
+	pFSI.fsys = fsys
+	pFSI.FPs = pEmpty.FPs
+	pFSI.FileInfo = fi
+	pFSI.FPs.IsFile = fi.Mode().IsRegular()
+	pFSI.FPs.IsDir = fi.IsDir()
+	pFSI.FPs.IsSymlink = (0 != (fi.Mode() & fs.ModeSymlink))
+	pFSI.FPs.IsDirlike = pFSI.FPs.IsDir || pFSI.FPs.IsSymlink
+	if fi.IsDir() {
+		pFSI.FPs.EnsurePathSepSuffixes()
+	}
+	pFSI.FSO_type = pFSI.FSObjectType()
+	// An os.DirFS has the OS-dependent fields; most others don't.
+	pFSI.setStatInfo(fi)
+	pFSI.Perms = permString(fi)
+	return pFSI
+}
+
+// newFilepathsInFS is [NewFilepaths] for a name in an [fs.FS].
+// It does no I/O, so the type flags are not set.
+func newFilepathsInFS(name string) *Filepaths {
+	var pFPs = new(Filepaths)
+	pFPs.creatPath = name
+	pFPs.RelFP = name
+	pFPs.ShortFP = name
+	pFPs.IsValid = fs.ValidPath(name)
+	pFPs.IsLocal = FP.IsLocal(FP.FromSlash(name))
+	return pFPs
+}
+
+// FS returns the [fs.FS] that the FSObject was found in,
+// or nil if it is in the host file system (or in memory).
+func (p *FSObject) FS() fs.FS {
+	return p.fsys
+}
+
+// fsName is the object's name within its [fs.FS].
+func (p *FSObject) fsName() string {
+	var s = trimPathSepSuffix(p.FPs.RelFP)
+	if s == "" {
+		return "."
+	}
+	return s
+}
+
+// lstat gets a fresh [fs.FileInfo] from wherever the object lives.
+func (p *FSObject) lstat() (fs.FileInfo, error) {
+	if p.inMemory {
+		return p.FileInfo, nil
+	}
+	if p.fsys != nil {
+		return fs.Lstat(p.fsys, p.fsName())
+	}
+	return lstatHost(trimPathSepSuffix(p.FPs.AbsFP))
+}
+
+// openInFS is [OpenContent] for an object in an [fs.FS]. If the
+// [fs.File] cannot Seek (for example, a compressed zip entry),
+// the content is read into memory (within the limits) instead.
+// .
+func (p *FSObject) openInFS(lim ContentLimits) (io.ReadSeekCloser, error) {
+	f, e := p.fsys.Open(p.fsName())
+	if e != nil {
+		return nil, &fs.PathError{Op: "fso.opencontent:fs.open",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	fi, e := f.Stat()
+	if e != nil {
+		f.Close()
+		return nil, &fs.PathError{Op: "fso.opencontent:stat",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	if lim.exceeds(fi.Size()) {
+		f.Close()
+		return nil, &fs.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: ErrTooLarge}
+	}
+	if rsc, ok := f.(io.ReadSeekCloser); ok {
+		return rsc, nil
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if lim.MaxSize > 0 {
+		r = io.LimitReader(f, lim.MaxSize+1)
+	}
+	bb, e := io.ReadAll(r)
+	if e != nil {
+		return nil, &fs.PathError{Op: "fso.opencontent:io.readall",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	if lim.exceeds(int64(len(bb))) {
+		return nil, &fs.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: ErrTooLarge}
+	}
+	return bytesReadSeekCloser{bytes.NewReader(bb)}, nil
+}
+
+// bytesReadSeekCloser adds a no-op Close to a [bytes.Reader].
+type bytesReadSeekCloser struct {
+	*bytes.Reader
+}
+
+func (bytesReadSeekCloser) Close() error { return nil }
+
+// ReadDirFS is [ReadDir] for a directory in an [fs.FS]. As for
+// ReadDir, an error on an individual item is attached to the
+// item via interface [Errer]. The items are sorted by name.
+// .
+func ReadDirFS(fsys fs.FS, dir string) ([]FSObject, error) {
+	if fsys == nil {
+		return nil, errors.New("ReadDirFS: nil fs")
+	}
+	dir = trimPathSepSuffix(dir)
+	if dir == "" {
+		dir = "."
+	}
+	des, e := fs.ReadDir(fsys, dir)
+	if e != nil {
+		return nil, &fs.PathError{Op: "fs.ReadDir", Path: dir, Err: e}
+	}
+	var FSIs []FSObject
+	for _, de := range des {
+		FSIs = append(FSIs, *NewFSObjectFromFS(
+			fsys, path.Join(dir, de.Name())))
+	}
+	return FSIs, nil
+}
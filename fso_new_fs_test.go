@@ -0,0 +1,126 @@
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+)
+
+func testMapFS() fstest.MapFS {
+	return fstest.MapFS{
+		"a.txt":       {Data: []byte("alpha\n")},
+		"sub/b.xml":   {Data: []byte("<b/>\n")},
+		"sub/c.md":    {Data: []byte("# C\n")},
+		"sub/link":    {Data: []byte("b.xml"), Mode: fs.ModeSymlink},
+		"sub/deep/d":  {Data: []byte("d")},
+		"empty/":      {Mode: fs.ModeDir | 0755},
+		"big.bin":     {Data: make([]byte, 1000)},
+		"sub/deep/e/": {Mode: fs.ModeDir},
+	}
+}
+
+func TestNewFSObjectFromFS(t *testing.T) {
+	var fsys = testMapFS()
+	var tests = []struct {
+		name    string
+		typ     FSO_type
+		relFP   string
+		wantErr error
+	}{
+		{"a.txt", FSO_type_FILE, "a.txt", nil},
+		{"sub", FSO_type_DIRR, "sub/", nil},
+		{"sub/", FSO_type_DIRR, "sub/", nil},
+		{".", FSO_type_DIRR, "./", nil},
+		// Not followed
+		{"sub/link", FSO_type_SYML, "sub/link", nil},
+		{"nope.txt", "", "", fs.ErrNotExist},
+		{"../a.txt", "", "", fs.ErrInvalid},
+		{"/a.txt", "", "", fs.ErrInvalid},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var p = NewFSObjectFromFS(fsys, tc.name)
+			if tc.wantErr != nil {
+				if !errors.Is(p.GetError(), tc.wantErr) {
+					t.Errorf("got %v, want %v", p.GetError(), tc.wantErr)
+				}
+				if tc.wantErr == fs.ErrNotExist && !p.FPs.DoesNotExist {
+					t.Error("DoesNotExist not set")
+				}
+				return
+			}
+			if p.HasError() {
+				t.Fatal(p.GetError())
+			}
+			if p.FSO_type != tc.typ || p.FPs.RelFP != tc.relFP {
+				t.Errorf("got %s %q, want %s %q",
+					p.FSO_type, p.FPs.RelFP, tc.typ, tc.relFP)
+			}
+			if p.FPs.AbsFP != "" || p.FS() == nil {
+				t.Errorf("AbsFP %q, FS %v", p.FPs.AbsFP, p.FS())
+			}
+		})
+	}
+	if p := NewFSObjectFromFS(nil, "a.txt"); !p.HasError() {
+		t.Error("nil fs: no error")
+	}
+}
+
+func TestFSObjectFromFSContents(t *testing.T) {
+	var fsys = testMapFS()
+	var p = NewFSObjectFromFS(fsys, "sub/b.xml")
+	s, e := p.Contents()
+	if e != nil || s != "<b/>\n" {
+		t.Fatalf("got %q, %v", s, e)
+	}
+	if p.Digest().IsZero() {
+		t.Error("no digest")
+	}
+	p = NewFSObjectFromFS(fsys, "big.bin")
+	if _, e = p.ContentsLimited(ContentLimits{MaxSize: 999}); !errors.Is(e, ErrTooLarge) {
+		t.Errorf("limit: got %v, want ErrTooLarge", e)
+	}
+	// Saving needs a host path.
+	p = NewFSObjectFromFS(fsys, "a.txt")
+	p.SetContents("changed")
+	if e = p.Save(); !errors.Is(e, ErrNoHostPath) {
+		t.Errorf("Save: got %v, want ErrNoHostPath", e)
+	}
+}
+
+func TestReadDirFS(t *testing.T) {
+	pp, e := ReadDirFS(testMapFS(), "sub/")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var want = []string{"sub/b.xml", "sub/c.md", "sub/deep/", "sub/link"}
+	if len(pp) != len(want) {
+		t.Fatalf("got %d items, want %d", len(pp), len(want))
+	}
+	for i, p := range pp {
+		if p.FPs.RelFP != want[i] {
+			t.Errorf("item %d: got %q, want %q", i, p.FPs.RelFP, want[i])
+		}
+	}
+	if _, e = ReadDirFS(testMapFS(), "nope"); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("missing dir: got %v", e)
+	}
+}
+
+func TestNewFSObjectFromDirFS(t *testing.T) {
+	var dir = t.TempDir()
+	writeTestFile(t, dir, "x/y.txt", "why")
+	var p = NewFSObjectFromFS(os.DirFS(dir), "x/y.txt")
+	if p.HasError() || !p.IsFile() {
+		t.Fatalf("got %v", p.GetError())
+	}
+	// An os.DirFS has the OS-dependent fields.
+	if _, ok := statInfoOf(p.FileInfo); ok && !p.Stat.Valid {
+		t.Error("Stat not filled in")
+	}
+	if s, e := p.Contents(); e != nil || s != "why" {
+		t.Errorf("got %q, %v", s, e)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"fmt"
 	"errors"
-	"syscall"
 	FP "path/filepath"
 )
 
@@ -53,8 +52,9 @@ will be of type *PathError.
 // be empty and invalid, except (maybe!) embedded struct
 // [FPs] (a [Filepaths]). 
 //
-// Note that passing in an empty path is not OK; instead 
-// create (by hand) a new pathless FSObject from the content. 
+// Note that passing in an empty path is not OK; instead create 
+// a new pathless FSObject from the content, using func 
+// [NewFSObjectFromContent].
 //
 // If you wish to create a blank FSObject that has no path,
 // simply use a nil ptr instead of calling this func.
@@ -108,6 +108,7 @@ func NewFSObjectSandboxed(anFP string, aRoot os.Root) *FSObject {
 	pFSI = new(FSObject)
 	pFSI.FPs = *pFPs
 	pFSI.FileInfo = fi
+	pFSI.FSO_type = pFSI.FSObjectType()
 	// pFSI.Exists = true
 	// Also set the time of access
 	// pFSI.LastCheckTime = time.Now()
@@ -127,29 +128,20 @@ func NewFSObjectSandboxed(anFP string, aRoot os.Root) *FSObject {
 	}
 	// -----------------------------------------------
 	// Now we try to fetch the fields that might be OS-dependent
-	s, ok := fi.Sys().(*syscall.Stat_t)
-        if !ok {
+	if e := pFSI.setStatInfo(fi); e != nil {
 	       // Non-fatal error
 	       // FIXME: This might be difficult to debug 
-	       pe := &fs.PathError{ Op:"fs.fileinfo.sys", 
-	       	      Path:pFSI.FPs.AbsFP, Err:errors.New(
-		     "cannot convert Stat.Sys() to syscall.Stat_t " +
-		     "(should NOT be fatal!)") }
-		pFSI.SetError(pe)
-		// Do not return, from here 
+	       pFSI.SetError(&fs.PathError{ Op:"fs.fileinfo.sys", 
+	       	      Path:pFSI.FPs.AbsFP, Err:e })
+	       // Do not return, from here 
 	       }
-        var nlinks int
-        nlinks = int(s.Nlink)
-        if nlinks > 1 && (fi.Mode()&fs.ModeSymlink == 0) {
-                // The index number of this file's inode:
-                pFSI.Inode  = int(s.Ino)
-                pFSI.NLinks = int(s.Nlink)
-        }
-        // TODO: FILE PERMS
-        // inode, nlinks int64
 
 	pFSI.Perms = permString(fi)
-	
+	// Record the file as it is now, so that Save can 
+	// tell if it is changed before the content is loaded.
+	if fi.Mode().IsRegular() {
+	   pFSI.setBaseline()
+	   }
         return pFSI 
 }
 
@@ -0,0 +1,177 @@
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// ChangeKind is the result of [FSObject.Refresh]. The values
+// are ordered by severity, so they can be compared with "<".
+type ChangeKind int
+
+const (
+	// ChangeNone means that nothing that we check has changed.
+	ChangeNone ChangeKind = iota
+	// ChangeMetadata means that (e.g.) permissions, ctime or
+	// link count changed, but size, mtime and content did not.
+	ChangeMetadata
+	// ChangeContent means that size or mtime changed, or
+	// (when re-hashing) the content hash changed.
+	ChangeContent
+	// ChangeReplaced means that the path now refers to a
+	// different object: another inode or device (e.g. an
+	// editor's save-by-rename), or another type of object.
+	ChangeReplaced
+	// ChangeDeleted means that nothing is there any more.
+	ChangeDeleted
+)
+
+func (c ChangeKind) String() string {
+	switch c {
+	case ChangeNone:
+		return "unchanged"
+	case ChangeMetadata:
+		return "metadata-only"
+	case ChangeContent:
+		return "content-changed"
+	case ChangeReplaced:
+		return "replaced"
+	case ChangeDeleted:
+		return "deleted"
+	}
+	return "?"
+}
+
+// Refresh re-stats the object and says how it has changed since its
+// [fs.FileInfo] was last fetched. Unlike a simple ModTime check, it
+// compares size, mtime (to the nanosecond), mode, ctime, link count,
+// inode and device (the last four only where the OS provides them),
+// so it also catches same-second edits, in-place rewrites, and files
+// replaced by rename.
+//
+// If rehash is true and the object is a file whose content has been
+// loaded, the file is also re-hashed (md5) and compared, which
+// catches a rewrite that preserved both size and mtime.
+//
+// The FSObject is updated to match: FileInfo, Perms, FSO_type, [Stat],
+// and flag [Filepaths.DoesNotExist]. If the content changed (or worse),
+// the content in memory is dropped, so that the next call to [Contents]
+// reloads it - unless it has unsaved changes ([ContentInMemoryIsDirty]),
+// in which case it is kept, and [Save] will report the conflict.
+//
+// An in-memory object (see [NewFSObjectFromContent]) never changes.
+// .
+func (p *FSObject) Refresh(rehash bool) (ChangeKind, error) {
+	if p.inMemory {
+		return ChangeNone, nil
+	}
+	newFI, e := p.lstat()
+	if e != nil {
+		if errors.Is(e, fs.ErrNotExist) {
+			if p.FPs.DoesNotExist {
+				return ChangeNone, nil
+			}
+			p.FPs.DoesNotExist = true
+			p.dropContent()
+			return ChangeDeleted, nil
+		}
+		return ChangeNone, &fs.PathError{Op: "fso.refresh",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	var kind = p.compareFileInfo(newFI)
+	if rehash && kind < ChangeContent && newFI.Mode().IsRegular() {
+		if p.contentHashChanged() {
+			kind = ChangeContent
+		}
+	}
+	if kind == ChangeNone {
+		return kind, nil
+	}
+	p.FileInfo = newFI
+	p.FPs.DoesNotExist = false
+	p.FSO_type = p.FSObjectType()
+	p.Perms = permString(newFI)
+	p.FPs.IsFile = newFI.Mode().IsRegular()
+	p.FPs.IsDir = newFI.IsDir()
+	p.FPs.IsSymlink = (0 != (newFI.Mode() & fs.ModeSymlink))
+	p.FPs.IsDirlike = p.FPs.IsDir || p.FPs.IsSymlink
+	p.setStatInfo(newFI)
+	if kind >= ChangeContent {
+		p.dropContent()
+	}
+	// With nothing unsaved, the new state is the baseline.
+	if !p.FPs.ContentInMemoryIsDirty && newFI.Mode().IsRegular() {
+		p.setBaseline()
+	}
+	return kind, nil
+}
+
+// compareFileInfo compares a fresh FileInfo with the current one.
+func (p *FSObject) compareFileInfo(newFI fs.FileInfo) ChangeKind {
+	var oldFI = p.FileInfo
+	// It did not exist before, or we never stat'd it
+	if oldFI == nil || p.FPs.DoesNotExist {
+		return ChangeReplaced
+	}
+	if oldFI.Mode().Type() != newFI.Mode().Type() {
+		return ChangeReplaced
+	}
+	oldSt, okOld := statInfoOf(oldFI)
+	newSt, okNew := statInfoOf(newFI)
+	if okOld && okNew &&
+		(oldSt.Dev != newSt.Dev || oldSt.Ino != newSt.Ino) {
+		return ChangeReplaced
+	}
+	if oldFI.Size() != newFI.Size() ||
+		!oldFI.ModTime().Equal(newFI.ModTime()) {
+		return ChangeContent
+	}
+	if oldFI.Mode() != newFI.Mode() {
+		return ChangeMetadata
+	}
+	if okOld && okNew && (!oldSt.Ctime.Equal(newSt.Ctime) ||
+		oldSt.Nlink != newSt.Nlink) {
+		return ChangeMetadata
+	}
+	return ChangeNone
+}
+
+// contentHashChanged re-hashes the file and compares it with the md5
+// from when the content was loaded. With nothing to compare, false.
+func (p *FSObject) contentHashChanged() bool {
+	var was = p.loaded.md5
+	if was.IsZero() {
+		was = p.Digests.Get(HashMD5)
+		if was.IsZero() || p.FPs.ContentInMemoryIsDirty {
+			return false
+		}
+	}
+	var dd ContentDigests
+	var e error
+	if p.fsys != nil {
+		f, e2 := p.fsys.Open(p.fsName())
+		if e2 != nil {
+			return false
+		}
+		defer f.Close()
+		dd, _, e = HashReader(f, HashMD5)
+	} else {
+		f, _, e2 := openRegular(p.FPs.AbsFP)
+		if e2 != nil {
+			return false
+		}
+		defer f.Close()
+		dd, _, e = HashReader(f, HashMD5)
+	}
+	return e == nil && dd.Get(HashMD5).Hex() != was.Hex()
+}
+
+// dropContent forgets content that no longer matches the
+// object, unless it has unsaved changes.
+func (p *FSObject) dropContent() {
+	if p.FPs.ContentInMemoryIsDirty {
+		return
+	}
+	p.TypedRaw = nil
+	p.Digests = nil
+}
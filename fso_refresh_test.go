@@ -0,0 +1,124 @@
+package fileutils
+
+import (
+	"os"
+	FP "path/filepath"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	var tests = []struct {
+		name   string
+		change func(t *testing.T, path string)
+		want   ChangeKind
+	}{
+		{"none", func(*testing.T, string) {}, ChangeNone},
+		{"chmod", func(t *testing.T, path string) {
+			os.Chmod(path, 0600)
+		}, ChangeMetadata},
+		{"rewritten", func(t *testing.T, path string) {
+			rewrite(t, path, "changed content\n")
+		}, ChangeContent},
+		{"renamed over", func(t *testing.T, path string) {
+			var tmp = writeTestFile(t, FP.Dir(path), "tmp", "other\n")
+			if e := os.Rename(tmp, path); e != nil {
+				t.Fatal(e)
+			}
+		}, ChangeReplaced},
+		{"now a dir", func(t *testing.T, path string) {
+			os.Remove(path)
+			os.Mkdir(path, 0755)
+		}, ChangeReplaced},
+		{"deleted", func(t *testing.T, path string) {
+			os.Remove(path)
+		}, ChangeDeleted},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var path = writeTestFile(t, t.TempDir(), "f.txt", "content\n")
+			var p = NewFSObject(path)
+			if _, e := p.Contents(); e != nil {
+				t.Fatal(e)
+			}
+			tc.change(t, path)
+			kind, e := p.Refresh(false)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if kind != tc.want {
+				t.Fatalf("got %s, want %s", kind, tc.want)
+			}
+			// Content that no longer matches is dropped.
+			if (p.TypedRaw == nil) != (kind >= ChangeContent) {
+				t.Errorf("content kept: %v", p.TypedRaw != nil)
+			}
+			if kind == ChangeDeleted && !p.FPs.DoesNotExist {
+				t.Error("DoesNotExist not set")
+			}
+			// And now it is up to date.
+			if kind, _ = p.Refresh(false); kind != ChangeNone {
+				t.Errorf("second Refresh: %s", kind)
+			}
+		})
+	}
+}
+
+// TestRefreshRehash checks that a rewrite that keeps
+// the size and the mtime is caught only by re-hashing.
+func TestRefreshRehash(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "content\n")
+	var p = NewFSObject(path)
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	var fi = p.FileInfo
+	if e := os.WriteFile(path, []byte("CONTENT\n"), 0644); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.Chtimes(path, fi.ModTime(), fi.ModTime()); e != nil {
+		t.Fatal(e)
+	}
+	if kind, _ := p.Refresh(false); kind >= ChangeContent {
+		t.Fatalf("without rehash: %s", kind)
+	}
+	if kind, _ := p.Refresh(true); kind != ChangeContent {
+		t.Errorf("with rehash: got %s, want %s", kind, ChangeContent)
+	}
+	if s, _ := p.Contents(); s != "CONTENT\n" {
+		t.Errorf("not reloaded: %q", s)
+	}
+}
+
+func TestRefreshKeepsDirtyContent(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "content\n")
+	var p = NewFSObject(path)
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	p.SetContents("unsaved\n")
+	rewrite(t, path, "theirs\n")
+	if kind, _ := p.Refresh(false); kind != ChangeContent {
+		t.Errorf("got %s", kind)
+	}
+	if s, _ := p.Contents(); s != "unsaved\n" {
+		t.Errorf("unsaved content lost: %q", s)
+	}
+}
+
+func TestRefreshInMemory(t *testing.T) {
+	var p = NewFSObjectFromContent("x", "")
+	if kind, e := p.Refresh(true); kind != ChangeNone || e != nil {
+		t.Errorf("got %s, %v", kind, e)
+	}
+}
+
+func TestChangeKindString(t *testing.T) {
+	for k, want := range map[ChangeKind]string{
+		ChangeNone: "unchanged", ChangeMetadata: "metadata-only",
+		ChangeContent: "content-changed", ChangeReplaced: "replaced",
+		ChangeDeleted: "deleted", ChangeKind(99): "?"} {
+		if k.String() != want {
+			t.Errorf("%d: got %q, want %q", k, k.String(), want)
+		}
+	}
+}
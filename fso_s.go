@@ -22,11 +22,15 @@ func (p *FSObject) ListingString() string {
      // If this returns an error, it should 
      // also set the error via interface Errer,
      // so here we ignore the error return value. 
-     _, elc := p.Contents()
-     if elc != nil {
-     	p.SetError(fmt.Errorf("LoadContents: %w", elc))
-        return "ERROR:ListingString:" + elc.Error()
-        }
+     // Only a file has contents: a named pipe 
+     // (for example) could block forever. 
+     if p.IsFile() {
+       _, elc := p.Contents()
+       if elc != nil {
+     	  p.SetError(fmt.Errorf("LoadContents: %w", elc))
+          return "ERROR:ListingString:" + elc.Error()
+          }
+     }
      
      var fstp, size, err string
      fstp = S.ToUpper(string(p.FSO_type)) 
@@ -38,8 +42,10 @@ func (p *FSObject) ListingString() string {
 
 }
 
-// Echo implements [Stringser].
+// Echo implements [Stringser]. For an object in an 
+// [fs.FS], there is no AbsFP, so it uses the RelFP. 
 func (p *FSObject) Echo() string {
+	if p.FPs.AbsFP == "" { return p.FPs.RelFP }
 	return p.FPs.AbsFP
 }
 
@@ -53,9 +59,14 @@ func (p *FSObject) Infos() string {
 		s = fmt.Sprintf("Dirr[len:%d] ", p.Size())
 	} else if p.IsSymlink() {
 		s = "Symlink "
+	} else if p.FileInfo != nil {
+		s = FSO_typeOf(p.Mode()).LongName() + " "
 	} else {
 		s = "FSObject:?uninitialized "
 	}
+	if p.inMemory && p.FPs.ShortFP == "" {
+		s += "(in memory)"
+	}
 	s += p.FPs.ShortFP
 	return s
 }
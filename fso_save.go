@@ -0,0 +1,217 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+
+	CT "github.com/fbaube/ctoken"
+	SU "github.com/fbaube/stringutils"
+)
+
+// ErrSaveConflict is wrapped (in a [fs.PathError]) by [FSObject.Save]
+// when the file on disk has changed since its content was loaded, so
+// that saving would overwrite somebody else's changes. The message
+// says what changed. Use [FSObject.ForceSave] to overwrite anyway.
+var ErrSaveConflict = errors.New("file changed on disk since it was loaded")
+
+// ErrNoHostPath is wrapped (in a [fs.PathError]) when an FSObject
+// cannot be saved because it has no path on the host file system
+// (for example, it is pathless, or it lives in an [fs.FS]).
+var ErrNoHostPath = errors.New("no host file system path")
+
+// ErrNoContent is wrapped (in a [fs.PathError]) by [FSObject.Save]
+// when there is no content in memory to write, because it was never
+// loaded (or set), or it was dropped by [FSObject.Refresh]. Saving
+// it would truncate the file.
+var ErrNoContent = errors.New("no content loaded or set")
+
+// contentBaseline records the state of the file on disk as of
+// when the FSObject was created, or its content was loaded (or
+// saved), so that [Save] can tell whether the file has been
+// changed by somebody else.
+type contentBaseline struct {
+	valid   bool
+	size    int64
+	modTime time.Time
+	md5     ContentDigest
+}
+
+// setBaseline records the current FileInfo and md5 as the baseline.
+func (p *FSObject) setBaseline() {
+	if p.FileInfo == nil || p.inMemory {
+		p.loaded = contentBaseline{}
+		return
+	}
+	p.loaded = contentBaseline{valid: true, size: p.FileInfo.Size(),
+		modTime: p.FileInfo.ModTime(), md5: p.Digests.Get(HashMD5)}
+}
+
+// SetContents replaces the content in memory, recomputes the hash(es),
+// and sets flag [Filepaths.ContentInMemoryIsDirty]; nothing is written
+// to disk until [Save] is called. Until then, [Contents] returns the
+// new content rather than reloading it from disk.
+//
+// If the content had not been loaded yet, the FileInfo from when the
+// FSObject was created (or last refreshed) is used as the baseline
+// for conflict detection.
+// .
+func (p *FSObject) SetContents(s string) error {
+	if !p.inMemory && p.FileInfo != nil && !p.IsFile() {
+		return &fs.PathError{Op: "fso.setcontents",
+			Path: p.FPs.ShortFP, Err: ErrNotAFile}
+	}
+	if !p.loaded.valid && !p.FPs.ContentInMemoryIsDirty {
+		p.setBaseline()
+	}
+	var rt SU.Raw_type
+	if p.TypedRaw != nil {
+		rt = p.TypedRaw.Raw_type
+	}
+	p.TypedRaw = new(CT.TypedRaw)
+	p.Raw = CT.Raw(s)
+	p.TypedRaw.Raw_type = rt
+	mh, e := p.newHasher()
+	if e != nil {
+		return &fs.PathError{Op: "fso.setcontents:hash",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	io.WriteString(mh, s)
+	p.setDigests(mh.Digests())
+	p.setMimeType([]byte(s[:min(len(s), SniffLen)]))
+	p.setMType()
+	if sfi, ok := p.FileInfo.(*synthFileInfo); ok {
+		sfi.size = int64(len(s))
+		sfi.modTime = time.Now()
+	}
+	p.FPs.ContentInMemoryIsDirty = true
+	return nil
+}
+
+// Save writes the content in memory to the file at [Filepaths.AbsFP],
+// atomically (see [WriteAtomicMode]), in its original encoding if it
+// was transcoded to UTF-8 when it was loaded (see [EncodingInfo]). It preserves the permissions of
+// an existing file (a new file gets 0644), and afterwards it refreshes
+// the FileInfo and the hash(es) and clears the dirty flag.
+//
+// Save returns [ErrNoContent] if there is no content in memory,
+// that is, if it was neither loaded (by [Contents]) nor set (by
+// [SetContents]).
+//
+// Save refuses to overwrite, returning [ErrSaveConflict], if the file
+// on disk has changed since the content was loaded: if its size or mod
+// time differ AND its content hash differs, or if it was deleted, or
+// (for a new FSObject from [NewFSObjectFromContent]) if a file has
+// appeared at the path in the meantime.
+// .
+func (p *FSObject) Save() error {
+	return p.save(false)
+}
+
+// ForceSave is [Save] without the conflict check.
+func (p *FSObject) ForceSave() error {
+	return p.save(true)
+}
+
+func (p *FSObject) save(force bool) error {
+	var target = p.FPs.AbsFP
+	if target == "" || p.fsys != nil {
+		return &fs.PathError{Op: "fso.save",
+			Path: p.FPs.ShortFP, Err: ErrNoHostPath}
+	}
+	if p.TypedRaw == nil {
+		return &fs.PathError{Op: "fso.save",
+			Path: p.FPs.ShortFP, Err: ErrNoContent}
+	}
+	target = trimPathSepSuffix(target)
+	var perm fs.FileMode = 0644
+	diskFI, e := lstatHost(target)
+	if e != nil && !errors.Is(e, fs.ErrNotExist) {
+		return &fs.PathError{Op: "fso.save:lstat", Path: p.FPs.ShortFP, Err: e}
+	}
+	if diskFI != nil {
+		if !diskFI.Mode().IsRegular() {
+			return &fs.PathError{Op: "fso.save",
+				Path: p.FPs.ShortFP, Err: ErrNotAFile}
+		}
+		perm = diskFI.Mode().Perm()
+	} else if p.FileInfo != nil && !p.inMemory {
+		perm = p.FileInfo.Mode().Perm()
+	}
+	if !force {
+		if why := p.conflict(target, diskFI); why != "" {
+			return &fs.PathError{Op: "fso.save", Path: p.FPs.ShortFP,
+				Err: fmt.Errorf("%w: %s", ErrSaveConflict, why)}
+		}
+	}
+	// Undo any transcoding to UTF-8
+	bb, e := p.encodeForSave(p.TypedRaw.S())
+	if e != nil {
+		return &fs.PathError{Op: "fso.save:encode", Path: p.FPs.ShortFP, Err: e}
+	}
+	e = WriteAtomicMode(target, perm, func(w io.Writer) error {
+		_, e := w.Write(bb)
+		return e
+	})
+	if e != nil {
+		return &fs.PathError{Op: "fso.save:write", Path: p.FPs.ShortFP, Err: e}
+	}
+	// Refresh everything from the new file
+	newFI, e := lstatHost(target)
+	if e != nil {
+		return &fs.PathError{Op: "fso.save:lstat", Path: p.FPs.ShortFP, Err: e}
+	}
+	p.FileInfo = newFI
+	p.FSO_type = FSO_type_FILE
+	p.Perms = permString(newFI)
+	p.inMemory = false
+	p.setStatInfo(newFI)
+	p.FPs.DoesNotExist = false
+	p.FPs.IsFile = true
+	p.FPs.ContentInMemoryIsDirty = false
+	if mh, e := p.newHasher(); e == nil {
+		mh.Write(bb)
+		p.setDigests(mh.Digests())
+	}
+	p.setBaseline()
+	return nil
+}
+
+// conflict returns a reason if the file on disk has changed
+// since the baseline was recorded, else "".
+func (p *FSObject) conflict(target string, diskFI fs.FileInfo) string {
+	if !p.loaded.valid {
+		// Nothing was on disk when we started ?
+		if p.inMemory && p.FPs.DoesNotExist && diskFI != nil {
+			return "file was created"
+		}
+		return ""
+	}
+	if diskFI == nil {
+		return "file was deleted"
+	}
+	if diskFI.Size() == p.loaded.size &&
+		diskFI.ModTime().Equal(p.loaded.modTime) {
+		return ""
+	}
+	var why = fmt.Sprintf("size %d => %d, mtime %s => %s",
+		p.loaded.size, diskFI.Size(),
+		p.loaded.modTime.Format(time.RFC3339Nano),
+		diskFI.ModTime().Format(time.RFC3339Nano))
+	// Without a hash to compare, a change is a change.
+	if p.loaded.md5.IsZero() {
+		return why
+	}
+	pF, _, e := openRegular(target)
+	if e != nil {
+		return why
+	}
+	defer pF.Close()
+	dd, _, e := HashReader(pF, HashMD5)
+	if e != nil || dd.Get(HashMD5).Hex() != p.loaded.md5.Hex() {
+		return why + ", content hash differs"
+	}
+	return ""
+}
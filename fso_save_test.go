@@ -0,0 +1,183 @@
+package fileutils
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+// rewrite changes a file behind an FSObject's back, and
+// makes sure that its mtime changes even on a coarse clock.
+func rewrite(t *testing.T, path, content string) {
+	t.Helper()
+	fi, e := os.Stat(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = os.WriteFile(path, []byte(content), fi.Mode()); e != nil {
+		t.Fatal(e)
+	}
+	var mt = fi.ModTime().Add(2 * time.Second)
+	if e = os.Chtimes(path, mt, mt); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestSave(t *testing.T) {
+	var dir = t.TempDir()
+	var path = writeTestFile(t, dir, "f.txt", "old\n")
+	var p = NewFSObject(path)
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.Chmod(path, 0600); e != nil {
+		t.Fatal(e)
+	}
+	if e := p.SetContents("new\n"); e != nil {
+		t.Fatal(e)
+	}
+	if s, _ := p.Contents(); s != "new\n" || !p.FPs.ContentInMemoryIsDirty {
+		t.Errorf("before Save: %q, dirty %v", s, p.FPs.ContentInMemoryIsDirty)
+	}
+	if e := p.Save(); e != nil {
+		t.Fatal(e)
+	}
+	fi, e := os.Stat(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if bb, _ := os.ReadFile(path); string(bb) != "new\n" {
+		t.Errorf("on disk: %q", bb)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("permissions not kept: %v", fi.Mode())
+	}
+	if p.FPs.ContentInMemoryIsDirty || p.Size() != 4 {
+		t.Errorf("after Save: dirty %v, size %d",
+			p.FPs.ContentInMemoryIsDirty, p.Size())
+	}
+	// A second save, with no change on disk in between, is fine.
+	p.SetContents("newer\n")
+	if e := p.Save(); e != nil {
+		t.Errorf("second Save: %v", e)
+	}
+}
+
+func TestSaveConflict(t *testing.T) {
+	var tests = []struct {
+		name     string
+		change   func(t *testing.T, path string)
+		conflict bool
+	}{
+		{"unchanged", func(*testing.T, string) {}, false},
+		{"rewritten", func(t *testing.T, path string) {
+			rewrite(t, path, "theirs\n")
+		}, true},
+		// Same size, different content
+		{"same size", func(t *testing.T, path string) {
+			rewrite(t, path, "ORIG\n")
+		}, true},
+		// Touched, but the content hash is the same
+		{"touched", func(t *testing.T, path string) {
+			rewrite(t, path, "orig\n")
+		}, false},
+		{"deleted", func(t *testing.T, path string) {
+			os.Remove(path)
+		}, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var path = writeTestFile(t, t.TempDir(), "f.txt", "orig\n")
+			var p = NewFSObject(path)
+			if _, e := p.Contents(); e != nil {
+				t.Fatal(e)
+			}
+			p.SetContents("mine\n")
+			tc.change(t, path)
+			var e = p.Save()
+			if tc.conflict != errors.Is(e, ErrSaveConflict) {
+				t.Fatalf("got %v, want conflict %v", e, tc.conflict)
+			}
+			if tc.conflict {
+				// Nothing was written, and the edit is kept.
+				if s, _ := p.Contents(); s != "mine\n" {
+					t.Errorf("edit lost: %q", s)
+				}
+				if e = p.ForceSave(); e != nil {
+					t.Fatal(e)
+				}
+			}
+			if bb, _ := os.ReadFile(path); string(bb) != "mine\n" {
+				t.Errorf("on disk: %q", bb)
+			}
+		})
+	}
+}
+
+func TestSetContentsNotAFile(t *testing.T) {
+	var p = NewFSObject(t.TempDir())
+	if e := p.SetContents("x"); !errors.Is(e, ErrNotAFile) {
+		t.Errorf("got %v, want ErrNotAFile", e)
+	}
+}
+
+// TestSaveUnloaded checks that the FileInfo from when the FSObject
+// was made is the baseline if the content was never loaded.
+func TestSaveUnloaded(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "orig\n")
+	var p = NewFSObject(path)
+	rewrite(t, path, "theirs, longer\n")
+	p.SetContents("mine\n")
+	if e := p.Save(); !errors.Is(e, ErrSaveConflict) {
+		t.Errorf("got %v, want ErrSaveConflict", e)
+	}
+}
+
+// TestSaveNoContent checks that Save does not truncate a file
+// whose content was never loaded or set, or was dropped.
+func TestSaveNoContent(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "orig\n")
+	var p = NewFSObject(path)
+	if e := p.Save(); !errors.Is(e, ErrNoContent) {
+		t.Errorf("not loaded: got %v, want ErrNoContent", e)
+	}
+	if e := p.ForceSave(); !errors.Is(e, ErrNoContent) {
+		t.Errorf("ForceSave: got %v, want ErrNoContent", e)
+	}
+	p.Contents()
+	rewrite(t, path, "theirs\n")
+	if _, e := p.Refresh(false); e != nil {
+		t.Fatal(e)
+	}
+	if e := p.Save(); !errors.Is(e, ErrNoContent) {
+		t.Errorf("dropped: got %v, want ErrNoContent", e)
+	}
+	if bb, _ := os.ReadFile(path); string(bb) != "theirs\n" {
+		t.Errorf("on disk: %q", bb)
+	}
+	// After a Refresh, the file as it is now is the baseline.
+	p.SetContents("mine\n")
+	if e := p.Save(); e != nil {
+		t.Errorf("after Refresh: %v", e)
+	}
+}
+
+// TestSaveIgnoresTMPDIR checks that the temp file is made beside
+// the target, so that the rename cannot cross file systems.
+func TestSaveIgnoresTMPDIR(t *testing.T) {
+	var dir = t.TempDir()
+	t.Setenv("TMPDIR", "/nonexistent/tmp")
+	var p = NewFSObject(writeTestFile(t, dir, "f.txt", "orig\n"))
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	p.SetContents("mine\n")
+	if e := p.Save(); e != nil {
+		t.Fatal(e)
+	}
+	des, e := os.ReadDir(dir)
+	if e != nil || len(des) != 1 {
+		t.Errorf("temp file left behind: %v, %v", des, e)
+	}
+}
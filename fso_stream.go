@@ -0,0 +1,210 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ContentLimits are the per-call bounds that are applied when
+// an [FSObject]'s content is read. They replace the hardwired
+// use of the package constants [MAX_FILE_SIZE] and [MIN_FILE_SIZE],
+// which now serve only as the values in [DefaultContentLimits].
+// .
+type ContentLimits struct {
+	// MinSize is the size below which a file is suspiciously
+	// tiny and cannot be analysed for MIME/content type. It
+	// causes a warning, not an error. Zero disables the check.
+	MinSize int64
+	// MaxSize is the largest file that may be read. A value
+	// of zero (or less) means that there is no upper limit.
+	MaxSize int64
+	// MmapAbove is the size above which [FSObject.ContentsLimited]
+	// reads the file thru a memory mapping rather than with read(2).
+	// This does NOT keep the content out of memory: it is copied
+	// into the Raw string, once, rather than read into a buffer
+	// that is then copied, and the mapping is released at once.
+	// To work on a mapping itself, use [FSObject.MapContents].
+	// Zero (or less) means never.
+	MmapAbove int64
+	// TranscodeToUTF8 makes [FSObject.ContentsLimited] convert
+	// content that is not UTF-8 (see [DetectEncoding]) to UTF-8,
+	// and strip a BOM. [FSObject.Save] then converts it back.
+	TranscodeToUTF8 bool
+}
+
+// DefaultContentLimits is what [FSObject.Contents] uses.
+var DefaultContentLimits = ContentLimits{
+	MinSize: MIN_FILE_SIZE, MaxSize: MAX_FILE_SIZE}
+
+// NoContentLimits places no bounds on size at all, and
+// is meant for use with the streaming API, not with
+// [FSObject.ContentsLimited].
+var NoContentLimits = ContentLimits{}
+
+// DefaultChunkSize is used by [FSObject.ReadChunks] when
+// the caller does not specify a chunk size.
+const DefaultChunkSize = 64 * 1024
+
+// ErrTooLarge is wrapped (in a [fs.PathError]) when
+// content exceeds the applicable [ContentLimits].
+var ErrTooLarge = errors.New("file too large")
+
+// ErrNotAFile is wrapped (in a [fs.PathError]) when
+// content is requested from something that is not a file.
+var ErrNotAFile = errors.New("not a regular file")
+
+// exceeds checks a size against the upper limit.
+func (lim ContentLimits) exceeds(n int64) bool {
+	return lim.MaxSize > 0 && n > lim.MaxSize
+}
+
+// OpenContent opens the file for streaming, read-only, and
+// returns it as an [io.ReadSeekCloser], which the caller must
+// Close. The size check uses a fresh [os.File.Stat] on the
+// opened file, so it is not fooled by a file that has been
+// replaced since the [FSObject] was created. No content is
+// stored in the FSObject, and field [TypedRaw] is not touched.
+// .
+func (p *FSObject) OpenContent(lim ContentLimits) (io.ReadSeekCloser, error) {
+	if p.inMemory {
+		return p.openInMemory(lim)
+	}
+	if p.FPs.DoesNotExist {
+		return nil, &fs.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: fs.ErrNotExist}
+	}
+	if !p.IsFile() {
+		return nil, &fs.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: ErrNotAFile}
+	}
+	if p.fsys != nil {
+		return p.openInFS(lim)
+	}
+	pF, fi, e := openRegular(p.FPs.AbsFP)
+	if e != nil {
+		return nil, &fs.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	if lim.exceeds(fi.Size()) {
+		pF.Close()
+		return nil, &fs.PathError{Op: "fso.opencontent",
+			Path: p.FPs.ShortFP, Err: fmt.Errorf(
+				"%w: %d > %d", ErrTooLarge, fi.Size(), lim.MaxSize)}
+	}
+	return pF, nil
+}
+
+// ReadChunks streams the file's content to the callback in
+// chunks of (at most) chunkSize bytes, hashing as it reads,
+// and returns the number of bytes read and the md5 hash. The
+// digests for all of the algorithms in [HashAlgs] are stored
+// in the FSObject (but the content is not).
+// The chunk slice is reused between calls, so the callback
+// must copy anything that it wants to keep. If the callback
+// returns an error, reading stops and the error is returned.
+//
+// The [ContentLimits] are enforced while reading, so a file
+// that grows during the read is still caught.
+// .
+func (p *FSObject) ReadChunks(lim ContentLimits, chunkSize int,
+	fn func(chunk []byte) error) (n int64, sum [16]byte, e error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	var mh *MultiHasher
+	mh, e = p.newHasher()
+	if e != nil {
+		return 0, sum, e
+	}
+	var rc io.ReadCloser
+	rc, e = p.OpenContent(lim)
+	if e != nil {
+		return 0, sum, e
+	}
+	defer rc.Close()
+	var hr = NewHashingReader(rc, mh)
+	var buf = make([]byte, chunkSize)
+	for {
+		var nr int
+		nr, e = io.ReadFull(hr, buf)
+		if nr > 0 {
+			if lim.exceeds(hr.N) {
+				return hr.N, sum, &fs.PathError{
+					Op: "fso.readchunks", Path: p.FPs.ShortFP,
+					Err: fmt.Errorf("%w: grew past %d",
+						ErrTooLarge, lim.MaxSize)}
+			}
+			if fn != nil {
+				if e2 := fn(buf[:nr]); e2 != nil {
+					return hr.N, sum, e2
+				}
+			}
+		}
+		if e == io.EOF || e == io.ErrUnexpectedEOF {
+			break
+		}
+		if e != nil {
+			return hr.N, sum, &fs.PathError{Op: "fso.readchunks",
+				Path: p.FPs.ShortFP, Err: e}
+		}
+	}
+	var dd = mh.Digests()
+	p.Digests = dd
+	copy(sum[:], dd.Get(HashMD5).Sum)
+	return hr.N, sum, nil
+}
+
+// HashingReader is an [io.Reader] that feeds everything
+// that passes thru it into a [hash.Hash], and counts it.
+// It makes it possible to hash content while reading it,
+// rather than in a second pass.
+type HashingReader struct {
+	R io.Reader
+	// H can also be a [MultiHasher].
+	H hash.Hash
+	// N is the number of bytes read so far.
+	N int64
+}
+
+// NewHashingReader wraps an [io.Reader].
+func NewHashingReader(r io.Reader, h hash.Hash) *HashingReader {
+	return &HashingReader{R: r, H: h}
+}
+
+// Read implements [io.Reader].
+func (p *HashingReader) Read(bb []byte) (int, error) {
+	n, e := p.R.Read(bb)
+	if n > 0 {
+		p.H.Write(bb[:n])
+		p.N += int64(n)
+	}
+	return n, e
+}
+
+// openRegular opens the file read-only, but refuses (with
+// [ErrNotAFile]) anything that is not a regular file. It opens
+// non-blocking, so if the path has been replaced by a named pipe
+// (or a device) since it was stat'd, the open does not hang,
+// and then it checks the opened file, not the path.
+// .
+func openRegular(path string) (*os.File, fs.FileInfo, error) {
+	pF, e := os.OpenFile(path, os.O_RDONLY|oNonBlock, 0)
+	if e != nil {
+		return nil, nil, e
+	}
+	fi, e := pF.Stat()
+	if e != nil {
+		pF.Close()
+		return nil, nil, e
+	}
+	if !fi.Mode().IsRegular() {
+		pF.Close()
+		return nil, fi, fmt.Errorf("%w: %s",
+			ErrNotAFile, FSO_typeOf(fi.Mode()).LongName())
+	}
+	return pF, fi, nil
+}
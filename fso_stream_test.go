@@ -0,0 +1,139 @@
+package fileutils
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestContentsLimited(t *testing.T) {
+	var dir = t.TempDir()
+	var content = strings.Repeat("0123456789\n", 100)
+	var path = writeTestFile(t, dir, "f.txt", content)
+	var tests = []struct {
+		name    string
+		maxSize int64
+		wantErr error
+	}{
+		{"no limit", 0, nil},
+		{"exact", int64(len(content)), nil},
+		{"one short", int64(len(content)) - 1, ErrTooLarge},
+		{"tiny", 10, ErrTooLarge},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var p = NewFSObject(path)
+			s, e := p.ContentsLimited(ContentLimits{MaxSize: tc.maxSize})
+			if !errors.Is(e, tc.wantErr) {
+				t.Fatalf("error: got %v, want %v", e, tc.wantErr)
+			}
+			if tc.wantErr == nil && s != content {
+				t.Errorf("got %d bytes, want %d", len(s), len(content))
+			}
+		})
+	}
+}
+
+func TestOpenContentNotAFile(t *testing.T) {
+	var dir = t.TempDir()
+	var p = NewFSObject(dir)
+	if _, e := p.OpenContent(NoContentLimits); !errors.Is(e, ErrNotAFile) {
+		t.Errorf("dir: got %v, want ErrNotAFile", e)
+	}
+	var path = writeTestFile(t, dir, "gone.txt", "x")
+	p = NewFSObject(path)
+	os.Remove(path)
+	p.FPs.DoesNotExist = true
+	if _, e := p.OpenContent(NoContentLimits); !errors.Is(e, os.ErrNotExist) {
+		t.Errorf("deleted: got %v, want ErrNotExist", e)
+	}
+}
+
+func TestOpenContentSeeks(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "hello, world")
+	rsc, e := NewFSObject(path).OpenContent(DefaultContentLimits)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer rsc.Close()
+	if _, e = rsc.Seek(7, io.SeekStart); e != nil {
+		t.Fatal(e)
+	}
+	bb, e := io.ReadAll(rsc)
+	if e != nil || string(bb) != "world" {
+		t.Errorf("got %q, %v", bb, e)
+	}
+}
+
+func TestReadChunks(t *testing.T) {
+	var content = strings.Repeat("abcdefg", 1000)
+	var path = writeTestFile(t, t.TempDir(), "f.txt", content)
+	var want = md5.Sum([]byte(content))
+	for _, size := range []int{0, 1, 7, 100, 4096, 1 << 20} {
+		var p = NewFSObject(path)
+		var got bytes.Buffer
+		var nChunks int
+		n, sum, e := p.ReadChunks(NoContentLimits, size,
+			func(chunk []byte) error {
+				if size > 0 && len(chunk) > size {
+					t.Errorf("size %d: chunk of %d", size, len(chunk))
+				}
+				nChunks++
+				got.Write(chunk)
+				return nil
+			})
+		if e != nil {
+			t.Fatalf("size %d: %v", size, e)
+		}
+		if n != int64(len(content)) || got.String() != content {
+			t.Errorf("size %d: got %d bytes", size, n)
+		}
+		if sum != want {
+			t.Errorf("size %d: md5 %x, want %x", size, sum, want)
+		}
+		if p.Digest().Hex() != hex.EncodeToString(want[:]) {
+			t.Errorf("size %d: stored digest %s", size, p.Digest())
+		}
+		if size == 7 && nChunks != 1000 {
+			t.Errorf("size 7: %d chunks", nChunks)
+		}
+	}
+}
+
+func TestReadChunksErrors(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", strings.Repeat("x", 1000))
+	var stop = errors.New("stop")
+	var calls int
+	n, _, e := NewFSObject(path).ReadChunks(NoContentLimits, 100,
+		func([]byte) error {
+			if calls++; calls == 3 {
+				return stop
+			}
+			return nil
+		})
+	if e != stop || n != 300 {
+		t.Errorf("callback error: got %d, %v", n, e)
+	}
+	_, _, e = NewFSObject(path).ReadChunks(ContentLimits{MaxSize: 999}, 0, nil)
+	if !errors.Is(e, ErrTooLarge) {
+		t.Errorf("limit: got %v, want ErrTooLarge", e)
+	}
+}
+
+func TestHashingReader(t *testing.T) {
+	var content = "The quick brown fox"
+	var h = md5.New()
+	var hr = NewHashingReader(strings.NewReader(content), h)
+	if _, e := io.Copy(io.Discard, hr); e != nil {
+		t.Fatal(e)
+	}
+	var want = md5.Sum([]byte(content))
+	if hr.N != int64(len(content)) || !bytes.Equal(h.Sum(nil), want[:]) {
+		t.Errorf("got %d bytes, %x", hr.N, h.Sum(nil))
+	}
+}
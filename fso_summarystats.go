@@ -2,32 +2,54 @@ package fileutils
 
 // FSObjectSummaryStats is so that NrItems equals the sum of
 // NrDirs + NrFiles + NrSymLs + NrMiscs; NrErrors is independent.
+// NrMiscs is broken down (as a subset) into NrPipes + NrSockets
+// + NrDevices + any others (see [FSO_type]).
 type FSObjectSummaryStats struct {
      NrItems, NrDirs, NrFiles, NrSymLs, NrMiscs, NrErrors int
+     NrPipes, NrSockets, NrDevices int
 }
 
-/*
+// AddIn counts the FSObject. An FSObject with no
+// FileInfo (e.g. it does not exist) counts only as
+// an item, and (if it has one) as an error.
 func (pSS *FSObjectSummaryStats) AddIn(pI *FSObject) {
-	if pFSI.IsDir() {
-                if pFSI.TypedRaw == nil {
-                   pFSI.TypedRaw = new(CT.TypedRaw)
-                   }
-                pFSI.TypedRaw.Raw_type = SU.Raw_type_DIRLIKE
-                pMFT.nDirs++ // just a simple counter
-            } else if pFSI.Type() == 0 { // regular file
-                pMFT.nFiles++ // just a simple counter
-            } else if (pFSI.Type() & fs.ModeSymlink) != 0 { // Symlink
-                if pFSI.TypedRaw == nil {
-                   pFSI.TypedRaw = new(CT.TypedRaw)
-                }
-                pFSI.TypedRaw.Raw_type = SU.Raw_type_DIRLIKE // OOPS
-                pMFT.nMiscs++ // just a simple counter
-                L.L.Okay("Item (SYML) OK: what to do ?!")
-            } else { // Some weirdness in the Mode bits
-                pFSI.TypedRaw.Raw_type = SU.Raw_type_DIRLIKE
-             // pMFT.nMiscs++ // just a simple counter
-                pMFT.nErrors++
-                L.L.E
+	pSS.NrItems++
+	if pI.HasError() {
+		pSS.NrErrors++
+	}
+	if pI.FileInfo == nil {
+		return
+	}
+	switch t := FSO_typeOf(pI.Mode()); t {
+	case FSO_type_DIRR:
+		pSS.NrDirs++
+	case FSO_type_FILE:
+		pSS.NrFiles++
+	case FSO_type_SYML:
+		pSS.NrSymLs++
+	default:
+		pSS.NrMiscs++
+		switch t {
+		case FSO_type_PIPE:
+			pSS.NrPipes++
+		case FSO_type_SOCK:
+			pSS.NrSockets++
+		case FSO_type_CDEV, FSO_type_BDEV:
+			pSS.NrDevices++
+		}
+	}
 }
-*/
 
+// Add adds in the counts of another FSObjectSummaryStats,
+// such as for a subtree.
+func (pSS *FSObjectSummaryStats) Add(o FSObjectSummaryStats) {
+	pSS.NrItems += o.NrItems
+	pSS.NrDirs += o.NrDirs
+	pSS.NrFiles += o.NrFiles
+	pSS.NrSymLs += o.NrSymLs
+	pSS.NrMiscs += o.NrMiscs
+	pSS.NrErrors += o.NrErrors
+	pSS.NrPipes += o.NrPipes
+	pSS.NrSockets += o.NrSockets
+	pSS.NrDevices += o.NrDevices
+}
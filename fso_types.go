@@ -1,9 +1,15 @@
 package fileutils
 
 import(
+	"io/fs"
 	D "github.com/fbaube/dsmnd"
 )
 
+// FSO_type is the type of file system object. The first four
+// are also [D.SemanticFieldType]s; the special files (pipes,
+// sockets, devices) are all "other" to the DB (see func SFT),
+// but are kept distinct here because they cannot be read like
+// a file: opening a named pipe for reading can block forever.
 type FSO_type D.SemanticFieldType
 
 const(
@@ -12,8 +18,83 @@ const(
 	FSO_type_SYML FSO_type = FSO_type(D.SFT_FSYML)
 	FSO_type_OTHR FSO_type = FSO_type(D.SFT_FSOTH)
 	// tem_type_ FSO_type = FSO_type(D.SFT_FS)
+
+	// FSO_type_PIPE is a named pipe (FIFO).
+	FSO_type_PIPE FSO_type = "fspip"
+	// FSO_type_SOCK is a Unix domain socket.
+	FSO_type_SOCK FSO_type = "fssok"
+	// FSO_type_CDEV is a character device node.
+	FSO_type_CDEV FSO_type = "fscdv"
+	// FSO_type_BDEV is a block device node.
+	FSO_type_BDEV FSO_type = "fsbdv"
 )
 
+// FSO_typeOf classifies a [fs.FileMode]. Anything that is not
+// a file, directory, symlink, pipe, socket, or device (such as
+// [fs.ModeIrregular]) is FSO_type_OTHR.
+func FSO_typeOf(m fs.FileMode) FSO_type {
+	switch {
+	case m.IsRegular():
+		return FSO_type_FILE
+	case m.IsDir():
+		return FSO_type_DIRR
+	case m&fs.ModeSymlink != 0:
+		return FSO_type_SYML
+	case m&fs.ModeNamedPipe != 0:
+		return FSO_type_PIPE
+	case m&fs.ModeSocket != 0:
+		return FSO_type_SOCK
+	case m&fs.ModeCharDevice != 0:
+		return FSO_type_CDEV
+	case m&fs.ModeDevice != 0:
+		return FSO_type_BDEV
+	}
+	return FSO_type_OTHR
+}
+
+// IsSpecial is true for a pipe, socket,
+// device, or other non-file non-dir non-link.
+func (t FSO_type) IsSpecial() bool {
+	switch t {
+	case FSO_type_PIPE, FSO_type_SOCK, FSO_type_CDEV,
+		FSO_type_BDEV, FSO_type_OTHR:
+		return true
+	}
+	return false
+}
+
+// SFT maps the FSO_type to its [D.SemanticFieldType],
+// which is SFT_FSOTH for all the special files.
+func (t FSO_type) SFT() D.SemanticFieldType {
+	if t.IsSpecial() {
+		return D.SFT_FSOTH
+	}
+	return D.SemanticFieldType(t)
+}
+
+// LongName is for listings.
+func (t FSO_type) LongName() string {
+	switch t {
+	case FSO_type_DIRR:
+		return "directory"
+	case FSO_type_FILE:
+		return "file"
+	case FSO_type_SYML:
+		return "symlink"
+	case FSO_type_PIPE:
+		return "named pipe"
+	case FSO_type_SOCK:
+		return "socket"
+	case FSO_type_CDEV:
+		return "char device"
+	case FSO_type_BDEV:
+		return "block device"
+	case FSO_type_OTHR:
+		return "other"
+	}
+	return "?"
+}
+
 /*
 // FSYS: FILE SYSTEM ITEMS (4) 
 {BDT_FSYS.DT(), SFT_FSDIR.S(), "Dir",  "FS directory (unord|ord)"}, 
@@ -0,0 +1,126 @@
+//go:build linux || darwin
+
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	FP "path/filepath"
+	"testing"
+	"time"
+
+	D "github.com/fbaube/dsmnd"
+	"golang.org/x/sys/unix"
+)
+
+func TestFSO_typeOf(t *testing.T) {
+	var tests = []struct {
+		mode    fs.FileMode
+		want    FSO_type
+		special bool
+		long    string
+	}{
+		{0644, FSO_type_FILE, false, "file"},
+		{fs.ModeDir | 0755, FSO_type_DIRR, false, "directory"},
+		{fs.ModeSymlink | 0777, FSO_type_SYML, false, "symlink"},
+		{fs.ModeNamedPipe | 0600, FSO_type_PIPE, true, "named pipe"},
+		{fs.ModeSocket | 0755, FSO_type_SOCK, true, "socket"},
+		{fs.ModeDevice | fs.ModeCharDevice | 0666, FSO_type_CDEV, true, "char device"},
+		{fs.ModeDevice | 0660, FSO_type_BDEV, true, "block device"},
+		{fs.ModeIrregular, FSO_type_OTHR, true, "other"},
+	}
+	for _, tc := range tests {
+		var got = FSO_typeOf(tc.mode)
+		if got != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.mode, got, tc.want)
+		}
+		if got.IsSpecial() != tc.special || got.LongName() != tc.long {
+			t.Errorf("%v: special %v, long name %q",
+				tc.mode, got.IsSpecial(), got.LongName())
+		}
+		// The DB sees all the special files as "other".
+		if tc.special && got.SFT() != D.SFT_FSOTH {
+			t.Errorf("%v: SFT %s", tc.mode, got.SFT())
+		}
+		if !tc.special && got.SFT() != D.SemanticFieldType(tc.want) {
+			t.Errorf("%v: SFT %s", tc.mode, got.SFT())
+		}
+	}
+	if FSO_type("nope").LongName() != "?" {
+		t.Error("unknown type has a long name")
+	}
+}
+
+// TestSpecialFiles checks that special files are classified,
+// and that asking for their content neither hangs nor fails.
+func TestSpecialFiles(t *testing.T) {
+	var dir = t.TempDir()
+	var fifo = FP.Join(dir, "fifo")
+	if e := unix.Mkfifo(fifo, 0600); e != nil {
+		t.Fatal(e)
+	}
+	var sock = FP.Join(dir, "sock")
+	ln, e := net.Listen("unix", sock)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer ln.Close()
+	var tests = []struct {
+		path string
+		want FSO_type
+	}{
+		{fifo, FSO_type_PIPE},
+		{sock, FSO_type_SOCK},
+		{os.DevNull, FSO_type_CDEV},
+	}
+	for _, tc := range tests {
+		var p = NewFSObject(tc.path)
+		if p.HasError() {
+			t.Fatalf("%s: %v", tc.path, p.GetError())
+		}
+		if p.FSO_type != tc.want || p.IsFile() {
+			t.Errorf("%s: got %s", tc.path, p.FSO_type)
+		}
+		var done = make(chan struct{})
+		go func() {
+			defer close(done)
+			if s, e := p.Contents(); s != "" || e != nil {
+				t.Errorf("%s: Contents: %q, %v", tc.path, s, e)
+			}
+			if _, e := p.OpenContent(NoContentLimits); !errors.Is(e, ErrNotAFile) {
+				t.Errorf("%s: OpenContent: got %v", tc.path, e)
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: reading the content hung", tc.path)
+		}
+	}
+}
+
+// TestOpenRegularFifo checks that a file that was replaced by a
+// named pipe after it was stat'd is refused, rather than opened.
+func TestOpenRegularFifo(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f", "x")
+	var p = NewFSObject(path)
+	os.Remove(path)
+	if e := unix.Mkfifo(path, 0600); e != nil {
+		t.Fatal(e)
+	}
+	var done = make(chan error)
+	go func() {
+		_, _, e := p.ReadChunks(NoContentLimits, 0, nil)
+		done <- e
+	}()
+	select {
+	case e := <-done:
+		if !errors.Is(e, ErrNotAFile) {
+			t.Errorf("got %v, want ErrNotAFile", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("open hung")
+	}
+}
@@ -25,6 +25,10 @@ import(
 //    only one item, the `inpath` itself.
 //  - A symlink to a directory it is followed; behavior 
 //    for a symlink to a file is not easily summarised. 
+//
+// Walk errors are ignored, so (for example) the contents of a 
+// directory that cannot be read are simply missing. To find out 
+// about them, or to get FSObjects rather than names, use [WalkTree].
 // 
 // The docu for [os.Dirfs] states:
 // The result implements io/fs.StatFS, io/fs.ReadFileFS and io/fs.ReadDirFS.
@@ -41,8 +45,16 @@ import(
 //    ReadDir(name string) ([]fs.DirEntry, error)
 // . 
 func GatherDirTreeList(path string) (paths []string) {
-     	fsys := os.DirFS(path)
-	fs.WalkDir(fsys, ".",
+	return GatherDirTreeListFS(os.DirFS(path), ".")
+}
+
+// GatherDirTreeListFS is [GatherDirTreeList] for any [fs.FS], 
+// starting at root (use "." for the whole fs.FS). Item names
+// are relative to the fs.FS, not to root, which makes them
+// usable directly with [NewFSObjectFromFS]. 
+// .
+func GatherDirTreeListFS(fsys fs.FS, root string) (paths []string) {
+	fs.WalkDir(fsys, root,
 		func(pathbase string, de fs.DirEntry, e error) error {
 			paths = append(paths, pathbase)
 		return nil
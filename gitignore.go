@@ -0,0 +1,457 @@
+package fileutils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	FP "path/filepath"
+	S "strings"
+	"sync"
+	"syscall"
+)
+
+// IgnoreRule is one pattern from a .gitignore file (or from
+// .git/info/exclude, or added by [GitIgnore.AddPatterns]),
+// parsed per gitignore(5).
+type IgnoreRule struct {
+	// Pattern is the line as written (less trailing spaces).
+	Pattern string
+	// Source is the file that the rule came from, relative
+	// to the top of the repository (see [GitIgnore]), and
+	// Line is its line number (starting at 1).
+	Source string
+	Line   int
+	// Base is the directory that the rule applies below,
+	// relative to the top, with slashes; "" is the top.
+	Base string
+	// Negate is for a pattern that starts with "!", which
+	// re-includes a path that an earlier rule excluded.
+	Negate bool
+	// DirOnly is for a pattern that ends with "/".
+	DirOnly bool
+	// Anchored is for a pattern with a "/" at the start or
+	// in the middle, which is matched against the path
+	// relative to Base. Otherwise it is matched against
+	// the name of the item at any level below Base.
+	Anchored bool
+	segs     []string
+}
+
+func (r *IgnoreRule) String() string {
+	var src = r.Source
+	if src == "" {
+		src = "(added)"
+	}
+	return fmt.Sprintf("%s:%d: %q", src, r.Line, r.Pattern)
+}
+
+// IgnoreDecision is the result of [GitIgnore.Match]: whether the
+// path is ignored, and which rule decided it. If no rule matched,
+// Rule is nil (and the path is not ignored).
+type IgnoreDecision struct {
+	Ignored bool
+	Rule    *IgnoreRule
+	// Dir is set if the path is ignored because a directory
+	// that contains it is ignored. (As in git, a path in an
+	// ignored directory cannot be re-included.) It is relative
+	// to the top of the repository, like [IgnoreRule.Base].
+	Dir string
+	// Err is set if a .gitignore that applies to the path
+	// could not be read (and so was skipped), and this is
+	// the first decision that needed it.
+	Err error
+}
+
+// Reason is a human-readable explanation, like the
+// output of "git check-ignore -v", or "" if no rule
+// matched.
+func (d IgnoreDecision) Reason() string {
+	if d.Rule == nil {
+		return ""
+	}
+	var s = "excluded by " + d.Rule.String()
+	if !d.Ignored {
+		s = "re-included by " + d.Rule.String()
+	}
+	if d.Dir != "" {
+		s += " (for directory " + d.Dir + "/)"
+	}
+	return s
+}
+
+// GitIgnore applies gitignore rules to the paths in the tree at
+// a root directory. If root is in a git repository (at its top,
+// or in a subdirectory), it reads (as git does) .git/info/exclude
+// and the .gitignore of the top and of each directory down to
+// root, when it is created, and the .gitignore of each directory
+// below root the first time that it is needed. As in git, a rule
+// in a deeper .gitignore takes precedence over one in a shallower
+// one, and within a file, the last matching rule wins. If root is
+// not in a repository, it is the top.
+//
+// Paths passed in are relative to root, with slashes. To use it
+// in a walk, see [GitIgnore.Include] and [GitIgnore.Prune]; the
+// walk's root must be the same as the GitIgnore's.
+//
+// A GitIgnore is safe for concurrent use.
+// .
+type GitIgnore struct {
+	root string
+	// top is the top of the repository (or root),
+	// and prefix is root relative to it, with
+	// slashes, or "" if root is the top.
+	top, prefix string
+	// Trace, if set, is called by the filters from [Include]
+	// and [Prune] for every path that a rule matched, so that
+	// every decision can be logged with its reason, and also
+	// for a decision that has an error (see [IgnoreDecision]).
+	// Note that a directory can be traced twice in a walk, if
+	// both Prune and Include are used.
+	Trace func(rel string, d IgnoreDecision)
+
+	mu sync.Mutex
+	// global is from .git/info/exclude and AddPatterns,
+	// and has the lowest precedence.
+	global []*IgnoreRule
+	// perDir is the rules of each directory's .gitignore
+	// (by path relative to top); a key is present once
+	// the directory has been read.
+	perDir map[string][]*IgnoreRule
+	// errs is every .gitignore that could not be read.
+	errs []error
+}
+
+// NewGitIgnore creates a [GitIgnore] for the tree at root. It is
+// not an error if there are no .gitignore files, or if root is
+// not in a git repository.
+func NewGitIgnore(root string) (*GitIgnore, error) {
+	var g = &GitIgnore{root: root, top: root,
+		perDir: make(map[string][]*IgnoreRule)}
+	top, gitDir, e := findRepoTop(root)
+	if e != nil {
+		return nil, e
+	}
+	if gitDir != "" {
+		rel, e := FP.Rel(top, root)
+		if e != nil {
+			return nil, &fs.PathError{Op: "gitignore.new", Path: root, Err: e}
+		}
+		g.top = top
+		if rel != "." {
+			g.prefix = FP.ToSlash(rel)
+		}
+		rr, e := readIgnoreFile(FP.Join(gitDir, "info", "exclude"),
+			".git/info/exclude", "")
+		if e != nil {
+			return nil, e
+		}
+		g.global = rr
+	}
+	// The top and every directory down to root
+	var dir = ""
+	for _, part := range append([]string{""}, splitRel(g.prefix)...) {
+		dir = path.Join(dir, part)
+		if _, e := g.rulesFor(dir); e != nil {
+			return nil, e
+		}
+	}
+	return g, nil
+}
+
+// findRepoTop looks in root and then in each directory above it
+// for a .git (a directory, or a file "gitdir: ...", as in a worktree
+// or a submodule), and returns the directory that has it and the
+// git directory, or "" and "" if there is none.
+// .
+func findRepoTop(root string) (top, gitDir string, e error) {
+	dir, e := FP.Abs(root)
+	if e != nil {
+		return "", "", &fs.PathError{Op: "gitignore.new", Path: root, Err: e}
+	}
+	for {
+		if gitDir = findGitDir(dir); gitDir != "" {
+			return dir, gitDir, nil
+		}
+		var up = FP.Dir(dir)
+		if up == dir {
+			return "", "", nil
+		}
+		dir = up
+	}
+}
+
+// findGitDir returns dir's .git directory, following a .git
+// file ("gitdir: ...", as in a worktree or a submodule), or ""
+// if there is none.
+func findGitDir(dir string) string {
+	var dotGit = FP.Join(dir, ".git")
+	fi, e := os.Stat(dotGit)
+	if e != nil {
+		return ""
+	}
+	if fi.IsDir() {
+		return dotGit
+	}
+	bb, e := os.ReadFile(dotGit)
+	if e != nil {
+		return ""
+	}
+	var gd, ok = S.CutPrefix(S.TrimSpace(string(bb)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gd = FP.FromSlash(S.TrimSpace(gd))
+	if !FP.IsAbs(gd) {
+		gd = FP.Join(dir, gd)
+	}
+	return gd
+}
+
+// AddPatterns adds rules (with the lowest precedence, like those
+// in .git/info/exclude) that apply to the whole tree below root
+// (so an anchored pattern is relative to root). source is used
+// in their [IgnoreDecision.Reason].
+func (g *GitIgnore) AddPatterns(source string, patterns ...string) {
+	var rr = ParseIgnorePatterns(source, g.prefix,
+		[]byte(S.Join(patterns, "\n")))
+	g.mu.Lock()
+	g.global = append(g.global, rr...)
+	g.mu.Unlock()
+}
+
+// ParseIgnorePatterns parses the content of a .gitignore file
+// that is in directory base (relative to the top, with slashes;
+// "" is the top). source is for [IgnoreRule.Source].
+// Blank lines and comments are skipped.
+func ParseIgnorePatterns(source, base string, content []byte) []*IgnoreRule {
+	var rr []*IgnoreRule
+	var sc = bufio.NewScanner(bytes.NewReader(content))
+	var line int
+	for sc.Scan() {
+		line++
+		if r := parseIgnoreLine(sc.Text()); r != nil {
+			r.Source, r.Line, r.Base = source, line, base
+			rr = append(rr, r)
+		}
+	}
+	return rr
+}
+
+func parseIgnoreLine(s string) *IgnoreRule {
+	s = S.TrimSuffix(s, "\r")
+	// Trailing spaces are ignored unless escaped.
+	for S.HasSuffix(s, " ") && !S.HasSuffix(s, "\\ ") {
+		s = s[:len(s)-1]
+	}
+	if s == "" || s[0] == '#' {
+		return nil
+	}
+	var r = &IgnoreRule{Pattern: s}
+	if s[0] == '!' {
+		r.Negate = true
+		s = s[1:]
+	} else if S.HasPrefix(s, "\\!") || S.HasPrefix(s, "\\#") {
+		s = s[1:]
+	}
+	if S.HasSuffix(s, "/") {
+		r.DirOnly = true
+		s = S.TrimRight(s, "/")
+	}
+	if s == "" {
+		return nil
+	}
+	if S.Contains(s, "/") {
+		r.Anchored = true
+		s = S.TrimPrefix(s, "/")
+	}
+	// A class is negated with "!" in gitignore,
+	// but with "^" in path.Match.
+	s = S.ReplaceAll(s, "[!", "[^")
+	r.segs = S.Split(s, "/")
+	return r
+}
+
+// matches applies the rule to rel, which is relative
+// to the top.
+func (r *IgnoreRule) matches(rel string, isDir bool) bool {
+	if r.DirOnly && !isDir {
+		return false
+	}
+	if r.Base != "" {
+		var ok bool
+		rel, ok = S.CutPrefix(rel, r.Base+"/")
+		if !ok {
+			return false
+		}
+	}
+	if !r.Anchored {
+		var ok, _ = path.Match(r.segs[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segs, S.Split(rel, "/"), true)
+}
+
+// matchSegments matches path segments against pattern segments,
+// where a segment "**" matches zero or more path segments. It is
+// the one doublestar matcher, for [Glob] and [PathExcluder] too.
+// If inside is set, a "**" at the end matches one or more (i.e.
+// "everything inside"), which is the gitignore rule; otherwise it
+// also matches nothing, so that "a/**" matches "a" itself.
+func matchSegments(pat, name []string, inside bool) bool {
+	if len(pat) == 0 {
+		return len(name) == 0
+	}
+	if pat[0] == "**" {
+		if len(pat) == 1 && inside {
+			return len(name) > 0
+		}
+		for k := 0; k <= len(name); k++ {
+			if matchSegments(pat[1:], name[k:], inside) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(name) == 0 {
+		return false
+	}
+	var ok, _ = path.Match(pat[0], name[0])
+	return ok && matchSegments(pat[1:], name[1:], inside)
+}
+
+// readIgnoreFile reads and parses a .gitignore file. A
+// file that does not exist has no rules, and no error.
+func readIgnoreFile(fp, source, base string) ([]*IgnoreRule, error) {
+	bb, e := os.ReadFile(fp)
+	if e != nil {
+		if errors.Is(e, fs.ErrNotExist) || errors.Is(e, syscall.ENOTDIR) {
+			return nil, nil
+		}
+		return nil, &fs.PathError{Op: "gitignore.read", Path: fp, Err: e}
+	}
+	return ParseIgnorePatterns(source, base, bb), nil
+}
+
+// rulesFor returns the rules of dir's .gitignore, reading it if
+// necessary. dir is relative to the top ("" for the top). If it
+// cannot be read, the error is returned (and recorded) only once.
+func (g *GitIgnore) rulesFor(dir string) ([]*IgnoreRule, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if rr, ok := g.perDir[dir]; ok {
+		return rr, nil
+	}
+	var source = path.Join(dir, ".gitignore")
+	rr, e := readIgnoreFile(FP.Join(g.top, FP.FromSlash(source)), source, dir)
+	// Don't keep retrying a file that cannot be read.
+	g.perDir[dir] = rr
+	if e != nil {
+		g.errs = append(g.errs, e)
+	}
+	return rr, e
+}
+
+// Errors returns an error for every .gitignore
+// that could not be read (and so was skipped).
+func (g *GitIgnore) Errors() []error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]error(nil), g.errs...)
+}
+
+// Match decides whether the path rel (relative to the root, with
+// slashes) is ignored. isDir says whether it is a directory, which
+// matters for patterns that end with "/". A path in an ignored
+// directory is ignored, as in git (including when that directory
+// is above root), and so is a .git directory. A .gitignore that
+// cannot be read is skipped; its error is the one returned (the
+// first time only; see [GitIgnore.Errors]).
+// .
+func (g *GitIgnore) Match(rel string, isDir bool) (IgnoreDecision, error) {
+	rel = S.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return IgnoreDecision{}, nil
+	}
+	var parts = S.Split(path.Join(g.prefix, rel), "/")
+	var rules = g.globalRules()
+	var firstErr error
+	var d IgnoreDecision
+	for i := range parts {
+		// The rules of the directory that contains parts[i]
+		var rr, e = g.rulesFor(S.Join(parts[:i], "/"))
+		if e != nil && firstErr == nil {
+			firstErr = e
+		}
+		rules = append(rules, rr...)
+		var sub = S.Join(parts[:i+1], "/")
+		var last = i == len(parts)-1
+		if parts[i] == ".git" {
+			d = IgnoreDecision{Ignored: true, Rule: gitDirRule}
+		} else {
+			d = matchRules(rules, sub, isDir || !last)
+		}
+		if !last && d.Ignored {
+			d.Dir = sub
+			break
+		}
+	}
+	d.Err = firstErr
+	return d, firstErr
+}
+
+func (g *GitIgnore) globalRules() []*IgnoreRule {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*IgnoreRule(nil), g.global...)
+}
+
+// gitDirRule is built in, because git never looks
+// inside a .git directory, and cannot be overridden.
+var gitDirRule = &IgnoreRule{Pattern: ".git", Source: "(built-in)"}
+
+// matchRules returns the decision of the last rule that matches.
+func matchRules(rules []*IgnoreRule, rel string, isDir bool) IgnoreDecision {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].matches(rel, isDir) {
+			return IgnoreDecision{Ignored: !rules[i].Negate, Rule: rules[i]}
+		}
+	}
+	return IgnoreDecision{}
+}
+
+// Ignored is [Match] without the details.
+func (g *GitIgnore) Ignored(rel string, isDir bool) bool {
+	var d, _ = g.Match(rel, isDir)
+	return d.Ignored
+}
+
+// Include is a filter for [WalkOptions.Include] that leaves
+// out ignored paths. A .gitignore that cannot be read is
+// reported thru [GitIgnore.Trace] and [GitIgnore.Errors].
+func (g *GitIgnore) Include() WalkFilter {
+	return func(rel string, de fs.DirEntry) bool {
+		return !g.decide(rel, de.IsDir()).Ignored
+	}
+}
+
+// Prune is a filter for [WalkOptions.Prune] that stops the
+// walk from going into ignored directories (which is much
+// faster than using only [Include], and is what git does).
+// Errors are reported as for Include.
+func (g *GitIgnore) Prune() WalkFilter {
+	return func(rel string, de fs.DirEntry) bool {
+		return g.decide(rel, true).Ignored
+	}
+}
+
+func (g *GitIgnore) decide(rel string, isDir bool) IgnoreDecision {
+	var d, _ = g.Match(rel, isDir)
+	if g.Trace != nil && (d.Rule != nil || d.Err != nil) {
+		g.Trace(rel, d)
+	}
+	return d
+}
@@ -0,0 +1,294 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGitIgnoreSemantics is a table of patterns (as if they were
+// all in one .gitignore at the top), and the paths they ignore.
+func TestGitIgnoreSemantics(t *testing.T) {
+	type check struct {
+		path  string
+		isDir bool
+		want  bool
+	}
+	var tests = []struct {
+		name     string
+		patterns []string
+		checks   []check
+	}{
+		{"name at any level", []string{"*.log"}, []check{
+			{"a.log", false, true}, {"sub/deep/a.log", false, true},
+			{"a.log.txt", false, false}, {"log", false, false}}},
+		{"anchored at start", []string{"/build"}, []check{
+			{"build", true, true}, {"build", false, true},
+			{"sub/build", true, false}}},
+		{"dir only", []string{"build/"}, []check{
+			{"build", true, true}, {"build", false, false},
+			{"sub/build", true, true}, {"build/x.o", false, true}}},
+		{"anchored in middle", []string{"doc/*.txt"}, []check{
+			{"doc/a.txt", false, true}, {"doc/x/a.txt", false, false},
+			{"sub/doc/a.txt", false, false}}},
+		{"leading **", []string{"**/foo"}, []check{
+			{"foo", false, true}, {"a/b/foo", true, true},
+			{"a/foo/x", false, true}, {"afoo", false, false}}},
+		{"middle **", []string{"a/**/b"}, []check{
+			{"a/b", false, true}, {"a/x/y/b", false, true},
+			{"ab", false, false}, {"x/a/b", false, false}}},
+		// Everything inside, but not the directory itself.
+		{"trailing **", []string{"abc/**"}, []check{
+			{"abc", true, false}, {"abc/x", false, true},
+			{"abc/x/y", false, true}}},
+		{"negation", []string{"*.log", "!keep.log"}, []check{
+			{"keep.log", false, false}, {"x.log", false, true},
+			{"sub/keep.log", false, false}}},
+		// The last matching rule wins.
+		{"negation first", []string{"!keep.log", "*.log"}, []check{
+			{"keep.log", false, true}}},
+		// A path in an ignored directory cannot be re-included,
+		{"ignored dir", []string{"logs/", "!logs/keep.log"}, []check{
+			{"logs/keep.log", false, true}, {"logs", true, true}}},
+		// but one in an ignored directory's contents can.
+		{"ignored contents", []string{"logs/*", "!logs/keep.log"}, []check{
+			{"logs/keep.log", false, false}, {"logs/x", false, true},
+			{"logs", true, false}}},
+		{"escapes", []string{"\\#hash", "\\!bang", "# comment", "", "sp\\ "}, []check{
+			{"#hash", false, true}, {"!bang", false, true},
+			{"# comment", false, false}, {"sp ", false, true}, {"sp", false, false}}},
+		{"trailing spaces", []string{"a.txt   ", "b.txt\r"}, []check{
+			{"a.txt", false, true}, {"b.txt", false, true}}},
+		{"wildcards", []string{"?.c", "[!a]bc", "[0-9]*.md"}, []check{
+			{"a.c", false, true}, {"ab.c", false, false},
+			{"xbc", false, true}, {"abc", false, false},
+			{"1-intro.md", false, true}, {"intro.md", false, false}}},
+		{"star is one level", []string{"/*.txt"}, []check{
+			{"a.txt", false, true}, {"sub/a.txt", false, false}}},
+		// .git is always ignored, and cannot be re-included.
+		{"dot git", []string{"!.git"}, []check{
+			{".git", true, true}, {".git/config", false, true},
+			{".gitignore", false, false}}},
+		{"no rules", nil, []check{{"a", false, false}, {"", true, false}}},
+	}
+	for _, tc := range tests {
+		g, e := NewGitIgnore(t.TempDir())
+		if e != nil {
+			t.Fatal(e)
+		}
+		g.AddPatterns("test", tc.patterns...)
+		for _, c := range tc.checks {
+			if got := g.Ignored(c.path, c.isDir); got != c.want {
+				d, _ := g.Match(c.path, c.isDir)
+				t.Errorf("%s: %q (dir %v): got %v (%s)",
+					tc.name, c.path, c.isDir, got, d.Reason())
+			}
+		}
+	}
+}
+
+func TestParseIgnorePatterns(t *testing.T) {
+	var rr = ParseIgnorePatterns("sub/.gitignore", "sub",
+		[]byte("# c\n\n!/a/b/\n*.o\n"))
+	if len(rr) != 2 {
+		t.Fatalf("got %v", rr)
+	}
+	var r = rr[0]
+	if r.Pattern != "!/a/b/" || r.Line != 3 || r.Base != "sub" ||
+		!r.Negate || !r.DirOnly || !r.Anchored {
+		t.Errorf("got %+v", r)
+	}
+	if r = rr[1]; r.Line != 4 || r.Negate || r.DirOnly || r.Anchored {
+		t.Errorf("got %+v", r)
+	}
+	if s := r.String(); s != `sub/.gitignore:4: "*.o"` {
+		t.Errorf("String: %s", s)
+	}
+}
+
+// testRepoFiles is a git repository (just its .git
+// directory) with .gitignore files at several levels.
+var testRepoFiles = map[string]string{
+	".git/info/exclude":      "*.tmp\n",
+	".gitignore":             "*.log\n/build/\n",
+	"sub/.gitignore":         "!keep.log\n*.dat\n!keep.tmp\n",
+	"sub/deeper/.gitignore":  "/local\n",
+	"a.log":                  "",
+	"a.txt":                  "",
+	"x.tmp":                  "",
+	"x.dat":                  "",
+	"build/out.o":            "",
+	"sub/keep.log":           "",
+	"sub/keep.tmp":           "",
+	"sub/x.dat":              "",
+	"sub/build/y.o":          "",
+	"sub/local":              "",
+	"sub/deeper/local/z.txt": "",
+}
+
+func TestGitIgnoreRepo(t *testing.T) {
+	var top = writeTestTree(t, "", testRepoFiles)
+	var tests = []struct {
+		root, path string
+		isDir      bool
+		ignored    bool
+		reason     string
+	}{
+		{"", "a.log", false, true, `excluded by .gitignore:1: "*.log"`},
+		{"", "a.txt", false, false, ""},
+		{"", "x.tmp", false, true, `excluded by .git/info/exclude:1: "*.tmp"`},
+		{"", "x.dat", false, false, ""},
+		{"", "build", true, true, `excluded by .gitignore:2: "/build/"`},
+		{"", "build/out.o", false, true,
+			`excluded by .gitignore:2: "/build/" (for directory build/)`},
+		// A deeper .gitignore takes precedence.
+		{"", "sub/keep.log", false, false, `re-included by sub/.gitignore:1: "!keep.log"`},
+		{"", "sub/keep.tmp", false, false, `re-included by sub/.gitignore:3: "!keep.tmp"`},
+		{"", "sub/x.dat", false, true, `excluded by sub/.gitignore:2: "*.dat"`},
+		{"", "sub/build", true, false, ""},
+		{"", "sub/local", false, false, ""},
+		{"", "sub/deeper/local/z.txt", false, true,
+			`excluded by sub/deeper/.gitignore:1: "/local" (for directory sub/deeper/local/)`},
+		{"", ".git/info/exclude", false, true,
+			`excluded by (built-in):0: ".git" (for directory .git/)`},
+		// From a subdirectory, the .gitignore files above it apply,
+		// and paths (also in decisions) are relative to the top.
+		{"sub", "keep.log", false, false, `re-included by sub/.gitignore:1: "!keep.log"`},
+		{"sub", "x.log", false, true, `excluded by .gitignore:1: "*.log"`},
+		{"sub", "x.tmp", false, true, `excluded by .git/info/exclude:1: "*.tmp"`},
+		{"sub", "build", true, false, ""},
+		{"sub", "deeper/local", true, true, `excluded by sub/deeper/.gitignore:1: "/local"`},
+		{"sub/deeper", "local", true, true, `excluded by sub/deeper/.gitignore:1: "/local"`},
+		{"build", "out.o", false, true,
+			`excluded by .gitignore:2: "/build/" (for directory build/)`},
+	}
+	for _, tc := range tests {
+		g, e := NewGitIgnore(FP.Join(top, FP.FromSlash(tc.root)))
+		if e != nil {
+			t.Fatal(e)
+		}
+		d, e := g.Match(tc.path, tc.isDir)
+		if e != nil || d.Ignored != tc.ignored || d.Reason() != tc.reason {
+			t.Errorf("%s: %s: got %v %q, %v", tc.root, tc.path, d.Ignored, d.Reason(), e)
+		}
+	}
+}
+
+// TestGitIgnoreWorktree checks that a .git file is followed
+// to the git directory, for its info/exclude.
+func TestGitIgnoreWorktree(t *testing.T) {
+	var dir = t.TempDir()
+	writeTestFile(t, dir, "main/.git/worktrees/wt/info/exclude", "*.tmp\n")
+	writeTestFile(t, dir, "wt/.git", "gitdir: ../main/.git/worktrees/wt\n")
+	writeTestFile(t, dir, "wt/sub/.keep", "")
+	g, e := NewGitIgnore(FP.Join(dir, "wt", "sub"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if !g.Ignored("x.tmp", false) || g.Ignored("x.txt", false) {
+		t.Error("info/exclude was not used")
+	}
+	// The .git file is ignored, like a .git directory.
+	if g, _ = NewGitIgnore(FP.Join(dir, "wt")); !g.Ignored(".git", false) {
+		t.Error(".git file")
+	}
+}
+
+// TestGitIgnoreErrors uses a .gitignore that is a directory,
+// so that it cannot be read (even by root).
+func TestGitIgnoreErrors(t *testing.T) {
+	var top = writeTestTree(t, "", testRepoFiles)
+	if e := os.MkdirAll(FP.Join(top, "bad", ".gitignore"), 0755); e != nil {
+		t.Fatal(e)
+	}
+	writeTestFile(t, top, "bad/a.log", "")
+	g, e := NewGitIgnore(top)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var traced []string
+	g.Trace = func(rel string, d IgnoreDecision) {
+		if d.Err != nil {
+			traced = append(traced, rel)
+		}
+	}
+	// The error is returned once, and the file is skipped.
+	d, e := g.Match("bad/a.log", false)
+	var pe *fs.PathError
+	if !errors.As(e, &pe) || pe.Op != "gitignore.read" || d.Err != e || !d.Ignored {
+		t.Errorf("first: got %+v, %v", d, e)
+	}
+	if d, e = g.Match("bad/a.log", false); e != nil || d.Err != nil || !d.Ignored {
+		t.Errorf("again: got %+v, %v", d, e)
+	}
+	if errs := g.Errors(); len(errs) != 1 || errs[0] != pe {
+		t.Errorf("Errors: %v", errs)
+	}
+	// One that is needed by NewGitIgnore is an error there.
+	if _, e = NewGitIgnore(FP.Join(top, "bad")); e == nil {
+		t.Error("NewGitIgnore: no error")
+	}
+	// The walk's filters report it thru Trace.
+	g, _ = NewGitIgnore(top)
+	g.Trace = func(rel string, d IgnoreDecision) {
+		if d.Err != nil {
+			traced = append(traced, rel)
+		}
+	}
+	if _, e = WalkTree(context.Background(), top,
+		&WalkOptions{Include: g.Include(), Prune: g.Prune()}); e != nil {
+		t.Fatal(e)
+	}
+	if len(traced) != 1 || !strings.HasPrefix(traced[0], "bad/") || len(g.Errors()) != 1 {
+		t.Errorf("traced %v, errors %v", traced, g.Errors())
+	}
+}
+
+func TestGitIgnoreWalk(t *testing.T) {
+	var top = writeTestTree(t, "", testRepoFiles)
+	g, e := NewGitIgnore(top)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var reasons = make(map[string]string)
+	g.Trace = func(rel string, d IgnoreDecision) {
+		reasons[rel] = d.Reason()
+	}
+	pWR, e := WalkTree(context.Background(), top, &WalkOptions{Ordered: true,
+		Include: g.Include(), Prune: g.Prune()})
+	if e != nil {
+		t.Fatal(e)
+	}
+	var want = []string{".", ".gitignore", "a.txt", "sub", "sub/.gitignore",
+		"sub/build", "sub/build/y.o", "sub/deeper", "sub/deeper/.gitignore",
+		"sub/keep.log", "sub/keep.tmp", "sub/local", "x.dat"}
+	if got := walkRels(t, top, pWR.Items); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	// Pruned directories are not walked, so nothing in them is traced.
+	if reasons["build"] == "" || reasons[".git"] == "" || reasons["sub/x.dat"] == "" {
+		t.Errorf("reasons %v", reasons)
+	}
+	for rel := range reasons {
+		if strings.HasPrefix(rel, "build/") || strings.HasPrefix(rel, ".git/") {
+			t.Errorf("traced %s", rel)
+		}
+	}
+	// From a subdirectory, the walk's paths are relative to it.
+	if g, e = NewGitIgnore(FP.Join(top, "sub")); e != nil {
+		t.Fatal(e)
+	}
+	pWR, e = WalkTree(context.Background(), FP.Join(top, "sub"),
+		&WalkOptions{Ordered: true, Include: g.Include(), Prune: g.Prune()})
+	want = []string{".", ".gitignore", "build", "build/y.o", "deeper",
+		"deeper/.gitignore", "keep.log", "keep.tmp", "local"}
+	if got := walkRels(t, FP.Join(top, "sub"), pWR.Items); e != nil ||
+		!reflect.DeepEqual(got, want) {
+		t.Errorf("sub: got %v, %v", got, e)
+	}
+}
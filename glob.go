@@ -0,0 +1,246 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	S "strings"
+)
+
+// ErrBadGlob is wrapped by [CompileGlob] for a malformed pattern.
+var ErrBadGlob = errors.New("bad glob pattern")
+
+// MaxGlobAlternatives limits how many patterns brace expansion
+// can produce (see [ExpandBraces]), so that a pattern like
+// "{a,b}{c,d}{e,f}..." cannot use unlimited memory.
+const MaxGlobAlternatives = 1024
+
+// Glob is a compiled "doublestar" glob pattern, which is
+// matched against slash-separated relative paths, such as
+// "content/**/*.{md,dita}". Within a path component:
+//   - "*" matches any sequence of characters
+//   - "?" matches any one character
+//   - "[abc]", "[a-z]" match a character in the class,
+//     and "[^abc]" or "[!abc]" one that is not
+//   - "\" escapes the next character
+//
+// As a whole component, "**" matches zero or more components,
+// so "a/**" matches "a" and everything below it. Braces "{x,y}"
+// are expanded first (see [ExpandBraces]), and can be nested.
+// Note that "*" matches names that start with ".".
+// .
+type Glob struct {
+	pattern string
+	// alts is the alternatives after brace
+	// expansion, each split into components.
+	alts [][]string
+}
+
+// CompileGlob checks and compiles a pattern. A leading
+// "./" or "/" is ignored, because paths are relative.
+func CompileGlob(pattern string) (*Glob, error) {
+	var g = &Glob{pattern: pattern}
+	var p = pattern
+	for S.HasPrefix(p, "./") {
+		p = p[2:]
+	}
+	p = S.TrimLeft(p, "/")
+	ss, e := ExpandBraces(p)
+	if e != nil {
+		return nil, e
+	}
+	for _, s := range ss {
+		var segs = S.Split(S.TrimSuffix(s, "/"), "/")
+		for i, seg := range segs {
+			segs[i] = classBangToCaret(seg)
+			if _, e := path.Match(segs[i], ""); e != nil {
+				return nil, fmt.Errorf("%w: %q: %w", ErrBadGlob, pattern, e)
+			}
+		}
+		g.alts = append(g.alts, segs)
+	}
+	return g, nil
+}
+
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// GlobMatch is [CompileGlob] and then [Glob.Match].
+func GlobMatch(pattern, rel string) (bool, error) {
+	g, e := CompileGlob(pattern)
+	if e != nil {
+		return false, e
+	}
+	return g.Match(rel), nil
+}
+
+// Match is true if the pattern matches the whole of rel,
+// a slash-separated relative path (a trailing slash is
+// ignored).
+func (g *Glob) Match(rel string) bool {
+	var name = splitRel(rel)
+	for _, segs := range g.alts {
+		if matchSegments(segs, name, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// CouldMatchDir is true if the pattern could match something
+// below the directory rel (i.e. whether a walk should descend
+// into it). "." or "" is the root.
+func (g *Glob) CouldMatchDir(rel string) bool {
+	var name = splitRel(rel)
+	for _, segs := range g.alts {
+		if globPrefix(segs, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitRel(rel string) []string {
+	rel = S.Trim(rel, "/")
+	if rel == "" || rel == "." {
+		return nil
+	}
+	return S.Split(rel, "/")
+}
+
+// globPrefix is true if the pattern components could
+// match a path that has name as a proper prefix.
+func globPrefix(pat, name []string) bool {
+	for ; len(name) > 0; pat, name = pat[1:], name[1:] {
+		if len(pat) == 0 {
+			return false
+		}
+		if pat[0] == "**" {
+			return true
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+	}
+	return len(pat) > 0
+}
+
+// classBangToCaret converts a class "[!...]" to
+// "[^...]", which is what [path.Match] wants.
+func classBangToCaret(seg string) string {
+	if !S.Contains(seg, "[!") {
+		return seg
+	}
+	var sb S.Builder
+	for i := 0; i < len(seg); i++ {
+		var c = seg[i]
+		sb.WriteByte(c)
+		switch {
+		case c == '\\' && i+1 < len(seg):
+			i++
+			sb.WriteByte(seg[i])
+		case c == '[' && i+1 < len(seg) && seg[i+1] == '!':
+			sb.WriteByte('^')
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// ExpandBraces expands the alternatives in braces, so that
+// "a/{b,c{d,e}}/f" is "a/b/f", "a/cd/f", "a/ce/f". Braces that
+// contain no comma, unbalanced braces, and escaped braces and
+// commas ("\{"), are left as they are (as in bash). It is an
+// error if there would be more than [MaxGlobAlternatives].
+func ExpandBraces(pattern string) ([]string, error) {
+	var out []string
+	var e = expandBraces(pattern, &out)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %q: %w", ErrBadGlob, pattern, e)
+	}
+	return out, nil
+}
+
+func expandBraces(s string, out *[]string) error {
+	var open, close, commas = findBraces(s)
+	if open < 0 {
+		if len(*out) >= MaxGlobAlternatives {
+			return errors.New("too many alternatives")
+		}
+		*out = append(*out, s)
+		return nil
+	}
+	var pre, post = s[:open], s[close+1:]
+	var from = open + 1
+	for _, c := range append(commas, close) {
+		if e := expandBraces(pre+s[from:c]+post, out); e != nil {
+			return e
+		}
+		from = c + 1
+	}
+	return nil
+}
+
+// findBraces finds the first pair of braces that has a comma at
+// its top level, and returns the positions of the braces and of
+// those commas, or -1 if there is none.
+func findBraces(s string) (open, close int, commas []int) {
+	var depth int
+	var start = -1
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				start = i
+				commas = nil
+			}
+			depth++
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			// An unbalanced "}" is literal.
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 && len(commas) > 0 {
+				return start, i, commas
+			}
+		}
+	}
+	// An unclosed "{" is literal.
+	return -1, -1, nil
+}
+
+// GlobTree walks the tree at root (see [WalkTree]) and returns
+// the FSObjects whose paths (relative to root) match the pattern.
+// Directories that cannot contain a match are not walked at all,
+// and root itself is not in the results. Any Prune or Include in
+// opts is applied as well as the pattern.
+// .
+func GlobTree(ctx context.Context, root, pattern string, opts *WalkOptions) (*WalkResult, error) {
+	g, e := CompileGlob(pattern)
+	if e != nil {
+		return nil, e
+	}
+	var o WalkOptions
+	if opts != nil {
+		o = *opts
+	}
+	var prune, include = o.Prune, o.Include
+	o.Prune = func(rel string, d fs.DirEntry) bool {
+		return !g.CouldMatchDir(rel) || (prune != nil && prune(rel, d))
+	}
+	o.Include = func(rel string, d fs.DirEntry) bool {
+		return g.Match(rel) && (include == nil || include(rel, d))
+	}
+	o.SkipRoot = true
+	return WalkTree(ctx, root, &o)
+}
@@ -0,0 +1,221 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	FP "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		matches []string
+		not     []string
+	}{
+		{"content/**/*.{md,dita}",
+			[]string{"content/a.md", "content/x/y/b.dita"},
+			[]string{"content/a.txt", "other/a.md", "content", "content/x.md/y"}},
+		{"a/**", []string{"a", "a/b", "a/b/c"}, []string{"ab", "b/a"}},
+		{"**", []string{"", "x", "x/y"}, nil},
+		{"**/*.go", []string{"a.go", "x/y/a.go"}, []string{"a.go/x", "a.gox"}},
+		// "*" is within one component, and matches dot files.
+		{"*.go", []string{"a.go", ".hidden.go"}, []string{"x/a.go"}},
+		{"?.c", []string{"a.c"}, []string{"ab.c", ".c"}},
+		{"[a-c]*", []string{"b1", "a"}, []string{"d1"}},
+		{"[!a]*", []string{"b"}, []string{"a", "ab"}},
+		{"[^a]*", []string{"b"}, []string{"a"}},
+		{`\*`, []string{"*"}, []string{"a"}},
+		{`\[!a]`, []string{"[!a]"}, []string{"b"}},
+		{"./a/*", []string{"a/b", "a/b/"}, []string{"a/b/c"}},
+		{"/a/*", []string{"a/b"}, nil},
+		{"a/", []string{"a", "a/"}, []string{"a/b"}},
+		{"a/**/b/**/c", []string{"a/b/c", "a/x/b/y/z/c"}, []string{"a/c", "a/b"}},
+		{"{a,b{c,d}}.txt", []string{"a.txt", "bc.txt", "bd.txt"}, []string{"b.txt"}},
+		{"{a}.txt", []string{"{a}.txt"}, []string{"a.txt"}},
+	}
+	for _, tc := range tests {
+		g, e := CompileGlob(tc.pattern)
+		if e != nil {
+			t.Fatalf("%q: %v", tc.pattern, e)
+		}
+		if g.String() != tc.pattern {
+			t.Errorf("String: %q", g)
+		}
+		for _, rel := range tc.matches {
+			if !g.Match(rel) {
+				t.Errorf("%q does not match %q", tc.pattern, rel)
+			}
+		}
+		for _, rel := range tc.not {
+			if g.Match(rel) {
+				t.Errorf("%q matches %q", tc.pattern, rel)
+			}
+		}
+	}
+}
+
+// TestGlobAgrees checks that a Glob and an ExcludeGlob rule
+// (which share a matcher) agree, and that a .gitignore differs
+// only in that its trailing "**" does not match the directory.
+func TestGlobAgrees(t *testing.T) {
+	var rels = []string{"a", "a/b", "a/b/c", "b", "x/a/b"}
+	for _, pattern := range []string{"a/**", "**/b", "a/**/c", "**", "*/a/**"} {
+		var g, _ = CompileGlob(pattern)
+		px, e := NewPathExcluder(ExcludeRule{Kind: ExcludeGlob, Pattern: pattern})
+		if e != nil {
+			t.Fatal(e)
+		}
+		var rule = ParseIgnorePatterns("test", "", []byte("/"+pattern))[0]
+		for _, rel := range rels {
+			var ex, _ = px.Exclude(rel)
+			if ex != g.Match(rel) {
+				t.Errorf("%q: %q: excluder %v, glob %v", pattern, rel, ex, g.Match(rel))
+			}
+			var want = g.Match(rel)
+			if strings.HasSuffix(pattern, "/**") && rel == strings.TrimSuffix(pattern, "/**") {
+				want = false
+			}
+			if got := rule.matches(rel, true); got != want {
+				t.Errorf("%q: %q: gitignore %v", pattern, rel, got)
+			}
+		}
+	}
+}
+
+func TestGlobBad(t *testing.T) {
+	for _, pattern := range []string{"[", "a/[x", "{a,[}", "a/b\\"} {
+		if _, e := CompileGlob(pattern); !errors.Is(e, ErrBadGlob) {
+			t.Errorf("%q: got %v", pattern, e)
+		}
+	}
+	if ok, e := GlobMatch("[", "x"); ok || !errors.Is(e, ErrBadGlob) {
+		t.Errorf("GlobMatch: got %v, %v", ok, e)
+	}
+	if ok, e := GlobMatch("*/*.md", "a/b.md"); !ok || e != nil {
+		t.Errorf("GlobMatch: got %v, %v", ok, e)
+	}
+}
+
+func TestGlobCouldMatchDir(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		yes, no []string
+	}{
+		{"content/**/*.md", []string{".", "", "content", "content/x/y"},
+			[]string{"other", "contents"}},
+		{"a/b/*.go", []string{".", "a", "a/b"}, []string{"a/c", "a/b/c", "b"}},
+		{"*.go", []string{"."}, []string{"x"}},
+		{"{a,b}/c", []string{"a", "b/"}, []string{"c", "a/c"}},
+		{"**", []string{".", "x/y/z"}, nil},
+	}
+	for _, tc := range tests {
+		g, e := CompileGlob(tc.pattern)
+		if e != nil {
+			t.Fatal(e)
+		}
+		for _, rel := range tc.yes {
+			if !g.CouldMatchDir(rel) {
+				t.Errorf("%q: could not match in %q", tc.pattern, rel)
+			}
+		}
+		for _, rel := range tc.no {
+			if g.CouldMatchDir(rel) {
+				t.Errorf("%q: could match in %q", tc.pattern, rel)
+			}
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		want    []string
+	}{
+		{"abc", []string{"abc"}},
+		{"a/{b,c{d,e}}/f", []string{"a/b/f", "a/cd/f", "a/ce/f"}},
+		{"{a,b}{c,d}", []string{"ac", "ad", "bc", "bd"}},
+		{"{,x}y", []string{"y", "xy"}},
+		// Left as they are: no comma, unbalanced, escaped.
+		{"{a}", []string{"{a}"}},
+		{"a{b,c", []string{"a{b,c"}},
+		{"a}b{c,d}", []string{"a}bc", "a}bd"}},
+		{"{a}{b,c}", []string{"{a}b", "{a}c"}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{`{a\,b,c}`, []string{`a\,b`, "c"}},
+		{`{a,b\}`, []string{`{a,b\}`}},
+	}
+	for _, tc := range tests {
+		got, e := ExpandBraces(tc.pattern)
+		if e != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %q, %v", tc.pattern, got, e)
+		}
+	}
+	// 2^10 is the limit.
+	if got, e := ExpandBraces(strings.Repeat("{a,b}", 10)); e != nil ||
+		len(got) != MaxGlobAlternatives {
+		t.Errorf("at the limit: got %d, %v", len(got), e)
+	}
+	if _, e := ExpandBraces(strings.Repeat("{a,b}", 11)); !errors.Is(e, ErrBadGlob) ||
+		!strings.Contains(e.Error(), "too many alternatives") {
+		t.Errorf("over the limit: got %v", e)
+	}
+	if _, e := CompileGlob(strings.Repeat("{a,b}", 11)); !errors.Is(e, ErrBadGlob) {
+		t.Errorf("CompileGlob over the limit: got %v", e)
+	}
+}
+
+func TestGlobTree(t *testing.T) {
+	var root = writeTestTree(t, "", map[string]string{"content/a.md": "", "content/x/b.dita": "", "content/x/c.txt": "", "content/.h.md": "", "content/x/y/d.md": "", "other/e.md": "", "f.md": ""})
+	var walked []string
+	var opts = &WalkOptions{Ordered: true,
+		Prune: func(rel string, d fs.DirEntry) bool {
+			walked = append(walked, rel)
+			return rel == "content/x/y"
+		}}
+	pWR, e := GlobTree(context.Background(), root, "content/**/*.{md,dita}", opts)
+	if e != nil {
+		t.Fatal(e)
+	}
+	var want = []string{"content/.h.md", "content/a.md", "content/x/b.dita"}
+	if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// The pattern prunes first, so "other" is not even asked about.
+	if !reflect.DeepEqual(walked, []string{"content", "content/x", "content/x/y"}) {
+		t.Errorf("prune was called for %v", walked)
+	}
+	if pWR.Stats.NrItems != 3 || pWR.Stats.NrFiles != 3 {
+		t.Errorf("stats %+v", pWR.Stats)
+	}
+	// Include is applied as well as the pattern.
+	pWR, e = GlobTree(context.Background(), root, "**/*.md", &WalkOptions{Ordered: true,
+		Include: func(rel string, d fs.DirEntry) bool { return d.Name()[0] != '.' }})
+	if got := walkRels(t, root, pWR.Items); e != nil || !reflect.DeepEqual(got,
+		[]string{"content/a.md", "content/x/y/d.md", "f.md", "other/e.md"}) {
+		t.Errorf("include: got %v, %v", got, e)
+	}
+	if pWR, e = GlobTree(context.Background(), root, "a/[", nil); pWR != nil ||
+		!errors.Is(e, ErrBadGlob) {
+		t.Errorf("bad pattern: got %v", e)
+	}
+}
+
+func TestWalkTreeSkipRoot(t *testing.T) {
+	var root = writeTestTree(t, "", map[string]string{"a.txt": "", "b/c.txt": ""})
+	pWR, e := WalkTree(context.Background(), root,
+		&WalkOptions{Ordered: true, SkipRoot: true})
+	if got := walkRels(t, root, pWR.Items); e != nil ||
+		!reflect.DeepEqual(got, []string{"a.txt", "b", "b/c.txt"}) {
+		t.Errorf("got %v, %v", got, e)
+	}
+	// A root that is not walked is not skipped.
+	var file = FP.Join(root, "a.txt")
+	pWR, e = WalkTree(context.Background(), file, &WalkOptions{SkipRoot: true})
+	if e != nil || len(pWR.Items) != 1 {
+		t.Errorf("file: got %v, %v", pWR.Items, e)
+	}
+}
@@ -13,8 +13,8 @@ require (
 	github.com/fbaube/stringutils v0.0.0-20260511123541-ab996b555f8d
 	github.com/fbaube/wasmutils v0.0.0-20251129222829-80044fd986ff
 	github.com/fbaube/xmlutils v0.0.0-20240425064631-d7c56373bd9a
-	github.com/mgutz/str v1.2.0
 	github.com/nbio/xml v0.0.0-20260302224236-9f64bb3b5a9e
+	golang.org/x/sys v0.44.0
 )
 
 require (
@@ -28,7 +28,6 @@ require (
 	github.com/yuin/goldmark v1.7.13 // indirect
 	golang.org/x/exp v0.0.0-20260508232706-74f9aab9d74a // indirect
 	golang.org/x/net v0.47.0 // indirect
-	golang.org/x/sys v0.44.0 // indirect
 	golang.org/x/tools/godoc v0.1.0-deprecated // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 )
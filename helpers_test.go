@@ -0,0 +1,54 @@
+package fileutils
+
+import (
+	"os"
+	FP "path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile makes name below dir (with any directories that
+// are missing) and returns its path. It is a file that holds the
+// content, except that a name that ends in a slash is an empty
+// directory, and a name like "link -> target" is a symlink (and
+// if a symlink cannot be made, the test is skipped).
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	var target string
+	var isLink bool
+	name, target, isLink = strings.Cut(name, " -> ")
+	var path = FP.Join(dir, FP.FromSlash(name))
+	if strings.HasSuffix(name, "/") {
+		if e := os.MkdirAll(path, 0755); e != nil {
+			t.Fatal(e)
+		}
+		return path
+	}
+	if e := os.MkdirAll(FP.Dir(path), 0755); e != nil {
+		t.Fatal(e)
+	}
+	if isLink {
+		if e := os.Symlink(FP.FromSlash(target), path); e != nil {
+			t.Skip(e)
+		}
+		return path
+	}
+	if e := os.WriteFile(path, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+	return path
+}
+
+// writeTestTree is writeTestFile for each of the files (name
+// to content) below dir, or if dir is "", below a new temporary
+// directory. It returns dir.
+func writeTestTree(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	if dir == "" {
+		dir = t.TempDir()
+	}
+	for name, content := range files {
+		writeTestFile(t, dir, name, content)
+	}
+	return dir
+}
@@ -0,0 +1,391 @@
+package fileutils
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	S "strings"
+)
+
+// MarkupDialect is what kind of markup a document is, as
+// determined by [ClassifyMarkup] using the rules in the
+// file xml-doc.txt (which are from "What does XML smell
+// like?", https://www.xml.com/pub/a/2007/02/28/what-does-xml-smell-like.html).
+type MarkupDialect string
+
+const (
+	// MarkupNone is not markup (it does not start with a tag).
+	MarkupNone MarkupDialect = ""
+	// MarkupUnknown is markup, but no rule decided what kind.
+	MarkupUnknown MarkupDialect = "markup"
+	MarkupXML     MarkupDialect = "xml"
+	MarkupXHTML   MarkupDialect = "xhtml"
+	// MarkupHTML is pre-HTML5 (SGML-style) HTML.
+	MarkupHTML  MarkupDialect = "html"
+	MarkupHTML5 MarkupDialect = "html5"
+)
+
+// IsXML is true for XML and XHTML.
+func (d MarkupDialect) IsXML() bool {
+	return d == MarkupXML || d == MarkupXHTML
+}
+
+// IsHTML is true for XHTML and both kinds of HTML.
+func (d MarkupDialect) IsHTML() bool {
+	return d == MarkupXHTML || d == MarkupHTML || d == MarkupHTML5
+}
+
+// MarkupRule identifies the rule that decided a [MarkupDialect].
+type MarkupRule string
+
+const (
+	RuleNotMarkup MarkupRule = "not-markup"
+	// RuleDoctypePublicXHTML is a public ID containing "XHTML".
+	RuleDoctypePublicXHTML MarkupRule = "doctype-public-xhtml"
+	// RuleDoctypePublicHTML is a public ID containing "HTML".
+	RuleDoctypePublicHTML MarkupRule = "doctype-public-html"
+	// RuleDoctypePublicOther is any other public ID (such as
+	// DITA's), which is XML, because HTML only uses its own.
+	RuleDoctypePublicOther MarkupRule = "doctype-public-other"
+	// RuleDoctypeSystemOnly is a system ID without a public ID.
+	RuleDoctypeSystemOnly MarkupRule = "doctype-system-only"
+	// RuleDoctypeHTML5 is the empty <!DOCTYPE html>.
+	RuleDoctypeHTML5 MarkupRule = "doctype-html5"
+	// RuleRootXMLAttr is an xmlns, xmlns:*, or xml:*
+	// attribute on the root element.
+	RuleRootXMLAttr MarkupRule = "root-xml-attr"
+	// RuleXMLDecl is an <?xml ...?> declaration (when
+	// no other rule matched).
+	RuleXMLDecl MarkupRule = "xml-decl"
+	// RuleRootHTML is a root element <html> with no other
+	// evidence. It is a guess, not one of the rules.
+	RuleRootHTML MarkupRule = "root-html"
+	// RuleNoneMatched is markup that no rule could decide.
+	RuleNoneMatched MarkupRule = "none-matched"
+)
+
+// MarkupClass is the result of [ClassifyMarkup]: the dialect,
+// the rule that decided it, and the text that the rule matched
+// (such as the DOCTYPE, or the root element's attribute).
+type MarkupClass struct {
+	Dialect  MarkupDialect
+	Rule     MarkupRule
+	Evidence string
+	// XMLDecl is the <?xml ...?> declaration, if any.
+	XMLDecl string
+	// RootElm is the name of the root element, if it was reached.
+	RootElm string
+}
+
+func (mc MarkupClass) String() string {
+	var d = string(mc.Dialect)
+	if d == "" {
+		d = "none"
+	}
+	if mc.Evidence == "" {
+		return d + " (" + string(mc.Rule) + ")"
+	}
+	return d + " (" + string(mc.Rule) + ": " + mc.Evidence + ")"
+}
+
+// MarkupSniffLen is how much of a file is read to classify
+// it, which allows for long comments and internal subsets.
+const MarkupSniffLen = 64 * 1024
+
+// ClassifyMarkup applies the rules in xml-doc.txt to the start of
+// a document. The rules are applied in document order: first the
+// DOCTYPE (if any) and then the attributes of the root element.
+// If those are inconclusive, an XML declaration means XML. Comments
+// and processing instructions are skipped. A BOM is ignored, and
+// UTF-16 and UTF-32 are handled (see [DetectEncoding]).
+// .
+func ClassifyMarkup(head []byte) MarkupClass {
+	var mc MarkupClass
+	// UTF-16 and UTF-32 must be decoded first.
+	if enc := DetectEncoding(head).Encoding; enc.isWide() {
+		if s, e := DecodeToUTF8(head, enc); e == nil {
+			head = []byte(s)
+		}
+	}
+	var s = string(bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")))
+	var sawMarkup bool
+	for {
+		s = S.TrimLeft(s, " \t\r\n")
+		if s == "" || s[0] != '<' {
+			break
+		}
+		sawMarkup = true
+		switch {
+		case S.HasPrefix(s, "<?xml") && len(s) > 5 &&
+			isXMLSpace(s[5]):
+			var i = S.Index(s, "?>")
+			if i < 0 {
+				i = len(s) - 2
+			}
+			mc.XMLDecl = s[:i+2]
+			s = s[i+2:]
+			continue
+		case S.HasPrefix(s, "<?"):
+			s = skipPast(s, "?>")
+			continue
+		case S.HasPrefix(s, "<!--"):
+			s = skipPast(s, "-->")
+			continue
+		case len(s) >= 9 && S.EqualFold(s[:9], "<!DOCTYPE"):
+			var dt, rest = cutDoctype(s)
+			if classifyDoctype(dt, &mc) {
+				return mc
+			}
+			s = rest
+			continue
+		case len(s) > 1 && isNameStart(s[1]):
+			classifyRoot(s, &mc)
+			return mc
+		}
+		break
+	}
+	if !sawMarkup {
+		mc.Rule = RuleNotMarkup
+		return mc
+	}
+	if mc.XMLDecl != "" {
+		mc.Dialect, mc.Rule, mc.Evidence = MarkupXML, RuleXMLDecl, mc.XMLDecl
+		return mc
+	}
+	mc.Dialect, mc.Rule = MarkupUnknown, RuleNoneMatched
+	return mc
+}
+
+// classifyDoctype applies the DOCTYPE rules, and
+// returns true if one of them decided the dialect.
+func classifyDoctype(dt string, mc *MarkupClass) bool {
+	var name, pubID, sysID, hasPub, hasSys = parseDoctype(dt)
+	var upPub = S.ToUpper(pubID)
+	switch {
+	case hasPub && S.Contains(upPub, "XHTML"):
+		mc.Dialect, mc.Rule = MarkupXHTML, RuleDoctypePublicXHTML
+	case hasPub && S.Contains(upPub, "HTML"):
+		mc.Dialect, mc.Rule = MarkupHTML, RuleDoctypePublicHTML
+	case hasPub:
+		mc.Dialect, mc.Rule = MarkupXML, RuleDoctypePublicOther
+	case hasSys:
+		// <!DOCTYPE html SYSTEM "about:legacy-compat"> is HTML5
+		if S.EqualFold(name, "html") &&
+			S.EqualFold(sysID, "about:legacy-compat") {
+			mc.Dialect, mc.Rule = MarkupHTML5, RuleDoctypeHTML5
+		} else {
+			mc.Dialect, mc.Rule = MarkupXML, RuleDoctypeSystemOnly
+		}
+	case S.EqualFold(name, "html"):
+		mc.Dialect, mc.Rule = MarkupHTML5, RuleDoctypeHTML5
+	default:
+		return false
+	}
+	mc.Evidence = dt
+	return true
+}
+
+// classifyRoot applies the root element rule to a start tag.
+func classifyRoot(s string, mc *MarkupClass) {
+	var tag = cutTag(s)
+	var name, attrs = parseStartTag(tag)
+	mc.RootElm = name
+	for _, a := range attrs {
+		if a == "xmlns" || S.HasPrefix(a, "xmlns:") || S.HasPrefix(a, "xml:") {
+			mc.Dialect, mc.Rule, mc.Evidence = MarkupXML, RuleRootXMLAttr, a
+			if S.EqualFold(name, "html") {
+				mc.Dialect = MarkupXHTML
+			}
+			return
+		}
+	}
+	switch {
+	case mc.XMLDecl != "":
+		mc.Dialect, mc.Rule, mc.Evidence = MarkupXML, RuleXMLDecl, mc.XMLDecl
+		if S.EqualFold(name, "html") {
+			mc.Dialect = MarkupXHTML
+		}
+	case S.EqualFold(name, "html"):
+		mc.Dialect, mc.Rule, mc.Evidence = MarkupHTML, RuleRootHTML, tag
+	default:
+		mc.Dialect, mc.Rule = MarkupUnknown, RuleNoneMatched
+	}
+}
+
+func isXMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isNameStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c == '_' || c == ':' || c >= 0x80
+}
+
+// skipPast returns what follows the end marker, or "".
+func skipPast(s, end string) string {
+	if i := S.Index(s, end); i >= 0 {
+		return s[i+len(end):]
+	}
+	return ""
+}
+
+// cutDoctype splits off the DOCTYPE declaration, allowing
+// for quoted strings and an internal subset in brackets.
+func cutDoctype(s string) (dt, rest string) {
+	var quote byte
+	var depth int
+	for i := 2; i < len(s); i++ {
+		var c = s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '>' && depth <= 0:
+			return s[:i+1], s[i+1:]
+		}
+	}
+	return s, ""
+}
+
+// parseDoctype gets the fields of a DOCTYPE declaration.
+func parseDoctype(dt string) (name, pubID, sysID string, hasPub, hasSys bool) {
+	var s = S.TrimSuffix(dt[len("<!DOCTYPE"):], ">")
+	if i := S.IndexByte(s, '['); i >= 0 {
+		s = s[:i]
+	}
+	s = S.TrimLeft(s, " \t\r\n")
+	name, s = cutToken(s)
+	var kw string
+	kw, s = cutToken(s)
+	switch S.ToUpper(kw) {
+	case "PUBLIC":
+		pubID, s, hasPub = cutQuoted(s)
+		sysID, _, hasSys = cutQuoted(s)
+	case "SYSTEM":
+		sysID, _, hasSys = cutQuoted(s)
+	}
+	return
+}
+
+func cutToken(s string) (tok, rest string) {
+	s = S.TrimLeft(s, " \t\r\n")
+	var i = S.IndexAny(s, " \t\r\n\"'")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+func cutQuoted(s string) (val, rest string, ok bool) {
+	s = S.TrimLeft(s, " \t\r\n")
+	if s == "" || (s[0] != '"' && s[0] != '\'') {
+		return "", s, false
+	}
+	var i = S.IndexByte(s[1:], s[0])
+	if i < 0 {
+		return s[1:], "", true
+	}
+	return s[1 : i+1], s[i+2:], true
+}
+
+// cutTag returns the start tag, allowing for quoted ">".
+func cutTag(s string) string {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		var c = s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return s[:i+1]
+		}
+	}
+	return s
+}
+
+// parseStartTag returns the element name and the attribute names.
+func parseStartTag(tag string) (name string, attrs []string) {
+	var s = S.TrimPrefix(tag, "<")
+	s = S.TrimSuffix(S.TrimSuffix(s, ">"), "/")
+	name, s = cutName(s)
+	for {
+		s = S.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return
+		}
+		var a string
+		a, s = cutName(s)
+		if a == "" {
+			return
+		}
+		attrs = append(attrs, a)
+		s = S.TrimLeft(s, " \t\r\n")
+		if S.HasPrefix(s, "=") {
+			s = S.TrimLeft(s[1:], " \t\r\n")
+			if _, rest, ok := cutQuoted(s); ok {
+				s = rest
+			} else {
+				_, s = cutToken(s)
+			}
+		}
+	}
+}
+
+func cutName(s string) (name, rest string) {
+	var i = S.IndexAny(s, " \t\r\n=/>\"'")
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// ClassifyMarkupFile is [ClassifyMarkup] for the start
+// of the file at the path (see [MarkupSniffLen]).
+func ClassifyMarkupFile(path string) (MarkupClass, error) {
+	pF, _, e := openRegular(path)
+	if e != nil {
+		return MarkupClass{}, &fs.PathError{Op: "classifymarkup",
+			Path: path, Err: e}
+	}
+	defer pF.Close()
+	return classifyMarkupReader(pF, path)
+}
+
+func classifyMarkupReader(r io.Reader, path string) (MarkupClass, error) {
+	var bb = make([]byte, MarkupSniffLen)
+	n, e := io.ReadFull(r, bb)
+	if e != nil && e != io.EOF && e != io.ErrUnexpectedEOF {
+		return MarkupClass{}, &fs.PathError{Op: "classifymarkup",
+			Path: path, Err: e}
+	}
+	return ClassifyMarkup(bb[:n]), nil
+}
+
+// ClassifyMarkup is [ClassifyMarkup] for the FSObject. It uses
+// the content if it is loaded, and otherwise reads only the start
+// of the file, so it works for files of any size, on the host or
+// in an [fs.FS].
+// .
+func (p *FSObject) ClassifyMarkup() (MarkupClass, error) {
+	if p.TypedRaw != nil && (p.inMemory || len(p.Raw) > 0) {
+		var s = p.TypedRaw.S()
+		return ClassifyMarkup([]byte(s[:min(len(s), MarkupSniffLen)])), nil
+	}
+	rc, e := p.OpenContent(NoContentLimits)
+	if e != nil {
+		return MarkupClass{}, e
+	}
+	defer rc.Close()
+	return classifyMarkupReader(rc, p.FPs.ShortFP)
+}
@@ -0,0 +1,129 @@
+package fileutils
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+)
+
+// utf16LE encodes s as UTF-16LE, with a BOM.
+func utf16LE(s string) []byte {
+	var bb = []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		bb = binary.LittleEndian.AppendUint16(bb, u)
+	}
+	return bb
+}
+
+func TestClassifyMarkup(t *testing.T) {
+	var tests = []struct {
+		name    string
+		doc     string
+		dialect MarkupDialect
+		rule    MarkupRule
+		root    string
+	}{
+		{"text", "Just some text.", MarkupNone, RuleNotMarkup, ""},
+		{"empty", "", MarkupNone, RuleNotMarkup, ""},
+		{"dita topic",
+			`<?xml version="1.0"?>` + "\n" +
+				`<!DOCTYPE topic PUBLIC "-//OASIS//DTD DITA Topic//EN" "topic.dtd">` +
+				"\n<topic id=\"t\"/>",
+			MarkupXML, RuleDoctypePublicOther, ""},
+		{"xhtml 1.0",
+			`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" ` +
+				`"http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd"><html>`,
+			MarkupXHTML, RuleDoctypePublicXHTML, ""},
+		{"html 4",
+			`<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01//EN"><HTML>`,
+			MarkupHTML, RuleDoctypePublicHTML, ""},
+		{"html5", "<!doctype html>\n<html lang=en>",
+			MarkupHTML5, RuleDoctypeHTML5, ""},
+		{"html5 legacy", `<!DOCTYPE html SYSTEM "about:legacy-compat"><html>`,
+			MarkupHTML5, RuleDoctypeHTML5, ""},
+		{"system only", `<!DOCTYPE map SYSTEM "map.dtd"><map/>`,
+			MarkupXML, RuleDoctypeSystemOnly, ""},
+		{"xmlns", `<svg xmlns="http://www.w3.org/2000/svg" width="1"/>`,
+			MarkupXML, RuleRootXMLAttr, "svg"},
+		{"xml:lang", `<topic xml:lang="en" id="x">`,
+			MarkupXML, RuleRootXMLAttr, "topic"},
+		{"html xmlns", `<html xmlns="http://www.w3.org/1999/xhtml">`,
+			MarkupXHTML, RuleRootXMLAttr, "html"},
+		{"xml decl only", `<?xml version="1.0"?><topic id="t">`,
+			MarkupXML, RuleXMLDecl, "topic"},
+		{"xml decl html", `<?xml version="1.0"?><html>`,
+			MarkupXHTML, RuleXMLDecl, "html"},
+		{"bare html", "<html><body>hi</body></html>",
+			MarkupHTML, RuleRootHTML, "html"},
+		{"bare other", "<topic id=\"t\">", MarkupUnknown, RuleNoneMatched, "topic"},
+		// Comments and PIs are skipped, as is a BOM.
+		{"comments first",
+			"\xEF\xBB\xBF<!-- a <b> comment --><?pi x?>\n<!-- two -->\n" +
+				`<r xmlns:x="urn:x">`,
+			MarkupXML, RuleRootXMLAttr, "r"},
+		// The attribute is in a comment, not on the root.
+		{"xmlns in comment", "<!-- xmlns=\"x\" --><html>",
+			MarkupHTML, RuleRootHTML, "html"},
+		{"decl and comment only", `<?xml version="1.0"?><!-- c -->`,
+			MarkupXML, RuleXMLDecl, ""},
+		{"comment only", "<!-- c -->", MarkupUnknown, RuleNoneMatched, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var mc = ClassifyMarkup([]byte(tc.doc))
+			if mc.Dialect != tc.dialect || mc.Rule != tc.rule {
+				t.Errorf("got %s, want %s (%s)", mc, tc.dialect, tc.rule)
+			}
+			if tc.root != "" && mc.RootElm != tc.root {
+				t.Errorf("root %q, want %q", mc.RootElm, tc.root)
+			}
+		})
+	}
+}
+
+func TestClassifyMarkupUTF16(t *testing.T) {
+	var mc = ClassifyMarkup(utf16LE(`<?xml version="1.0" encoding="UTF-16"?><r xmlns="u"/>`))
+	if mc.Dialect != MarkupXML || mc.Rule != RuleRootXMLAttr {
+		t.Errorf("got %s", mc)
+	}
+}
+
+func TestMarkupDialectPredicates(t *testing.T) {
+	for d, want := range map[MarkupDialect][2]bool{
+		MarkupXML: {true, false}, MarkupXHTML: {true, true},
+		MarkupHTML: {false, true}, MarkupHTML5: {false, true},
+		MarkupUnknown: {false, false}, MarkupNone: {false, false},
+	} {
+		if d.IsXML() != want[0] || d.IsHTML() != want[1] {
+			t.Errorf("%q: IsXML %v, IsHTML %v", d, d.IsXML(), d.IsHTML())
+		}
+	}
+	if s := (MarkupClass{Rule: RuleNotMarkup}).String(); s != "none (not-markup)" {
+		t.Errorf("String: %q", s)
+	}
+}
+
+func TestFSObjectClassifyMarkup(t *testing.T) {
+	// The DOCTYPE is after a long comment, but within MarkupSniffLen.
+	var doc = "<!--" + strings.Repeat("x", 10000) + "-->" +
+		`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.1//EN" "x.dtd"><html/>`
+	var path = writeTestFile(t, t.TempDir(), "a.html", doc)
+	mc, e := NewFSObject(path).ClassifyMarkup()
+	if e != nil || mc.Dialect != MarkupXHTML {
+		t.Errorf("file: got %s, %v", mc, e)
+	}
+	if mc, e = ClassifyMarkupFile(path); e != nil || mc.Dialect != MarkupXHTML {
+		t.Errorf("ClassifyMarkupFile: got %s, %v", mc, e)
+	}
+	var fsys = fstest.MapFS{"b.xml": {Data: []byte(`<?xml version="1.0"?><b/>`)}}
+	mc, e = NewFSObjectFromFS(fsys, "b.xml").ClassifyMarkup()
+	if e != nil || mc.Dialect != MarkupXML {
+		t.Errorf("fs.FS: got %s, %v", mc, e)
+	}
+	mc, e = NewFSObjectFromContent("<html><p>x", "").ClassifyMarkup()
+	if e != nil || mc.Dialect != MarkupHTML {
+		t.Errorf("in memory: got %s, %v", mc, e)
+	}
+}
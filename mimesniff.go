@@ -0,0 +1,369 @@
+package fileutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	FP "path/filepath"
+	S "strings"
+
+	SU "github.com/fbaube/stringutils"
+)
+
+// SniffLen is how much of the start of the content
+// [SniffMimeType] looks at (more than the 512 bytes
+// of [http.DetectContentType], so that it can see a
+// few entries of a zip file, to tell OOXML from EPUB).
+const SniffLen = 8192
+
+// MimeOctetStream is the MIME type of unknown binary content.
+const MimeOctetStream = "application/octet-stream"
+
+// magicSig is a signature ("magic number") at an offset.
+type magicSig struct {
+	off  int
+	sig  string
+	mime string
+}
+
+// magicSigs are checked in order, so a longer signature
+// must precede a shorter one that is a prefix of it.
+var magicSigs = []magicSig{
+	{0, "\x89PNG\r\n\x1a\n", "image/png"},
+	{0, "\xFF\xD8\xFF", "image/jpeg"},
+	{0, "GIF87a", "image/gif"},
+	{0, "GIF89a", "image/gif"},
+	{0, "II*\x00", "image/tiff"},
+	{0, "MM\x00*", "image/tiff"},
+	{0, "\x00\x00\x01\x00", "image/x-icon"},
+	{0, "%PDF-", "application/pdf"},
+	{0, "%!PS", "application/postscript"},
+	{0, "{\\rtf", "application/rtf"},
+	{0, "PK\x03\x04", "application/zip"},
+	{0, "PK\x05\x06", "application/zip"},
+	{0, "PK\x07\x08", "application/zip"},
+	{0, "\x1F\x8B", "application/gzip"},
+	{0, "BZh", "application/x-bzip2"},
+	{0, "\xFD7zXZ\x00", "application/x-xz"},
+	{0, "\x28\xB5\x2F\xFD", "application/zstd"},
+	{0, "7z\xBC\xAF\x27\x1C", "application/x-7z-compressed"},
+	{0, "Rar!\x1A\x07", "application/vnd.rar"},
+	{257, "ustar", "application/x-tar"},
+	{0, "SQLite format 3\x00", "application/vnd.sqlite3"},
+	{0, "\x7FELF", "application/x-elf"},
+	{0, "\xFE\xED\xFA\xCE", "application/x-mach-binary"},
+	{0, "\xFE\xED\xFA\xCF", "application/x-mach-binary"},
+	{0, "\xCE\xFA\xED\xFE", "application/x-mach-binary"},
+	{0, "\xCF\xFA\xED\xFE", "application/x-mach-binary"},
+	{0, "\xCA\xFE\xBA\xBE", "application/java-vm"},
+	{0, "\x00asm", "application/wasm"},
+	{0, "OggS", "application/ogg"},
+	{0, "fLaC", "audio/flac"},
+	{0, "ID3", "audio/mpeg"},
+	{0, "wOFF", "font/woff"},
+	{0, "wOF2", "font/woff2"},
+	{0, "OTTO", "font/otf"},
+	{0, "\x00\x01\x00\x00\x00", "font/ttf"},
+}
+
+// SniffMimeType determines the MIME type of content from its first
+// bytes (see [SniffLen]). It recognises many binary formats by their
+// signatures, including the zip-based formats (OOXML, ODF, EPUB, JAR),
+// and otherwise falls back to [http.DetectContentType], which handles
+// text (incl. XML and HTML), BOMs, and some more media types.
+// .
+func SniffMimeType(head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	if len(head) == 0 {
+		return "text/plain; charset=utf-8"
+	}
+	for _, ms := range magicSigs {
+		if len(head) >= ms.off+len(ms.sig) &&
+			string(head[ms.off:ms.off+len(ms.sig)]) == ms.sig {
+			if ms.mime == "application/zip" {
+				return sniffZipMime(head)
+			}
+			return ms.mime
+		}
+	}
+	if mt := sniffContainerMime(head); mt != "" {
+		return mt
+	}
+	// MZ (DOS/Windows) is a short signature, so check more.
+	if len(head) >= 64 && head[0] == 'M' && head[1] == 'Z' {
+		// Compared as int64, so that it cannot overflow an int.
+		var peOff = int64(binary.LittleEndian.Uint32(head[60:64]))
+		if peOff+4 <= int64(len(head)) &&
+			string(head[peOff:peOff+4]) == "PE\x00\x00" {
+			return "application/vnd.microsoft.portable-executable"
+		}
+	}
+	return http.DetectContentType(head)
+}
+
+// sniffContainerMime checks the RIFF and ISO-BMFF containers.
+func sniffContainerMime(head []byte) string {
+	if len(head) >= 12 && string(head[:4]) == "RIFF" {
+		switch string(head[8:12]) {
+		case "WEBP":
+			return "image/webp"
+		case "WAVE":
+			return "audio/wav"
+		case "AVI ":
+			return "video/x-msvideo"
+		}
+	}
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		switch string(head[8:12]) {
+		case "qt  ":
+			return "video/quicktime"
+		case "heic", "heix", "mif1":
+			return "image/heic"
+		case "avif":
+			return "image/avif"
+		case "M4A ":
+			return "audio/mp4"
+		}
+		return "video/mp4"
+	}
+	return ""
+}
+
+// sniffZipMime refines a zip file by its first entries, which are
+// (per their specs) "mimetype" (stored) for EPUB and ODF, and for
+// OOXML "[Content_Types].xml" and a directory for the application.
+// Only the names in the entries' headers are looked at, so a name
+// like "password/" elsewhere in the data does not count.
+// .
+func sniffZipMime(head []byte) string {
+	var off int
+	for i := 0; i < 16 && off+30 <= len(head); i++ {
+		if string(head[off:off+4]) != "PK\x03\x04" {
+			break
+		}
+		var flags = binary.LittleEndian.Uint16(head[off+6:])
+		var csize = int64(binary.LittleEndian.Uint32(head[off+18:]))
+		var nlen = int(binary.LittleEndian.Uint16(head[off+26:]))
+		var xlen = int(binary.LittleEndian.Uint16(head[off+28:]))
+		var dataOff = off + 30 + nlen + xlen
+		if off+30+nlen > len(head) {
+			break
+		}
+		var name = string(head[off+30 : off+30+nlen])
+		// Does the entry's data end within the head ?
+		var fits = int64(dataOff)+csize <= int64(len(head))
+		if name == "mimetype" && dataOff < len(head) {
+			var end int
+			if csize > 0 && fits {
+				end = dataOff + int(csize)
+			} else {
+				// Size is in a data descriptor (or
+				// beyond the head), so find the end.
+				end = min(dataOff+128, len(head))
+				if i := bytes.Index(head[dataOff:end], []byte("PK")); i >= 0 {
+					end = dataOff + i
+				}
+			}
+			var mt = S.TrimSpace(string(head[dataOff:end]))
+			if S.HasPrefix(mt, "application/") {
+				return mt
+			}
+		}
+		switch {
+		case S.HasPrefix(name, "word/"):
+			return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		case S.HasPrefix(name, "xl/"):
+			return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		case S.HasPrefix(name, "ppt/"):
+			return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+		case name == "META-INF/MANIFEST.MF":
+			return "application/java-archive"
+		}
+		// With a data descriptor, the size is not in the
+		// header, so look for the next one after the data.
+		if flags&0x08 != 0 && csize == 0 {
+			if dataOff > len(head) {
+				break
+			}
+			var i = bytes.Index(head[dataOff:], []byte("PK\x03\x04"))
+			if i < 0 {
+				break
+			}
+			off = dataOff + i
+			continue
+		}
+		if !fits {
+			break
+		}
+		off = dataOff + int(csize)
+	}
+	return "application/zip"
+}
+
+// extMimeTypes are the MIME types for file extensions that we
+// care about; they take precedence over [mime.TypeByExtension],
+// whose results depend on the host's configuration.
+var extMimeTypes = map[string]string{
+	".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg",
+	".gif": "image/gif", ".webp": "image/webp", ".bmp": "image/bmp",
+	".tif": "image/tiff", ".tiff": "image/tiff", ".ico": "image/x-icon",
+	".svg": "image/svg+xml", ".pdf": "application/pdf",
+	".zip": "application/zip", ".gz": "application/gzip",
+	".tgz": "application/gzip", ".bz2": "application/x-bzip2",
+	".xz": "application/x-xz", ".zst": "application/zstd",
+	".7z": "application/x-7z-compressed", ".rar": "application/vnd.rar",
+	".tar": "application/x-tar", ".jar": "application/java-archive",
+	".epub": "application/epub+zip",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	".odt":  "application/vnd.oasis.opendocument.text",
+	".ods":  "application/vnd.oasis.opendocument.spreadsheet",
+	".odp":  "application/vnd.oasis.opendocument.presentation",
+	".wasm": "application/wasm", ".sqlite": "application/vnd.sqlite3",
+	".db": "application/vnd.sqlite3", ".exe": "application/vnd.microsoft.portable-executable",
+	".dll":   "application/vnd.microsoft.portable-executable",
+	".class": "application/java-vm", ".mp3": "audio/mpeg",
+	".flac": "audio/flac", ".wav": "audio/wav", ".ogg": "application/ogg",
+	".mp4": "video/mp4", ".m4a": "audio/mp4", ".mov": "video/quicktime",
+	".avi": "video/x-msvideo", ".heic": "image/heic", ".avif": "image/avif",
+	".woff": "font/woff", ".woff2": "font/woff2", ".otf": "font/otf",
+	".ttf": "font/ttf", ".ps": "application/postscript",
+	".rtf": "application/rtf",
+	".txt": "text/plain", ".md": "text/markdown", ".mdita": "text/markdown",
+	".xml": "text/xml", ".dita": "text/xml", ".ditamap": "text/xml",
+	".xdita": "text/xml", ".html": "text/html", ".htm": "text/html",
+	".xhtml": "application/xhtml+xml", ".hdita": "text/html",
+	".css": "text/css", ".js": "text/javascript", ".json": "application/json",
+	".csv": "text/csv", ".yaml": "text/yaml", ".yml": "text/yaml",
+	".go": "text/plain", ".sql": "text/plain", ".sh": "text/plain",
+}
+
+// ExtMimeType returns the MIME type (without parameters) that the
+// file name's extension implies, or "" if it is unknown.
+func ExtMimeType(name string) string {
+	var ext = S.ToLower(FP.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	if mt, ok := extMimeTypes[ext]; ok {
+		return mt
+	}
+	return MimeBase(mime.TypeByExtension(ext))
+}
+
+// MimeBase strips any parameters (such as "; charset=utf-8").
+func MimeBase(mt string) string {
+	if i := S.IndexByte(mt, ';'); i >= 0 {
+		mt = mt[:i]
+	}
+	return S.ToLower(S.TrimSpace(mt))
+}
+
+// IsTextMimeType says whether the MIME type is some kind of text
+// (including XML, JSON, JavaScript, and SVG).
+func IsTextMimeType(mt string) bool {
+	mt = MimeBase(mt)
+	switch {
+	case S.HasPrefix(mt, "text/"),
+		S.HasSuffix(mt, "+xml"), S.HasSuffix(mt, "+json"),
+		mt == "application/xml", mt == "application/json",
+		mt == "application/javascript":
+		return true
+	}
+	return false
+}
+
+// MimeExtMismatch says whether the file name's extension contradicts
+// the sniffed MIME type: for example, a ".png" that is actually a
+// JPEG, a ".docx" that is not a zip, or a ".xml" that is binary.
+// Differences between kinds of text are NOT a mismatch, because
+// sniffing cannot reliably tell (say) Markdown from plain text,
+// and a zip that could not be refined is not a mismatch for a
+// zip-based format. An unknown extension is never a mismatch.
+// .
+func MimeExtMismatch(sniffed, name string) bool {
+	var want = ExtMimeType(name)
+	var got = MimeBase(sniffed)
+	if want == "" || got == "" || want == got {
+		return false
+	}
+	var wantText, gotText = IsTextMimeType(want), IsTextMimeType(got)
+	if wantText || gotText {
+		return wantText != gotText
+	}
+	// Sniffing did not recognise it.
+	if got == MimeOctetStream {
+		return false
+	}
+	if got == "application/zip" && isZipBasedMime(want) {
+		return false
+	}
+	return true
+}
+
+func isZipBasedMime(mt string) bool {
+	return mt == "application/zip" || mt == "application/java-archive" ||
+		mt == "application/epub+zip" ||
+		S.HasPrefix(mt, "application/vnd.openxmlformats-officedocument.") ||
+		S.HasPrefix(mt, "application/vnd.oasis.opendocument.")
+}
+
+// setMimeType sniffs the content and sets the fields [MimeType]
+// and [MimeExtMismatch]. Binary content is marked as such in the
+// TypedRaw, unless it has already been classified.
+func (p *FSObject) setMimeType(head []byte) {
+	p.MimeType = SniffMimeType(head)
+	// Without a BOM, UTF-16/32 looks like binary, and the
+	// charset from http.DetectContentType is just a guess.
+	if enc := p.Encoding.Encoding; enc != EncUnknown {
+		var base = MimeBase(p.MimeType)
+		if enc.isWide() && (base == MimeOctetStream ||
+			base == "text/plain") {
+			p.MimeType = "text/plain; charset=" + string(enc)
+		} else if IsTextMimeType(base) &&
+			S.Contains(p.MimeType, "charset=") {
+			p.MimeType = base + "; charset=" + string(enc)
+		}
+	}
+	var name = p.FPs.AbsFP
+	if name == "" {
+		name = p.FPs.RelFP
+	}
+	p.MimeExtMismatch = MimeExtMismatch(p.MimeType, trimPathSepSuffix(name))
+	if p.TypedRaw != nil && p.TypedRaw.Raw_type == "" &&
+		!IsTextMimeType(p.MimeType) {
+		p.TypedRaw.Raw_type = SU.Raw_type_BIN
+	}
+}
+
+// DetectMimeType sniffs the MIME type (see [SniffMimeType]) and
+// sets the fields [MimeType] and [MimeExtMismatch]. If the content
+// is not loaded, it reads only the first [SniffLen] bytes (thru
+// [OpenContent]), so it works for files of any size.
+// .
+func (p *FSObject) DetectMimeType() (string, error) {
+	if p.TypedRaw != nil && (p.inMemory || len(p.Raw) > 0) {
+		var s = p.TypedRaw.S()
+		p.setMimeType([]byte(s[:min(len(s), SniffLen)]))
+		return p.MimeType, nil
+	}
+	rc, e := p.OpenContent(NoContentLimits)
+	if e != nil {
+		return "", e
+	}
+	defer rc.Close()
+	var bb = make([]byte, SniffLen)
+	n, e := io.ReadFull(rc, bb)
+	if e != nil && e != io.EOF && e != io.ErrUnexpectedEOF {
+		return "", &fs.PathError{Op: "fso.detectmimetype",
+			Path: p.FPs.ShortFP, Err: e}
+	}
+	p.setMimeType(bb[:n])
+	return p.MimeType, nil
+}
@@ -0,0 +1,165 @@
+package fileutils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	SU "github.com/fbaube/stringutils"
+)
+
+// zipWith makes a zip of the named (empty, or stored) entries,
+// in order, so that it is like an OOXML, EPUB, or JAR file.
+func zipWith(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var zw = zip.NewWriter(&buf)
+	for _, name := range entries {
+		var hdr = &zip.FileHeader{Name: name, Method: zip.Deflate}
+		var body string
+		if name == "mimetype" {
+			hdr.Method = zip.Store
+			body = "application/epub+zip"
+		}
+		w, e := zw.CreateHeader(hdr)
+		if e != nil {
+			t.Fatal(e)
+		}
+		w.Write([]byte(body))
+	}
+	if e := zw.Close(); e != nil {
+		t.Fatal(e)
+	}
+	return buf.Bytes()
+}
+
+func TestSniffMimeType(t *testing.T) {
+	var pe = make([]byte, 256)
+	copy(pe, "MZ")
+	binary.LittleEndian.PutUint32(pe[60:], 128)
+	copy(pe[128:], "PE\x00\x00")
+
+	// An offset past the end (which is negative as an int32).
+	var badPE = bytes.Clone(pe)
+	binary.LittleEndian.PutUint32(badPE[60:], 0xFFFFFFF0)
+	// An entry whose size is past the end of the head.
+	var badZip = zipWith(t, "a.txt", "word/document.xml")
+	binary.LittleEndian.PutUint32(badZip[18:], 0xFFFFFFF0)
+
+	var tests = []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"empty", nil, "text/plain; charset=utf-8"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "image/png"},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"gzip", []byte("\x1f\x8b\x08\x00"), "application/gzip"},
+		{"tar", makeTar(t, testTarEntries[:1], false), "application/x-tar"},
+		{"elf", []byte("\x7fELF\x02\x01\x01"), "application/x-elf"},
+		{"exe", pe, "application/vnd.microsoft.portable-executable"},
+		{"not exe", []byte("MZ is not enough"), "text/plain; charset=utf-8"},
+		{"bad exe", badPE, MimeOctetStream},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom"), "video/mp4"},
+		{"heic", []byte("\x00\x00\x00\x18ftypheic"), "image/heic"},
+		{"zip", zipWith(t, "a.txt"), "application/zip"},
+		{"docx", zipWith(t, "[Content_Types].xml", "word/document.xml"),
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"xlsx", zipWith(t, "[Content_Types].xml", "_rels/.rels", "xl/workbook.xml"),
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"epub", zipWith(t, "mimetype", "META-INF/container.xml"),
+			"application/epub+zip"},
+		{"jar", zipWith(t, "META-INF/MANIFEST.MF"), "application/java-archive"},
+		// Only names of entries count, not names in the data.
+		{"not docx", zipWith(t, "password/a.txt", "keyword/b.txt", "axl/c.txt"),
+			"application/zip"},
+		{"bad zip", badZip, "application/zip"},
+		{"xml", []byte("<?xml version=\"1.0\"?><a/>"), "text/xml; charset=utf-8"},
+		{"html", []byte("<!DOCTYPE html><html></html>"), "text/html; charset=utf-8"},
+		{"text", []byte("Just text.\n"), "text/plain; charset=utf-8"},
+		{"binary", []byte{0, 1, 2, 3, 0xfe, 0xff, 0x80}, MimeOctetStream},
+	}
+	for _, tc := range tests {
+		if got := SniffMimeType(tc.head); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestMimeExtMismatch(t *testing.T) {
+	var tests = []struct {
+		sniffed, name string
+		want          bool
+	}{
+		{"image/png", "a.png", false},
+		{"image/jpeg", "a.png", true},
+		{"text/plain; charset=utf-8", "a.docx", true},
+		{"application/zip", "a.docx", false},
+		{"application/zip", "a.epub", false},
+		{"application/zip", "a.png", true},
+		{MimeOctetStream, "a.xml", true},
+		{MimeOctetStream, "a.png", false},
+		// Kinds of text are not told apart.
+		{"text/plain; charset=utf-8", "a.md", false},
+		{"text/xml; charset=utf-8", "a.dita", false},
+		{"text/html; charset=utf-8", "a.xml", false},
+		// Unknown extension, or none.
+		{"image/png", "a.qqq", false},
+		{"image/png", "README", false},
+	}
+	for _, tc := range tests {
+		if got := MimeExtMismatch(tc.sniffed, tc.name); got != tc.want {
+			t.Errorf("%s as %s: got %v", tc.name, tc.sniffed, got)
+		}
+	}
+}
+
+func TestMimeHelpers(t *testing.T) {
+	if got := MimeBase(" Text/HTML ; charset=utf-8"); got != "text/html" {
+		t.Errorf("MimeBase: %q", got)
+	}
+	for mt, want := range map[string]bool{
+		"text/plain": true, "image/svg+xml": true, "application/json": true,
+		"application/ld+json": true, "application/xml; charset=x": true,
+		"image/png": false, MimeOctetStream: false, "application/zip": false,
+	} {
+		if IsTextMimeType(mt) != want {
+			t.Errorf("IsTextMimeType(%q): got %v", mt, !want)
+		}
+	}
+	for name, want := range map[string]string{
+		"a.PNG": "image/png", "a.dita": "text/xml", "a.mdita": "text/markdown",
+		"dir/a.tar.gz": "application/gzip", "noext": "",
+	} {
+		if got := ExtMimeType(name); got != want {
+			t.Errorf("ExtMimeType(%q): got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestDetectMimeType(t *testing.T) {
+	var dir = t.TempDir()
+	// Big, so that only its head is read.
+	var png = "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 3*SniffLen)
+	var p = NewFSObject(writeTestFile(t, dir, "pic.jpg", png))
+	mt, e := p.DetectMimeType()
+	if e != nil || mt != "image/png" {
+		t.Fatalf("got %q, %v", mt, e)
+	}
+	if !p.MimeExtMismatch || p.TypedRaw != nil {
+		t.Errorf("mismatch %v, loaded %v", p.MimeExtMismatch, p.TypedRaw != nil)
+	}
+	// Loading the content also sniffs it.
+	p = NewFSObject(writeTestFile(t, dir, "pic.png", png))
+	if _, e = p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	if p.MimeType != "image/png" || p.MimeExtMismatch ||
+		p.TypedRaw.Raw_type != SU.Raw_type_BIN {
+		t.Errorf("got %q, mismatch %v, raw type %q",
+			p.MimeType, p.MimeExtMismatch, p.TypedRaw.Raw_type)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin)
+
+package fileutils
+
+import "os"
+
+func mmapFile(pF *os.File, size int) ([]byte, error) {
+	return nil, ErrMmapUnsupported
+}
+
+func munmap(bb []byte) error {
+	return nil
+}
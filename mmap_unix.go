@@ -0,0 +1,17 @@
+//go:build linux || darwin
+
+package fileutils
+
+import (
+	"os"
+	"syscall"
+)
+
+func mmapFile(pF *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(pF.Fd()), 0, size,
+		syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func munmap(bb []byte) error {
+	return syscall.Munmap(bb)
+}
@@ -0,0 +1,344 @@
+package fileutils
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	FP "path/filepath"
+	S "strings"
+
+	SU "github.com/fbaube/stringutils"
+)
+
+// MType is the kind of a markup document, analogous to a MIME type.
+// It has three parts separated by "/" (see [MTypeSub]): the markup
+// syntax ("xml", "html", "mkdn"), the document family, and the kind
+// of document in the family. The families are the three markup
+// formats of LwDITA (XDITA, HDITA, MDITA), DITA 1.x, and the plain
+// (non-DITA) formats.
+//
+// Note that an HDITA topic that uses no HDITA-specific attributes is
+// indistinguishable from plain HTML5, and an MDITA topic in the core
+// profile (i.e. without YAML front matter) from plain Markdown, so
+// these are classified as plain HTML and plain Markdown unless the
+// file extension says otherwise.
+// .
+type MType string
+
+const (
+	// MType_Unknown is not markup, or not recognised.
+	MType_Unknown     MType = ""
+	MType_XDITA_Topic MType = "xml/xdita/topic"
+	MType_XDITA_Map   MType = "xml/xdita/map"
+	MType_HDITA_Topic MType = "html/hdita/topic"
+	MType_MDITA_Topic MType = "mkdn/mdita/topic"
+	// MType_DITA_Topic is any DITA 1.x topic type
+	// (topic, concept, task, reference, etc.).
+	MType_DITA_Topic MType = "xml/dita/topic"
+	// MType_DITA_Map is any DITA 1.x map type
+	// (map, bookmap, subjectScheme, etc.).
+	MType_DITA_Map MType = "xml/dita/map"
+	MType_XML      MType = "xml/xml/doc"
+	// MType_HTML is HTML of any [MarkupDialect] (incl. XHTML).
+	MType_HTML MType = "html/html/doc"
+	MType_MKDN MType = "mkdn/mkdn/doc"
+)
+
+// part is [MTypeSub], except that it does not panic
+// for an MType with fewer parts, like [MType_Unknown].
+func (mt MType) part(i int) string {
+	var ss = S.Split(string(mt), "/")
+	if i < 0 || i >= len(ss) {
+		return ""
+	}
+	return ss[i]
+}
+
+// IsLwDITA is true for XDITA, HDITA and MDITA.
+func (mt MType) IsLwDITA() bool {
+	var fam = mt.part(1)
+	return fam == "xdita" || fam == "hdita" || fam == "mdita"
+}
+
+// IsDITA is true for LwDITA and DITA 1.x.
+func (mt MType) IsDITA() bool {
+	return mt.IsLwDITA() || mt.part(1) == "dita"
+}
+
+// IsMap is true for XDITA and DITA 1.x maps.
+func (mt MType) IsMap() bool {
+	return mt.part(2) == "map"
+}
+
+// RawType is the [SU.Raw_type] for the MType's markup syntax,
+// or "" for [MType_Unknown].
+func (mt MType) RawType() SU.Raw_type {
+	switch mt.part(0) {
+	case "xml":
+		return SU.Raw_type_XML
+	case "html":
+		return SU.Raw_type_HTML
+	case "mkdn":
+		return SU.Raw_type_MKDN
+	}
+	return ""
+}
+
+// MTypeClass is the result of [ClassifyMType]: the MType, and
+// what decided it (such as the DOCTYPE, the root element, or
+// the file extension), and the [MarkupClass] of the content.
+type MTypeClass struct {
+	MType    MType
+	Evidence string
+	Markup   MarkupClass
+}
+
+func (mc MTypeClass) String() string {
+	if mc.MType == MType_Unknown {
+		return "unknown"
+	}
+	return string(mc.MType) + " (" + mc.Evidence + ")"
+}
+
+// ditaMapRoots are the DITA 1.x root elements that are maps.
+// All others (topic, concept, task, etc.) are topics.
+var ditaMapRoots = map[string]bool{
+	"map": true, "bookmap": true, "subjectScheme": true,
+	"classifyMap": true, "learningMap": true,
+}
+
+// ditaTopicRoots are the DITA 1.x root elements
+// that are topics but are not in LwDITA.
+var ditaTopicRoots = map[string]bool{
+	"concept": true, "task": true, "reference": true,
+	"glossentry": true, "glossgroup": true, "troubleshooting": true,
+	"learningAssessment": true, "learningContent": true,
+	"learningOverview": true, "learningPlan": true,
+	"learningSummary": true, "dita": true,
+}
+
+// ClassifyMType combines the file extension of name (which can be
+// "") with the start of the content in head (which can be nil, for
+// a guess from the extension alone) to determine an [MType].
+//
+// For XML, the DOCTYPE decides: a public ID for Lightweight DITA
+// (or a system ID of lw-topic.dtd or lw-map.dtd) is XDITA, and any
+// other DITA public ID is DITA 1.x. Without a DOCTYPE, the root
+// element decides: a DITA 1.x-only root (concept, bookmap, etc.),
+// or a DITAArchVersion attribute, is DITA 1.x, and otherwise a
+// root of topic or map is XDITA. For HTML, a data-hd-class attribute
+// (or an .hdita extension) is HDITA. For non-markup content, YAML
+// front matter (or an .mdita extension) is MDITA, if the extension
+// is Markdown's or absent. Markup that [ClassifyMarkup] cannot say
+// is XML or HTML (such as a leading comment, or an inline element)
+// is treated as non-markup if the extension is Markdown's.
+//
+// head should be (at least) the first [MarkupSniffLen] bytes.
+// .
+func ClassifyMType(name string, head []byte) MTypeClass {
+	var mc MTypeClass
+	var ext = S.ToLower(FP.Ext(name))
+	if head == nil {
+		mc.MType = mtypeOfExt(ext)
+		if mc.MType != MType_Unknown {
+			mc.Evidence = "ext " + ext
+		}
+		return mc
+	}
+	mc.Markup = ClassifyMarkup(head)
+	var d = mc.Markup.Dialect
+	switch {
+	// Markdown can start with an HTML comment or inline HTML,
+	// so markup of no particular kind does not override it.
+	case d == MarkupNone, d == MarkupUnknown &&
+		mtypeOfExt(ext).RawType() == SU.Raw_type_MKDN:
+		classifyMkdn(ext, head, &mc)
+	case d.IsHTML():
+		mc.MType, mc.Evidence = MType_HTML, string(mc.Markup.Rule)
+		if bytes.Contains(head, []byte("data-hd-class")) {
+			mc.MType, mc.Evidence = MType_HDITA_Topic, "attr data-hd-class"
+		} else if ext == ".hdita" {
+			mc.MType, mc.Evidence = MType_HDITA_Topic, "ext "+ext
+		}
+	default:
+		classifyXML(ext, head, &mc)
+	}
+	return mc
+}
+
+// classifyXML handles [ClassifyMType] for XML (and
+// for markup that [ClassifyMarkup] could not decide).
+func classifyXML(ext string, head []byte, mc *MTypeClass) {
+	var root = mc.Markup.RootElm
+	switch mc.Markup.Rule {
+	case RuleDoctypePublicOther, RuleDoctypeSystemOnly:
+		var dtName, pubID, sysID, _, _ = parseDoctype(mc.Markup.Evidence)
+		var upPub = S.ToUpper(pubID)
+		var lwSys = S.HasPrefix(FP.Base(sysID), "lw-")
+		switch {
+		case S.Contains(upPub, "LIGHTWEIGHT DITA") ||
+			S.Contains(upPub, "XDITA") || lwSys:
+			mc.MType = MType_XDITA_Topic
+			if dtName == "map" {
+				mc.MType = MType_XDITA_Map
+			}
+		case S.Contains(upPub, "DITA"):
+			mc.MType = MType_DITA_Topic
+			if ditaMapRoots[dtName] {
+				mc.MType = MType_DITA_Map
+			}
+		default:
+			mc.MType = MType_XML
+		}
+		mc.Evidence = mc.Markup.Evidence
+		return
+	}
+	var isDITA1 = bytes.Contains(head, []byte("DITAArchVersion"))
+	switch {
+	case ditaMapRoots[root] && (isDITA1 || root != "map"):
+		mc.MType, mc.Evidence = MType_DITA_Map, "root <"+root+">"
+	case ditaTopicRoots[root] || (root == "topic" && isDITA1):
+		mc.MType, mc.Evidence = MType_DITA_Topic, "root <"+root+">"
+	case root == "map":
+		mc.MType, mc.Evidence = MType_XDITA_Map, "root <map>"
+	case root == "topic":
+		mc.MType, mc.Evidence = MType_XDITA_Topic, "root <topic>"
+	default:
+		// An unknown root, so the extension decides.
+		mc.MType = mtypeOfExt(ext)
+		if !mc.MType.IsDITA() || mc.MType.RawType() != SU.Raw_type_XML {
+			mc.MType = MType_XML
+			mc.Evidence = string(mc.Markup.Rule)
+		} else {
+			mc.Evidence = "ext " + ext
+		}
+	}
+}
+
+// classifyMkdn handles [ClassifyMType] for content that is not markup.
+func classifyMkdn(ext string, head []byte, mc *MTypeClass) {
+	switch ext {
+	case ".md", ".markdown", ".mdita", "":
+	default:
+		return
+	}
+	switch {
+	case hasFrontMatter(head):
+		mc.MType, mc.Evidence = MType_MDITA_Topic, "yaml front matter"
+	case ext == ".mdita":
+		mc.MType, mc.Evidence = MType_MDITA_Topic, "ext "+ext
+	case ext != "":
+		mc.MType, mc.Evidence = MType_MKDN, "ext "+ext
+	}
+}
+
+// hasFrontMatter is true if the content starts with a YAML block
+// delimited by "---" lines (the closing one can also be "...").
+func hasFrontMatter(head []byte) bool {
+	var s = string(bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")))
+	s = S.ReplaceAll(s, "\r\n", "\n")
+	if !S.HasPrefix(s, "---\n") {
+		return false
+	}
+	s = s[4:]
+	for s != "" {
+		var line string
+		line, s, _ = S.Cut(s, "\n")
+		if line == "---" || line == "..." {
+			return true
+		}
+	}
+	return false
+}
+
+// mtypeOfExt guesses an MType from a (lower-case) file extension.
+func mtypeOfExt(ext string) MType {
+	switch ext {
+	case ".dita":
+		return MType_DITA_Topic
+	case ".ditamap":
+		return MType_DITA_Map
+	case ".xdita":
+		return MType_XDITA_Topic
+	case ".xml":
+		return MType_XML
+	case ".hdita":
+		return MType_HDITA_Topic
+	case ".html", ".htm", ".xhtml":
+		return MType_HTML
+	case ".mdita":
+		return MType_MDITA_Topic
+	case ".md", ".markdown":
+		return MType_MKDN
+	}
+	return MType_Unknown
+}
+
+// setMType classifies the content in memory (if it is text) and
+// records the result in field [MType] and, as the Raw_type, in
+// the [CT.TypedRaw]. It is called when the content is loaded.
+func (p *FSObject) setMType() {
+	if p.TypedRaw == nil || !IsTextMimeType(p.MimeType) {
+		return
+	}
+	var s = p.TypedRaw.S()
+	p.storeMType(ClassifyMType(p.mtypeName(),
+		[]byte(s[:min(len(s), MarkupSniffLen)])).MType)
+}
+
+// setMTypeFromExt is for when the content has not been loaded.
+// The Raw_type is set when it is, because a non-nil TypedRaw
+// means (to [Contents]) that the content has been loaded.
+func (p *FSObject) setMTypeFromExt() {
+	p.storeMType(ClassifyMType(p.mtypeName(), nil).MType)
+}
+
+// storeMType sets field [MType] and, if the content
+// has been loaded, the Raw_type in the TypedRaw.
+func (p *FSObject) storeMType(mt MType) {
+	p.MType = mt
+	if rt := mt.RawType(); rt != "" && p.TypedRaw != nil {
+		p.TypedRaw.Raw_type = rt
+	}
+}
+
+func (p *FSObject) mtypeName() string {
+	var name = p.FPs.AbsFP
+	if name == "" {
+		name = p.FPs.RelFP
+	}
+	return trimPathSepSuffix(name)
+}
+
+// DetectMType classifies the object's content (see [ClassifyMType]),
+// reading just the start of it if it has not been loaded, and stores
+// the result in field [MType] and (if the content has been loaded)
+// as the Raw_type in the TypedRaw.
+// A directory, symlink, or special file is [MType_Unknown].
+// .
+func (p *FSObject) DetectMType() (MTypeClass, error) {
+	var head []byte
+	if p.TypedRaw != nil && (p.inMemory || len(p.Raw) > 0) {
+		var s = p.TypedRaw.S()
+		head = []byte(s[:min(len(s), MarkupSniffLen)])
+	} else {
+		if p.FileInfo != nil && !p.IsFile() {
+			return MTypeClass{}, nil
+		}
+		rc, e := p.OpenContent(NoContentLimits)
+		if e != nil {
+			return MTypeClass{}, e
+		}
+		defer rc.Close()
+		head = make([]byte, MarkupSniffLen)
+		n, e := io.ReadFull(rc, head)
+		if e != nil && e != io.EOF && e != io.ErrUnexpectedEOF {
+			return MTypeClass{}, &fs.PathError{Op: "fso.detectmtype",
+				Path: p.FPs.ShortFP, Err: e}
+		}
+		head = head[:n]
+	}
+	var mc = ClassifyMType(p.mtypeName(), head)
+	p.storeMType(mc.MType)
+	return mc, nil
+}
@@ -0,0 +1,159 @@
+package fileutils
+
+import (
+	"testing"
+
+	SU "github.com/fbaube/stringutils"
+)
+
+func TestClassifyMType(t *testing.T) {
+	const decl = `<?xml version="1.0"?>` + "\n"
+	const fm = "---\ntitle: T\nauthor: A\n---\n# T\n"
+	var tests = []struct {
+		name, file, head string
+		want             MType
+	}{
+		{"xdita doctype", "a.xml", decl + `<!DOCTYPE topic PUBLIC ` +
+			`"-//OASIS//DTD LIGHTWEIGHT DITA Topic//EN" "lw-topic.dtd"><topic id="t"/>`,
+			MType_XDITA_Topic},
+		{"xdita map system id", "a.ditamap", `<!DOCTYPE map SYSTEM "lw-map.dtd"><map/>`,
+			MType_XDITA_Map},
+		{"dita concept", "a.xml", decl + `<!DOCTYPE concept PUBLIC ` +
+			`"-//OASIS//DTD DITA Concept//EN" "concept.dtd"><concept id="c"/>`,
+			MType_DITA_Topic},
+		{"dita bookmap", "a.ditamap", `<!DOCTYPE bookmap PUBLIC ` +
+			`"-//OASIS//DTD DITA BookMap//EN" "bookmap.dtd"><bookmap/>`,
+			MType_DITA_Map},
+		// The DOCTYPE beats the extension.
+		{"other doctype", "a.dita", `<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" ` +
+			`"svg11.dtd"><svg/>`, MType_XML},
+		{"dita 1.x root", "a.xml", decl + `<task id="t">`, MType_DITA_Topic},
+		{"topic root", "a.xml", decl + `<topic id="t">`, MType_XDITA_Topic},
+		{"dita 1.x topic", "a.dita", decl + `<topic xmlns:ditaarch=` +
+			`"http://dita.oasis-open.org/architecture/2005/" ` +
+			`ditaarch:DITAArchVersion="1.3" id="t">`, MType_DITA_Topic},
+		{"map root", "a.ditamap", decl + `<map>`, MType_XDITA_Map},
+		{"dita 1.x map", "a.ditamap", decl + `<map DITAArchVersion="1.3">`,
+			MType_DITA_Map},
+		{"subject scheme", "a.ditamap", decl + `<subjectScheme>`, MType_DITA_Map},
+		{"unknown root dita ext", "a.dita", decl + `<glossary>`, MType_DITA_Topic},
+		{"unknown root xml ext", "a.xml", decl + `<glossary>`, MType_XML},
+		{"unknown root html ext", "a.hdita", decl + `<glossary>`, MType_XML},
+		{"html", "a.html", "<!DOCTYPE html>\n<html><body><p>x", MType_HTML},
+		{"hdita attr", "a.html", "<!DOCTYPE html>\n<html><body>" +
+			`<article data-hd-class="topic">`, MType_HDITA_Topic},
+		{"hdita ext", "a.hdita", "<html><body>", MType_HDITA_Topic},
+		{"mdita front matter", "a.md", fm, MType_MDITA_Topic},
+		{"mdita no ext", "README", fm, MType_MDITA_Topic},
+		{"mdita crlf dots", "a.md", "---\r\ntitle: T\r\n...\r\n# T", MType_MDITA_Topic},
+		{"mdita ext", "a.mdita", "# T\n", MType_MDITA_Topic},
+		{"markdown", "a.markdown", "# T\n\nText.\n", MType_MKDN},
+		{"front matter unclosed", "a.md", "---\ntitle: T\n# T\n", MType_MKDN},
+		{"front matter not first", "a.md", "\n---\ntitle: T\n---\n", MType_MKDN},
+		// Markdown can start with a comment, or inline HTML,
+		{"md with comment", "a.md", "<!-- c -->\n# T\n", MType_MKDN},
+		{"md with inline html", "a.md", "<span>x</span> is *y*\n", MType_MKDN},
+		// but not with an HTML document.
+		{"md that is html", "a.md", "<!DOCTYPE html><html>", MType_HTML},
+		// Front matter only means MDITA for Markdown.
+		{"txt front matter", "a.txt", fm, MType_Unknown},
+		{"text no ext", "notes", "Just text.\n", MType_Unknown},
+		{"empty", "", "", MType_Unknown},
+	}
+	for _, tc := range tests {
+		var mc = ClassifyMType(tc.file, []byte(tc.head))
+		if mc.MType != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, mc, tc.want)
+		}
+		if (mc.Evidence == "") != (tc.want == MType_Unknown) {
+			t.Errorf("%s: evidence %q", tc.name, mc.Evidence)
+		}
+	}
+}
+
+func TestClassifyMTypeByExt(t *testing.T) {
+	for name, want := range map[string]MType{
+		"a.dita": MType_DITA_Topic, "a.DITAMAP": MType_DITA_Map,
+		"a.xdita": MType_XDITA_Topic, "a.xml": MType_XML,
+		"a.hdita": MType_HDITA_Topic, "a.htm": MType_HTML,
+		"a.xhtml": MType_HTML, "a.mdita": MType_MDITA_Topic,
+		"a.md": MType_MKDN, "a.txt": MType_Unknown, "README": MType_Unknown,
+	} {
+		var mc = ClassifyMType(name, nil)
+		if mc.MType != want {
+			t.Errorf("%s: got %s, want %s", name, mc, want)
+		}
+	}
+	if s := ClassifyMType("a.txt", nil).String(); s != "unknown" {
+		t.Errorf("String: %q", s)
+	}
+	if s := ClassifyMType("a.md", nil).String(); s != "mkdn/mkdn/doc (ext .md)" {
+		t.Errorf("String: %q", s)
+	}
+}
+
+func TestMTypePredicates(t *testing.T) {
+	var tests = []struct {
+		mt                  MType
+		lwdita, dita, isMap bool
+		raw                 SU.Raw_type
+	}{
+		{MType_XDITA_Topic, true, true, false, SU.Raw_type_XML},
+		{MType_XDITA_Map, true, true, true, SU.Raw_type_XML},
+		{MType_HDITA_Topic, true, true, false, SU.Raw_type_HTML},
+		{MType_MDITA_Topic, true, true, false, SU.Raw_type_MKDN},
+		{MType_DITA_Topic, false, true, false, SU.Raw_type_XML},
+		{MType_DITA_Map, false, true, true, SU.Raw_type_XML},
+		{MType_XML, false, false, false, SU.Raw_type_XML},
+		{MType_HTML, false, false, false, SU.Raw_type_HTML},
+		{MType_MKDN, false, false, false, SU.Raw_type_MKDN},
+		// None of these may panic.
+		{MType_Unknown, false, false, false, ""},
+		{"xml", false, false, false, SU.Raw_type_XML},
+		{"xml/dita", false, true, false, SU.Raw_type_XML},
+	}
+	for _, tc := range tests {
+		if tc.mt.IsLwDITA() != tc.lwdita || tc.mt.IsDITA() != tc.dita ||
+			tc.mt.IsMap() != tc.isMap || tc.mt.RawType() != tc.raw {
+			t.Errorf("%q: got %v %v %v %q", tc.mt, tc.mt.IsLwDITA(),
+				tc.mt.IsDITA(), tc.mt.IsMap(), tc.mt.RawType())
+		}
+	}
+}
+
+func TestFSObjectMType(t *testing.T) {
+	var dir = t.TempDir()
+	// Loading the content classifies it, and sets the Raw_type.
+	var p = NewFSObject(writeTestFile(t, dir, "a.md", "---\ntitle: T\n---\n# T\n"))
+	if _, e := p.Contents(); e != nil {
+		t.Fatal(e)
+	}
+	if p.MType != MType_MDITA_Topic || p.TypedRaw.Raw_type != SU.Raw_type_MKDN {
+		t.Errorf("loaded: got %s, %s", p.MType, p.TypedRaw.Raw_type)
+	}
+	// DetectMType reads just the head, and does not load it.
+	p = NewFSObject(writeTestFile(t, dir, "b.xml",
+		`<?xml version="1.0"?><concept id="c"/>`))
+	mc, e := p.DetectMType()
+	if e != nil || mc.MType != MType_DITA_Topic || p.MType != MType_DITA_Topic ||
+		p.TypedRaw != nil {
+		t.Errorf("unloaded: got %s, %v", mc, e)
+	}
+	mc, e = NewFSObject(dir).DetectMType()
+	if e != nil || mc.MType != MType_Unknown {
+		t.Errorf("dir: got %s, %v", mc, e)
+	}
+	p = NewFSObjectFromContent(`<html><body data-hd-class="topic">`, "")
+	if p.MType != MType_HDITA_Topic || p.TypedRaw.Raw_type != SU.Raw_type_HTML {
+		t.Errorf("in memory: got %s, %s", p.MType, p.TypedRaw.Raw_type)
+	}
+	// Without the content, the extension is a guess.
+	var ps, _ = NewFSObjectSliceFromFilepathSlice([]string{
+		writeTestFile(t, dir, "c.ditamap", "<map/>"), dir + "/"})
+	if ps[0].MType != MType_DITA_Map || ps[0].TypedRaw != nil {
+		t.Errorf("slice: got %s", ps[0].MType)
+	}
+	if ps[1].MType != MType_Unknown || ps[1].TypedRaw.Raw_type != SU.Raw_type_DIRLIKE {
+		t.Errorf("slice dir: got %s", ps[1].MType)
+	}
+}
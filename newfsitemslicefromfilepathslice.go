@@ -1,7 +1,6 @@
 package fileutils
 
 import (
-	"io/fs"
 	S "strings"
 
 	SU "github.com/fbaube/stringutils"
@@ -29,32 +28,24 @@ func NewFSObjectSliceFromFilepathSlice(FPs [] string) ([]*FSObject, *FSObjectSum
 	for _, sFP := range FPs {
 	    pFSI := NewFSObject(sFP)
 	    FSIs = append(FSIs, pFSI)
-	    // Categorise the FSObject (file, dir, wotev).
-	    // Note the hacks to TypedRaw.
-	    if pFSI.IsDir() {
+	    pFSS.AddIn(pFSI)
+	    // Note the hacks to TypedRaw, for dirs & symlinks & 
+	    // specials (which have no content, and must not be
+	    // read: a named pipe can block forever).
+	    if pFSI.FileInfo != nil && (pFSI.IsDir() ||
+	       pFSI.IsSymlink() || pFSI.IsSpecial()) {
                 if pFSI.TypedRaw == nil {
                    pFSI.TypedRaw = new(CT.TypedRaw)
                    } 
                 pFSI.TypedRaw.Raw_type = SU.Raw_type_DIRLIKE
-                pFSS.NrDirs++ 
-            } else if pFSI.Type() == 0 { // regular file
-                pFSS.NrFiles++ 
-            } else if (pFSI.Type() & fs.ModeSymlink) != 0 { // Symlink
-                if pFSI.TypedRaw == nil {
-                   pFSI.TypedRaw = new(CT.TypedRaw)
-                }
-                pFSI.TypedRaw.Raw_type = SU.Raw_type_DIRLIKE 
-                pFSS.NrSymLs++ 
-                // L.L.Okay("Item (SYML) OK: what to do ?!")
-            } else { // Some weirdness in the Mode bits 
-                pFSI.TypedRaw.Raw_type = SU.Raw_type_DIRLIKE
-                pFSS.NrMiscs++ 
-                // pFSS.nErrors++
-            }
-	    if pFSI.HasError() {
-	       pFSS.NrErrors++
-	    } else
-	      if pFSI.IsDir() && !S.HasSuffix(sFP, "/") {
+            } else if pFSI.FileInfo != nil && pFSI.IsFile() {
+	        // The content is not read here, so this is just a
+		// guess from the file extension, which is refined
+		// when the content is loaded (see [ClassifyMType]).
+	        pFSI.setMTypeFromExt()
+	    }
+	    if !pFSI.HasError() && pFSI.FileInfo != nil &&
+	         pFSI.IsDir() && !S.HasSuffix(sFP, "/") {
 	   // Make sure a dir has a trailing slash (assumed as path separator)
 	   // inPath = FU.EnsureTrailingPathSep(inPath)
 	      panic("Missing trlg path sep in NewFSObjectSliceFromFilepathSlice")
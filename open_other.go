@@ -0,0 +1,6 @@
+//go:build !unix
+
+package fileutils
+
+// oNonBlock is not needed where there are no named pipes.
+const oNonBlock = 0
@@ -0,0 +1,9 @@
+//go:build unix
+
+package fileutils
+
+import "syscall"
+
+// oNonBlock keeps an open of a named pipe from blocking.
+// It has no effect on a regular file.
+const oNonBlock = syscall.O_NONBLOCK
@@ -0,0 +1,295 @@
+package fileutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	S "strings"
+)
+
+// ExcludeKind is how an [ExcludeRule] matches.
+type ExcludeKind string
+
+const (
+	ExcludePrefix   ExcludeKind = "prefix"
+	ExcludeSuffix   ExcludeKind = "suffix"
+	ExcludeContains ExcludeKind = "contains"
+	// ExcludeGlob is [path.Match], except that for target
+	// [TargetPath], "**" matches any number of directories
+	// (including none, at the end too), as for [Glob].
+	ExcludeGlob ExcludeKind = "glob"
+	// ExcludeRegex is [regexp.MatchString], so it is not
+	// anchored unless the pattern uses "^" and "$".
+	ExcludeRegex ExcludeKind = "regex"
+)
+
+// ExcludeTarget is what part of a path an [ExcludeRule]
+// is matched against. A path is split on slashes.
+type ExcludeTarget string
+
+const (
+	// TargetPath is the whole path, as passed in.
+	TargetPath ExcludeTarget = "path"
+	// TargetBase is the last component of the path
+	// (ignoring any trailing slash).
+	TargetBase ExcludeTarget = "base"
+	// TargetComponent is any component of the path.
+	TargetComponent ExcludeTarget = "component"
+)
+
+// ExcludeRule is one rule of a [PathExcluder].
+type ExcludeRule struct {
+	Kind    ExcludeKind
+	Target  ExcludeTarget
+	Pattern string
+	// Label, if set, is the reason that is given when the rule
+	// matches, instead of the default (which is like "leading-
+	// prefix<.>" for a path and "base-suffix<~>" for a base).
+	Label string
+	re    *regexp.Regexp
+}
+
+// Reason is the reason given when the rule matches.
+func (r *ExcludeRule) Reason() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	if r.Target == TargetPath || r.Target == "" {
+		switch r.Kind {
+		case ExcludePrefix:
+			return "leading-prefix<" + r.Pattern + ">"
+		case ExcludeSuffix:
+			return "trailing-suffix<" + r.Pattern + ">"
+		}
+		return string(r.Kind) + "<" + r.Pattern + ">"
+	}
+	return string(r.Target) + "-" + string(r.Kind) + "<" + r.Pattern + ">"
+}
+
+func (r *ExcludeRule) match(s string) bool {
+	switch r.Kind {
+	case ExcludePrefix:
+		return S.HasPrefix(s, r.Pattern)
+	case ExcludeSuffix:
+		return S.HasSuffix(s, r.Pattern)
+	case ExcludeContains:
+		return S.Contains(s, r.Pattern)
+	case ExcludeGlob:
+		if r.Target == TargetPath || r.Target == "" {
+			return matchSegments(S.Split(r.Pattern, "/"), S.Split(s, "/"), false)
+		}
+		var ok, _ = path.Match(r.Pattern, s)
+		return ok
+	case ExcludeRegex:
+		return r.re.MatchString(s)
+	}
+	return false
+}
+
+// matches applies the rule to the path, per its target.
+func (r *ExcludeRule) matches(s string) bool {
+	switch r.Target {
+	case TargetBase:
+		return r.match(path.Base(S.TrimSuffix(s, "/")))
+	case TargetComponent:
+		for _, c := range S.Split(S.TrimSuffix(s, "/"), "/") {
+			if r.match(c) {
+				return true
+			}
+		}
+		return false
+	}
+	return r.match(s)
+}
+
+// PathExcluder decides whether to exclude a path, using a list
+// of [ExcludeRule]s, and gives the reason (which lists every rule
+// that matched). Its method [PathExcluder.Exclude] has the same
+// signature as [ExcludeFilepath_m5] (which is now just the preset
+// [M5Excluder]), so it can be used as an [ExcludeFunc] in a walk.
+//
+// Paths use slashes, not [os.PathSeparator]. A directory can be
+// passed with a trailing slash, which (for example) stops a rule
+// for suffix "~" on the whole path from matching it.
+// .
+type PathExcluder struct {
+	// rules is unexported so that every rule is
+	// checked (and a regex compiled) by AddRule.
+	rules []ExcludeRule
+	// Keep is paths that are never excluded, such as ".".
+	Keep []string
+}
+
+// NewPathExcluder checks the rules (and compiles any regexes).
+func NewPathExcluder(rules ...ExcludeRule) (*PathExcluder, error) {
+	var px = new(PathExcluder)
+	for _, r := range rules {
+		if e := px.AddRule(r); e != nil {
+			return nil, e
+		}
+	}
+	return px, nil
+}
+
+// Rules returns (a copy of) the rules, in order.
+func (px *PathExcluder) Rules() []ExcludeRule {
+	return append([]ExcludeRule(nil), px.rules...)
+}
+
+// AddRule checks the rule and adds it. It is the only way
+// to add a rule, so a zero PathExcluder is ready to use.
+func (px *PathExcluder) AddRule(r ExcludeRule) error {
+	if r.Target == "" {
+		r.Target = TargetPath
+	}
+	switch r.Target {
+	case TargetPath, TargetBase, TargetComponent:
+	default:
+		return fmt.Errorf("pathexcluder: bad target %q", r.Target)
+	}
+	if r.Pattern == "" {
+		return fmt.Errorf("pathexcluder: empty %s pattern", r.Kind)
+	}
+	switch r.Kind {
+	case ExcludePrefix, ExcludeSuffix, ExcludeContains:
+	case ExcludeGlob:
+		for _, seg := range S.Split(r.Pattern, "/") {
+			if _, e := path.Match(seg, ""); e != nil {
+				return fmt.Errorf("pathexcluder: glob %q: %w", r.Pattern, e)
+			}
+		}
+	case ExcludeRegex:
+		var e error
+		if r.re, e = regexp.Compile(r.Pattern); e != nil {
+			return fmt.Errorf("pathexcluder: regex %q: %w", r.Pattern, e)
+		}
+	default:
+		return fmt.Errorf("pathexcluder: bad kind %q", r.Kind)
+	}
+	px.rules = append(px.rules, r)
+	return nil
+}
+
+// Exclude returns true (plus a reason) if any rule matches.
+// The reason lists (in order) every rule that matched, each
+// followed by a space, as for [ExcludeFilepath_m5].
+func (px *PathExcluder) Exclude(s string) (bool, string) {
+	for _, k := range px.Keep {
+		if s == k {
+			return false, ""
+		}
+	}
+	var reason string
+	for i := range px.rules {
+		if px.rules[i].matches(s) {
+			reason += px.rules[i].Reason() + " "
+		}
+	}
+	return (reason != ""), reason
+}
+
+// Include is a filter for [WalkOptions.Include]; see [ExcludeFunc].
+func (px *PathExcluder) Include() WalkFilter {
+	return ExcludeFunc(px.Exclude).Include()
+}
+
+// Prune is a filter for [WalkOptions.Prune]; see [ExcludeFunc].
+func (px *PathExcluder) Prune() WalkFilter {
+	return ExcludeFunc(px.Exclude).Prune()
+}
+
+// LoadPathExcluder reads a [PathExcluder] from a config
+// file; see [ParsePathExcluder] for the format.
+func LoadPathExcluder(fp string) (*PathExcluder, error) {
+	pF, e := os.Open(fp)
+	if e != nil {
+		return nil, &fs.PathError{Op: "loadpathexcluder", Path: fp, Err: e}
+	}
+	defer pF.Close()
+	return ParsePathExcluder(pF, fp)
+}
+
+// ParsePathExcluder reads a [PathExcluder] from a config file,
+// which has one rule per line, as the kind (optionally followed
+// by a colon and the target, which defaults to "path") and then
+// (after white space) the pattern, which is the rest of the line.
+// A line "keep <path>" adds to [PathExcluder.Keep]. Blank lines
+// and lines that start with "#" are skipped. For example:
+//
+//	keep .
+//	prefix:component .
+//	suffix:base ~
+//	glob **/node_modules
+//	regex:base ^#.*#$
+//
+// source is used in error messages.
+// .
+func ParsePathExcluder(r io.Reader, source string) (*PathExcluder, error) {
+	var px = new(PathExcluder)
+	var sc = bufio.NewScanner(r)
+	var line int
+	for sc.Scan() {
+		line++
+		var s = S.TrimSpace(sc.Text())
+		if s == "" || s[0] == '#' {
+			continue
+		}
+		var kt, pattern, ok = S.Cut(s, " ")
+		if !ok {
+			kt, pattern, ok = S.Cut(s, "\t")
+		}
+		pattern = S.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("%s:%d: no pattern: %s", source, line, s)
+		}
+		if kt == "keep" {
+			px.Keep = append(px.Keep, pattern)
+			continue
+		}
+		var kind, target, _ = S.Cut(kt, ":")
+		var e = px.AddRule(ExcludeRule{Kind: ExcludeKind(kind),
+			Target: ExcludeTarget(target), Pattern: pattern})
+		if e != nil {
+			return nil, fmt.Errorf("%s:%d: %w", source, line, e)
+		}
+	}
+	if e := sc.Err(); e != nil {
+		return nil, fmt.Errorf("%s: %w", source, e)
+	}
+	return px, nil
+}
+
+// M5Excluder is the preset that [ExcludeFilepath_m5] uses,
+// and makes exactly the same decisions, for the same reasons.
+// It is made from the lists m5ExcludePrefixes etc.
+func M5Excluder() *PathExcluder {
+	var px = &PathExcluder{Keep: []string{".", "./", "~", "~/"}}
+	for _, pfx := range m5ExcludePrefixes {
+		px.rules = append(px.rules, ExcludeRule{
+			Kind: ExcludePrefix, Target: TargetPath, Pattern: pfx})
+	}
+	for _, sfx := range m5ExcludeSuffixes {
+		px.rules = append(px.rules, ExcludeRule{
+			Kind: ExcludeSuffix, Target: TargetPath, Pattern: sfx})
+	}
+	for _, fpc := range m5ExcludeContains {
+		px.rules = append(px.rules, ExcludeRule{
+			Kind: ExcludeContains, Target: TargetPath, Pattern: fpc})
+	}
+	// Prefixes and suffixes around path separators
+	for _, pfx := range m5ExcludePrefixes {
+		px.rules = append(px.rules, ExcludeRule{Kind: ExcludeContains,
+			Target: TargetPath, Pattern: "/" + pfx,
+			Label: "dir/+prefix</" + pfx + ">"})
+	}
+	for _, sfx := range m5ExcludeSuffixes {
+		px.rules = append(px.rules, ExcludeRule{Kind: ExcludeContains,
+			Target: TargetPath, Pattern: sfx + "/",
+			Label: "suffix+/dir<" + sfx + "/>"})
+	}
+	return px
+}
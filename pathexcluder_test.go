@@ -0,0 +1,224 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	FP "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPathExcluderRules(t *testing.T) {
+	var tests = []struct {
+		rule   ExcludeRule
+		path   string
+		want   bool
+		reason string
+	}{
+		{ExcludeRule{Kind: ExcludePrefix, Pattern: "."}, ".git", true, "leading-prefix<.>"},
+		{ExcludeRule{Kind: ExcludePrefix, Pattern: "."}, "a/.git", false, ""},
+		{ExcludeRule{Kind: ExcludeSuffix, Pattern: "~"}, "a~", true, "trailing-suffix<~>"},
+		// A trailing slash marks a directory.
+		{ExcludeRule{Kind: ExcludeSuffix, Pattern: "~"}, "a~/", false, ""},
+		{ExcludeRule{Kind: ExcludeContains, Pattern: ".."}, "a..b", true, "contains<..>"},
+		{ExcludeRule{Kind: ExcludeGlob, Pattern: "**/node_modules"},
+			"node_modules", true, "glob<**/node_modules>"},
+		{ExcludeRule{Kind: ExcludeGlob, Pattern: "**/node_modules"},
+			"a/b/node_modules", true, "glob<**/node_modules>"},
+		{ExcludeRule{Kind: ExcludeGlob, Pattern: "**/node_modules"},
+			"a/node_modules/x", false, ""},
+		// A trailing "**" matches the directory itself, as for Glob.
+		{ExcludeRule{Kind: ExcludeGlob, Pattern: "build/**"},
+			"build", true, "glob<build/**>"},
+		{ExcludeRule{Kind: ExcludeGlob, Pattern: "build/**"},
+			"build/a/b", true, "glob<build/**>"},
+		{ExcludeRule{Kind: ExcludeGlob, Target: TargetBase, Pattern: "*.o"},
+			"a/b.o", true, "base-glob<*.o>"},
+		{ExcludeRule{Kind: ExcludeGlob, Target: TargetBase, Pattern: "*.o"},
+			"b.o/", true, "base-glob<*.o>"},
+		{ExcludeRule{Kind: ExcludeGlob, Target: TargetBase, Pattern: "*.o"},
+			"b.o/c", false, ""},
+		{ExcludeRule{Kind: ExcludeGlob, Target: TargetComponent, Pattern: "build*"},
+			"a/build-1/x", true, "component-glob<build*>"},
+		{ExcludeRule{Kind: ExcludeRegex, Target: TargetBase, Pattern: "^#.*#$"},
+			"a/#x#", true, "base-regex<^#.*#$>"},
+		{ExcludeRule{Kind: ExcludeRegex, Target: TargetBase, Pattern: "^#.*#$"},
+			"#a#/x", false, ""},
+		// A regex is not anchored unless it says so.
+		{ExcludeRule{Kind: ExcludeRegex, Pattern: "tmp[0-9]"},
+			"a/tmp12/b", true, "regex<tmp[0-9]>"},
+		{ExcludeRule{Kind: ExcludeRegex, Target: TargetComponent, Pattern: "^tmp[0-9]+$"},
+			"a/tmp12x/b", false, ""},
+		{ExcludeRule{Kind: ExcludePrefix, Target: TargetComponent, Pattern: "."},
+			"a/.cache/x", true, "component-prefix<.>"},
+		{ExcludeRule{Kind: ExcludeSuffix, Target: TargetBase, Pattern: "~",
+			Label: "backup"}, "a/b~", true, "backup"},
+	}
+	for _, tc := range tests {
+		px, e := NewPathExcluder(tc.rule)
+		if e != nil {
+			t.Fatal(e)
+		}
+		var got, reason = px.Exclude(tc.path)
+		var want = tc.reason
+		if want != "" {
+			want += " "
+		}
+		if got != tc.want || reason != want {
+			t.Errorf("%s %s %q: %q: got %v %q", tc.rule.Kind, tc.rule.Target,
+				tc.rule.Pattern, tc.path, got, reason)
+		}
+	}
+}
+
+func TestPathExcluder(t *testing.T) {
+	px, e := NewPathExcluder(
+		ExcludeRule{Kind: ExcludePrefix, Pattern: "."},
+		ExcludeRule{Kind: ExcludeSuffix, Target: TargetBase, Pattern: "~"})
+	if e != nil {
+		t.Fatal(e)
+	}
+	// Every rule that matches is in the reason.
+	if ex, why := px.Exclude(".a~"); !ex || why != "leading-prefix<.> base-suffix<~> " {
+		t.Errorf("got %v %q", ex, why)
+	}
+	px.Keep = []string{"."}
+	if ex, _ := px.Exclude("."); ex {
+		t.Error("Keep")
+	}
+	// Rules are copied out, and can be added again (which
+	// compiles a regex), so a zero PathExcluder is usable.
+	var rr = px.Rules()
+	rr[0].Pattern = "x"
+	if px.Rules()[0].Pattern != "." || rr[0].Target != TargetPath {
+		t.Errorf("Rules: got %+v", px.Rules())
+	}
+	var zero PathExcluder
+	if e = zero.AddRule(ExcludeRule{Kind: ExcludeRegex, Pattern: `\.bak$`}); e != nil {
+		t.Fatal(e)
+	}
+	var copied, _ = NewPathExcluder(zero.Rules()...)
+	for _, x := range []*PathExcluder{&zero, copied} {
+		if ex, _ := x.Exclude("a.bak"); !ex {
+			t.Error("regex")
+		}
+	}
+}
+
+func TestPathExcluderBadRules(t *testing.T) {
+	for _, r := range []ExcludeRule{
+		{Kind: ExcludePrefix, Target: "dir", Pattern: "x"},
+		{Kind: ExcludePrefix},
+		{Kind: ExcludeGlob, Pattern: "a/[x"},
+		{Kind: ExcludeRegex, Pattern: "("},
+		{Kind: "wildcard", Pattern: "x"},
+	} {
+		if _, e := NewPathExcluder(r); e == nil ||
+			!strings.HasPrefix(e.Error(), "pathexcluder: ") {
+			t.Errorf("%+v: got %v", r, e)
+		}
+	}
+}
+
+func TestM5Excluder(t *testing.T) {
+	var tests = []struct {
+		path, reason string
+	}{
+		{".", ""},
+		{"./", ""},
+		{"~/", ""},
+		{"src/", ""},
+		{"src/a.go", ""},
+		{"_build/", "leading-prefix<_> "},
+		{".git/", "leading-prefix<.> "},
+		{"src/a.go~", "trailing-suffix<~> "},
+		{"a.sh", "trailing-suffix<.sh> "},
+		{"a/../b", "contains<..> dir/+prefix</.> "},
+		{"src/_x/", "dir/+prefix</_> "},
+		{"src/.git/", "dir/+prefix</.> "},
+		{"old~/a.txt", "suffix+/dir<~/> "},
+	}
+	var m5 = M5Excluder()
+	for _, tc := range tests {
+		ex, why := ExcludeFilepath_m5(tc.path)
+		if ex != (tc.reason != "") || why != tc.reason {
+			t.Errorf("%q: got %v %q", tc.path, ex, why)
+		}
+		if ex2, why2 := m5.Exclude(tc.path); ex2 != ex || why2 != why {
+			t.Errorf("%q: preset got %v %q", tc.path, ex2, why2)
+		}
+	}
+}
+
+func TestHiddenFilesExcluder(t *testing.T) {
+	var px = HiddenFilesExcluder()
+	for path, want := range map[string]bool{
+		"#x#": true, ".git/config": true, ".gitignore": true,
+		".DS_Store": true, "a/.hidden": true, "a/#x": true, "a~": true,
+		"a/b.txt": false, "README": false, ".": false, "a/b/": false,
+	} {
+		if ex, why := px.Exclude(path); ex != want {
+			t.Errorf("%q: got %v %q", path, ex, why)
+		}
+	}
+}
+
+func TestParsePathExcluder(t *testing.T) {
+	var cfg = "# A comment\n\nkeep .\nprefix:component .\n" +
+		"suffix:base\t~\nglob **/node_modules\n  regex:base   ^#.*#$  \n"
+	px, e := ParsePathExcluder(strings.NewReader(cfg), "cfg")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var want = []ExcludeRule{
+		{Kind: ExcludePrefix, Target: TargetComponent, Pattern: "."},
+		{Kind: ExcludeSuffix, Target: TargetBase, Pattern: "~"},
+		{Kind: ExcludeGlob, Target: TargetPath, Pattern: "**/node_modules"},
+		{Kind: ExcludeRegex, Target: TargetBase, Pattern: "^#.*#$"},
+	}
+	var got = px.Rules()
+	for i := range got {
+		got[i].re = nil
+	}
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(px.Keep, []string{"."}) {
+		t.Errorf("got %+v, keep %v", got, px.Keep)
+	}
+	if ex, _ := px.Exclude("a/#b#"); !ex {
+		t.Error("regex")
+	}
+	for cfg, want := range map[string]string{
+		"# x\nprefix\n":        "cfg:2: no pattern: prefix",
+		"glob a/[x\n":          "cfg:1: pathexcluder: glob",
+		"keep .\nwildcard x\n": "cfg:2: pathexcluder: bad kind",
+		"prefix:somewhere x\n": "cfg:1: pathexcluder: bad target",
+		"regex:component (x\n": "cfg:1: pathexcluder: regex",
+	} {
+		if _, e := ParsePathExcluder(strings.NewReader(cfg), "cfg"); e == nil ||
+			!strings.HasPrefix(e.Error(), want) {
+			t.Errorf("%q: got %v", cfg, e)
+		}
+	}
+}
+
+func TestLoadPathExcluder(t *testing.T) {
+	var dir = t.TempDir()
+	var cfg = writeTestFile(t, dir, "exclude.cfg",
+		"prefix:component .\nglob:base *.o\n")
+	px, e := LoadPathExcluder(cfg)
+	if e != nil || len(px.Rules()) != 2 {
+		t.Fatalf("got %v, %v", px, e)
+	}
+	if _, e = LoadPathExcluder(FP.Join(dir, "nope")); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("missing: got %v", e)
+	}
+	// In a walk, a pruned directory is left out, as are its contents.
+	writeTestTree(t, dir, map[string]string{"src/a.c": "", "src/a.o": "", ".cache/x": "", "src/.deps/a.d": ""})
+	pWR, e := WalkTree(context.Background(), dir, &WalkOptions{Ordered: true,
+		Include: px.Include(), Prune: px.Prune()})
+	if got := walkRels(t, dir, pWR.Items); e != nil ||
+		!reflect.DeepEqual(got, []string{".", "exclude.cfg", "src", "src/a.c"}) {
+		t.Errorf("walk: got %v, %v", got, e)
+	}
+}
@@ -1,12 +1,9 @@
 package fileutils
 
-import (
-	S "strings"
-)
-
 // m5ExcludePrefixes m5ExcludeContains m5ExcludeSuffixes 
-// are dependencies on the app, but for simplicity we 
-// allow them here in this utility package. Notes:
+// are dependencies on the app, so they are used only to 
+// make the preset [M5Excluder]; other apps should build 
+// their own [PathExcluder]. Notes:
 //  - special handling is required for a leading dot (".") 
 //    or tilde ("~") that is by itself (i.e. is an entire 
 //    filepath element), representing the current or home
@@ -26,38 +23,12 @@ var m5ExcludeSuffixes = []string {
 //  - Excluded suffixes apply to all names, but will not apply to 
 //    a directory name that has a path separator appended.
 //  - The path separator is assumed to be slash ("/"), not os.Separator.
+//
+// It is now just the preset [M5Excluder] of [PathExcluder], 
+// which is where the rules can be customised. 
 // .
 func ExcludeFilepath_m5(s string) (bool, string) {
-     var reason string
-     if s == "." || s == "./" || s == "~" || s == "~/" {
-     	return false, ""
-	}
-     for _, pfx := range m5ExcludePrefixes {
-     	 if S.HasPrefix(s, pfx) {
-	    reason += "leading-prefix<" + pfx + "> " 
-	    }
-	 }
-     for _, sfx := range m5ExcludeSuffixes {
-     	 if S.HasSuffix(s, sfx) {
-	    reason += "trailing-suffix<" + sfx + "> " 
-	    }
-	 }
-     for _, fpc := range m5ExcludeContains {
-     	 if S.Contains(s, fpc) {
-	    reason += "contains<" + fpc + "> " 
-	    }
-	 }
-     // Check for prefixes and suffixes around path separators.
-     for _, pfx := range m5ExcludePrefixes {
-     	 if S.Contains(s, "/" + pfx) {
-	    reason += "dir/+prefix</" + pfx + "> " 
-	    }
-	 }
-     for _, sfx := range m5ExcludeSuffixes {
-     	 if S.Contains(s, sfx + "/") {
-	    reason += "suffix+/dir<" + sfx + "/> " 
-	    }
-	 }
-     return (reason != ""), reason 
+     return m5Excluder.Exclude(s)
 }
 
+var m5Excluder = M5Excluder()
@@ -111,7 +111,7 @@ func ResolvePath(s string) string {
 	}
 	if !S.HasPrefix(s, "~/") {
 		fmt.Fprintf(os.Stderr,
-			"not allowed to access other user's homedir:", s)
+			"not allowed to access other user's homedir: %s \n", s)
 		return s
 	}
 	return FP.Join(homedir, s[2:])
@@ -0,0 +1,115 @@
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+	"os/user"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StatInfo is the OS-dependent part of the result of a stat(2),
+// which [fs.FileInfo] provides only as an opaque Sys(). It is
+// captured when an FSObject is created (or refreshed), so that
+// listings, manifests and diff tools do not have to call stat
+// a second time. It is filled in from a [syscall.Stat_t], or on
+// Linux from the statx(2) that got the FileInfo (see the files
+// stat_*.go), so it is not available for (e.g.) the entries in
+// a zip, in which case field Valid is false.
+// .
+type StatInfo struct {
+	// Valid is false if the OS-dependent info is not available.
+	Valid bool `json:"valid"`
+	Uid   int  `json:"uid"`
+	Gid   int  `json:"gid"`
+	// User and Group are the names for Uid and Gid,
+	// or "" if they cannot be resolved.
+	User  string    `json:"user,omitempty"`
+	Group string    `json:"group,omitempty"`
+	Atime time.Time `json:"atime"`
+	Ctime time.Time `json:"ctime"`
+	// Btime is the birth (creation) time, which is zero
+	// where the OS or file system does not provide it.
+	// On Linux it requires statx(2), so it is zero for a
+	// FileInfo that came from (e.g.) [os.Lstat].
+	Btime time.Time `json:"btime"`
+	// Dev is the ID of the device containing the object.
+	Dev uint64 `json:"dev"`
+	// Rdev is the device ID, for a device node.
+	Rdev  uint64 `json:"rdev,omitempty"`
+	Ino   uint64 `json:"ino"`
+	Nlink uint64 `json:"nlink"`
+	// Blocks is the number of 512-byte blocks allocated.
+	Blocks int64 `json:"blocks"`
+	// BlkSize is the preferred block size for I/O.
+	BlkSize int64 `json:"blkSize"`
+}
+
+// HasBirthTime is a convenience function.
+func (p StatInfo) HasBirthTime() bool {
+	return !p.Btime.IsZero()
+}
+
+// setStatInfo fills in field [Stat] from the FileInfo, plus the
+// fields Inode and NLinks (which are set only for hard links).
+// It makes no syscalls (other than to resolve the user and group
+// names), so the birth time is available only if the FileInfo
+// came from [lstatHost]. It returns an error (that should NOT be
+// fatal) if the OS-dependent info is not available.
+func (p *FSObject) setStatInfo(fi fs.FileInfo) error {
+	st, ok := statInfoOf(fi)
+	if !ok {
+		p.Stat = StatInfo{}
+		p.Inode, p.NLinks = 0, 0
+		return errors.New("cannot convert Stat.Sys() to " +
+			"syscall.Stat_t (should NOT be fatal!)")
+	}
+	st.User = userName(st.Uid)
+	st.Group = groupName(st.Gid)
+	p.Stat = st
+	// Inode and NLinks are set only for hard link detection.
+	if st.Nlink > 1 && (fi.Mode()&fs.ModeSymlink == 0) {
+		p.Inode = int(st.Ino)
+		p.NLinks = int(st.Nlink)
+	} else {
+		p.Inode, p.NLinks = 0, 0
+	}
+	return nil
+}
+
+// Caches for resolving IDs to names, since
+// a tree tends to have very few distinct IDs.
+var (
+	idNamesMu  sync.Mutex
+	userNames  = make(map[int]string)
+	groupNames = make(map[int]string)
+)
+
+func userName(uid int) string {
+	idNamesMu.Lock()
+	defer idNamesMu.Unlock()
+	if s, ok := userNames[uid]; ok {
+		return s
+	}
+	var s string
+	if u, e := user.LookupId(strconv.Itoa(uid)); e == nil {
+		s = u.Username
+	}
+	userNames[uid] = s
+	return s
+}
+
+func groupName(gid int) string {
+	idNamesMu.Lock()
+	defer idNamesMu.Unlock()
+	if s, ok := groupNames[gid]; ok {
+		return s
+	}
+	var s string
+	if g, e := user.LookupGroupId(strconv.Itoa(gid)); e == nil {
+		s = g.Name
+	}
+	groupNames[gid] = s
+	return s
+}
@@ -0,0 +1,37 @@
+package fileutils
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+func statInfoOf(fi fs.FileInfo) (StatInfo, bool) {
+	if fi == nil {
+		return StatInfo{}, false
+	}
+	s, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || s == nil {
+		return StatInfo{}, false
+	}
+	return StatInfo{
+		Valid:   true,
+		Uid:     int(s.Uid),
+		Gid:     int(s.Gid),
+		Atime:   time.Unix(s.Atimespec.Unix()),
+		Ctime:   time.Unix(s.Ctimespec.Unix()),
+		Btime:   time.Unix(s.Birthtimespec.Unix()),
+		Dev:     uint64(s.Dev),
+		Rdev:    uint64(s.Rdev),
+		Ino:     uint64(s.Ino),
+		Nlink:   uint64(s.Nlink),
+		Blocks:  int64(s.Blocks),
+		BlkSize: int64(s.Blksize),
+	}, true
+}
+
+// lstatHost is [os.Lstat], because the Stat_t has the birth time.
+func lstatHost(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
@@ -0,0 +1,123 @@
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func statInfoOf(fi fs.FileInfo) (StatInfo, bool) {
+	if fi == nil {
+		return StatInfo{}, false
+	}
+	switch s := fi.Sys().(type) {
+	case *unix.Statx_t:
+		if s == nil {
+			return StatInfo{}, false
+		}
+		var st = StatInfo{
+			Valid:   true,
+			Uid:     int(s.Uid),
+			Gid:     int(s.Gid),
+			Atime:   time.Unix(s.Atime.Sec, int64(s.Atime.Nsec)),
+			Ctime:   time.Unix(s.Ctime.Sec, int64(s.Ctime.Nsec)),
+			Dev:     unix.Mkdev(s.Dev_major, s.Dev_minor),
+			Rdev:    unix.Mkdev(s.Rdev_major, s.Rdev_minor),
+			Ino:     s.Ino,
+			Nlink:   uint64(s.Nlink),
+			Blocks:  int64(s.Blocks),
+			BlkSize: int64(s.Blksize),
+		}
+		if s.Mask&unix.STATX_BTIME != 0 {
+			st.Btime = time.Unix(s.Btime.Sec, int64(s.Btime.Nsec))
+		}
+		return st, true
+	case *syscall.Stat_t:
+		if s == nil {
+			return StatInfo{}, false
+		}
+		return StatInfo{
+			Valid:   true,
+			Uid:     int(s.Uid),
+			Gid:     int(s.Gid),
+			Atime:   time.Unix(s.Atim.Unix()),
+			Ctime:   time.Unix(s.Ctim.Unix()),
+			Dev:     uint64(s.Dev),
+			Rdev:    uint64(s.Rdev),
+			Ino:     uint64(s.Ino),
+			Nlink:   uint64(s.Nlink),
+			Blocks:  int64(s.Blocks),
+			BlkSize: int64(s.Blksize),
+		}, true
+	}
+	return StatInfo{}, false
+}
+
+// lstatHost is [os.Lstat], except that it uses statx(2), so
+// that the one call also gets the birth time (where the file
+// system records it). Its Sys() is a [unix.Statx_t]. If the
+// kernel lacks statx (it needs Linux 4.11), or a seccomp
+// filter blocks it, it falls back to os.Lstat.
+// .
+func lstatHost(path string) (fs.FileInfo, error) {
+	var stx unix.Statx_t
+	e := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW,
+		unix.STATX_BASIC_STATS|unix.STATX_BTIME, &stx)
+	if errors.Is(e, unix.ENOSYS) || errors.Is(e, unix.EPERM) {
+		return os.Lstat(path)
+	}
+	if e != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: path, Err: e}
+	}
+	return &statxFileInfo{name: FP.Base(path), stx: stx}, nil
+}
+
+// statxFileInfo is the [fs.FileInfo] for a [unix.Statx_t].
+type statxFileInfo struct {
+	name string
+	stx  unix.Statx_t
+}
+
+func (fi *statxFileInfo) Name() string { return fi.name }
+func (fi *statxFileInfo) Size() int64  { return int64(fi.stx.Size) }
+func (fi *statxFileInfo) IsDir() bool  { return fi.Mode().IsDir() }
+func (fi *statxFileInfo) Sys() any     { return &fi.stx }
+
+func (fi *statxFileInfo) ModTime() time.Time {
+	return time.Unix(fi.stx.Mtime.Sec, int64(fi.stx.Mtime.Nsec))
+}
+
+// Mode converts the st_mode as [os.Lstat] does.
+func (fi *statxFileInfo) Mode() fs.FileMode {
+	var m = uint32(fi.stx.Mode)
+	var mode = fs.FileMode(m & 0777)
+	switch m & unix.S_IFMT {
+	case unix.S_IFBLK:
+		mode |= fs.ModeDevice
+	case unix.S_IFCHR:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case unix.S_IFDIR:
+		mode |= fs.ModeDir
+	case unix.S_IFIFO:
+		mode |= fs.ModeNamedPipe
+	case unix.S_IFLNK:
+		mode |= fs.ModeSymlink
+	case unix.S_IFSOCK:
+		mode |= fs.ModeSocket
+	}
+	if m&unix.S_ISGID != 0 {
+		mode |= fs.ModeSetgid
+	}
+	if m&unix.S_ISUID != 0 {
+		mode |= fs.ModeSetuid
+	}
+	if m&unix.S_ISVTX != 0 {
+		mode |= fs.ModeSticky
+	}
+	return mode
+}
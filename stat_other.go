@@ -0,0 +1,16 @@
+//go:build !(linux || darwin)
+
+package fileutils
+
+import (
+	"io/fs"
+	"os"
+)
+
+func statInfoOf(fi fs.FileInfo) (StatInfo, bool) {
+	return StatInfo{}, false
+}
+
+func lstatHost(path string) (fs.FileInfo, error) {
+	return os.Lstat(path)
+}
@@ -0,0 +1,104 @@
+//go:build linux || darwin
+
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"syscall"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/sys/unix"
+)
+
+// TestLstatHost checks that lstatHost agrees with os.Lstat.
+func TestLstatHost(t *testing.T) {
+	var dir = t.TempDir()
+	var file = writeTestFile(t, dir, "f.txt", "content")
+	os.Chmod(file, 0640|fs.ModeSetgid)
+	var paths = map[string]string{
+		"file":    file,
+		"dir":     dir,
+		"symlink": FP.Join(dir, "link"),
+		"fifo":    FP.Join(dir, "fifo"),
+		"dangler": FP.Join(dir, "dangler"),
+	}
+	if e := os.Symlink("f.txt", paths["symlink"]); e != nil {
+		t.Fatal(e)
+	}
+	if e := os.Symlink("nope", paths["dangler"]); e != nil {
+		t.Fatal(e)
+	}
+	if e := unix.Mkfifo(paths["fifo"], 0600); e != nil {
+		t.Fatal(e)
+	}
+	for name, path := range paths {
+		fi, e := lstatHost(path)
+		if e != nil {
+			t.Fatalf("%s: %v", name, e)
+		}
+		want, _ := os.Lstat(path)
+		if fi.Mode() != want.Mode() || fi.Size() != want.Size() ||
+			!fi.ModTime().Equal(want.ModTime()) || fi.Name() != want.Name() ||
+			fi.IsDir() != want.IsDir() {
+			t.Errorf("%s: got %v %d %v %q, want %v %d %v %q", name,
+				fi.Mode(), fi.Size(), fi.ModTime(), fi.Name(),
+				want.Mode(), want.Size(), want.ModTime(), want.Name())
+		}
+		st, ok := statInfoOf(fi)
+		var sys = want.Sys().(*syscall.Stat_t)
+		if !ok || st.Ino != uint64(sys.Ino) || st.Dev != uint64(sys.Dev) ||
+			st.Nlink != uint64(sys.Nlink) || st.Uid != int(sys.Uid) {
+			t.Errorf("%s: stat info %+v", name, st)
+		}
+	}
+	_, e := lstatHost(FP.Join(dir, "missing"))
+	var pe *fs.PathError
+	if !errors.Is(e, fs.ErrNotExist) || !errors.As(e, &pe) || pe.Op != "lstat" {
+		t.Errorf("missing: got %v", e)
+	}
+}
+
+func TestStatInfo(t *testing.T) {
+	var dir = t.TempDir()
+	var path = writeTestFile(t, dir, "f.txt", "content")
+	var p = NewFSObject(path)
+	if p.HasError() {
+		t.Fatal(p.GetError())
+	}
+	var st = p.Stat
+	if !st.Valid || st.Uid != os.Getuid() || st.Gid != os.Getgid() {
+		t.Errorf("got %+v", st)
+	}
+	if st.Nlink != 1 || p.NLinks != 0 || p.Inode != 0 {
+		t.Errorf("Nlink %d, NLinks %d, Inode %d", st.Nlink, p.NLinks, p.Inode)
+	}
+	if st.Ctime.IsZero() || st.Atime.IsZero() || st.BlkSize == 0 {
+		t.Errorf("times or block size missing: %+v", st)
+	}
+	// The birth time is optional, but it cannot be after the mtime.
+	if st.HasBirthTime() && st.Btime.After(p.ModTime()) {
+		t.Errorf("btime %v after mtime %v", st.Btime, p.ModTime())
+	}
+	// A hard link sets Inode and NLinks.
+	if e := os.Link(path, FP.Join(dir, "hard")); e != nil {
+		t.Fatal(e)
+	}
+	p = NewFSObject(path)
+	if p.NLinks != 2 || uint64(p.Inode) != p.Stat.Ino {
+		t.Errorf("hard link: NLinks %d, Inode %d", p.NLinks, p.Inode)
+	}
+}
+
+func TestStatInfoNotAvailable(t *testing.T) {
+	var p = NewFSObjectFromFS(fstest.MapFS{"f": {Data: []byte("x")}}, "f")
+	if p.Stat.Valid || p.Stat.HasBirthTime() {
+		t.Errorf("got %+v", p.Stat)
+	}
+	if e := p.setStatInfo(p.FileInfo); e == nil {
+		t.Error("no error")
+	}
+}
@@ -0,0 +1,484 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	FP "path/filepath"
+	S "strings"
+	"unicode/utf8"
+)
+
+// LineEnding is a style of line break.
+type LineEnding string
+
+const (
+	// LineEndNone means there are no line breaks at all.
+	LineEndNone  LineEnding = ""
+	LineEndLF    LineEnding = "lf"
+	LineEndCRLF  LineEnding = "crlf"
+	LineEndCR    LineEnding = "cr"
+	LineEndMixed LineEnding = "mixed"
+)
+
+// Sequence is the line break itself, e.g. "\r\n".
+func (le LineEnding) Sequence() string {
+	switch le {
+	case LineEndLF:
+		return "\n"
+	case LineEndCRLF:
+		return "\r\n"
+	case LineEndCR:
+		return "\r"
+	}
+	return ""
+}
+
+// Indentation is the style of the leading whitespace of lines.
+type Indentation string
+
+const (
+	IndentNone   Indentation = ""
+	IndentTabs   Indentation = "tabs"
+	IndentSpaces Indentation = "spaces"
+	// IndentMixed means that some lines are indented with
+	// tabs and others with spaces, or that some lines have
+	// both (see [TextProfile.MixedIndented]).
+	IndentMixed Indentation = "mixed"
+)
+
+// TextProfile describes the layout of some text, so that files
+// with mixed line endings, missing final newlines, and stray tabs
+// can be found (and then fixed, see [TextPolicy]). Line lengths
+// are in runes, counted by their first bytes, so in text that is
+// not valid UTF-8, a stray continuation byte is not counted.
+type TextProfile struct {
+	Size  int64
+	Lines int
+	// LFs, CRLFs, and CRs are counts of each kind of line break.
+	LFs, CRLFs, CRs int
+	LineEnding      LineEnding
+	// FinalNewline is set if the text ends in a line break
+	// (an empty text has none, and does not need one).
+	FinalNewline bool
+	// TabIndented, SpaceIndented and MixedIndented are counts
+	// of lines whose leading whitespace is tabs, spaces, or both.
+	TabIndented, SpaceIndented, MixedIndented int
+	Indentation                               Indentation
+	// TrailingSpace is the number of lines that end in
+	// (non-newline) whitespace.
+	TrailingSpace int
+	NULs          int
+	// ControlBytes counts C0 control characters (other than
+	// tab, LF, CR, and form feed) and DEL. It includes NULs.
+	ControlBytes  int
+	LongestLine   int
+	LongestLineNr int
+}
+
+// IsBinary is true if the text contains NULs, which text never does.
+func (tp TextProfile) IsBinary() bool {
+	return tp.NULs > 0
+}
+
+func (tp TextProfile) String() string {
+	var le = string(tp.LineEnding)
+	if le == "" {
+		le = "none"
+	}
+	var ind = string(tp.Indentation)
+	if ind == "" {
+		ind = "none"
+	}
+	return fmt.Sprintf("lines:%d eol:%s final-nl:%s indent:%s "+
+		"trailing-ws:%d ctrl:%d nul:%d longest:%d(L%d)",
+		tp.Lines, le, boolstring(tp.FinalNewline), ind, tp.TrailingSpace,
+		tp.ControlBytes, tp.NULs, tp.LongestLine, tp.LongestLineNr)
+}
+
+// textProfiler computes a [TextProfile] incrementally, so
+// that a file can be profiled in chunks (see [ReadChunks]).
+type textProfiler struct {
+	tp      TextProfile
+	prevCR  bool // a CR that might be the start of a CRLF
+	lineLen int
+	inIndent,
+	sawTab, sawSpace bool
+	lastWS   bool // last char on the line is whitespace
+	lineHas  bool // current line has any content
+	lastByte byte
+}
+
+func newTextProfiler() *textProfiler {
+	return &textProfiler{inIndent: true}
+}
+
+func (tpr *textProfiler) Write(bb []byte) (int, error) {
+	for _, c := range bb {
+		if tpr.prevCR {
+			tpr.prevCR = false
+			if c == '\n' {
+				tpr.tp.CRLFs++
+				tpr.endLine()
+				tpr.lastByte = c
+				continue
+			}
+			tpr.tp.CRs++
+			tpr.endLine()
+		}
+		switch {
+		case c == '\r':
+			tpr.prevCR = true
+		case c == '\n':
+			tpr.tp.LFs++
+			tpr.endLine()
+		default:
+			tpr.addByte(c)
+		}
+		tpr.lastByte = c
+	}
+	tpr.tp.Size += int64(len(bb))
+	return len(bb), nil
+}
+
+func (tpr *textProfiler) addByte(c byte) {
+	tpr.lineHas = true
+	// Count runes, not UTF-8 continuation bytes
+	if c < 0x80 || utf8.RuneStart(c) {
+		tpr.lineLen++
+	}
+	var isWS = c == ' ' || c == '\t'
+	tpr.lastWS = isWS
+	if tpr.inIndent {
+		switch c {
+		case ' ':
+			tpr.sawSpace = true
+		case '\t':
+			tpr.sawTab = true
+		default:
+			tpr.inIndent = false
+		}
+	}
+	switch {
+	case c == 0:
+		tpr.tp.NULs++
+		tpr.tp.ControlBytes++
+	case (c < 0x20 && c != '\t' && c != '\f') || c == 0x7F:
+		tpr.tp.ControlBytes++
+	}
+}
+
+func (tpr *textProfiler) endLine() {
+	var tp = &tpr.tp
+	tp.Lines++
+	if tpr.lineLen > tp.LongestLine {
+		tp.LongestLine, tp.LongestLineNr = tpr.lineLen, tp.Lines
+	}
+	// A line of only whitespace is not "indented".
+	if !tpr.inIndent {
+		switch {
+		case tpr.sawTab && tpr.sawSpace:
+			tp.MixedIndented++
+		case tpr.sawTab:
+			tp.TabIndented++
+		case tpr.sawSpace:
+			tp.SpaceIndented++
+		}
+	}
+	if tpr.lastWS {
+		tp.TrailingSpace++
+	}
+	tpr.lineLen = 0
+	tpr.inIndent, tpr.sawTab, tpr.sawSpace = true, false, false
+	tpr.lastWS, tpr.lineHas = false, false
+}
+
+// profile finishes the profile.
+func (tpr *textProfiler) profile() TextProfile {
+	if tpr.prevCR {
+		tpr.prevCR = false
+		tpr.tp.CRs++
+		tpr.endLine()
+	}
+	var tp = tpr.tp
+	if tpr.lineHas {
+		// The last line has no line break
+		tpr.endLine()
+		tp = tpr.tp
+	} else if tp.Size > 0 {
+		tp.FinalNewline = tpr.lastByte == '\n' || tpr.lastByte == '\r'
+	}
+	var kinds int
+	for _, n := range []int{tp.LFs, tp.CRLFs, tp.CRs} {
+		if n > 0 {
+			kinds++
+		}
+	}
+	switch {
+	case kinds > 1:
+		tp.LineEnding = LineEndMixed
+	case tp.LFs > 0:
+		tp.LineEnding = LineEndLF
+	case tp.CRLFs > 0:
+		tp.LineEnding = LineEndCRLF
+	case tp.CRs > 0:
+		tp.LineEnding = LineEndCR
+	}
+	switch {
+	case tp.MixedIndented > 0 || (tp.TabIndented > 0 && tp.SpaceIndented > 0):
+		tp.Indentation = IndentMixed
+	case tp.TabIndented > 0:
+		tp.Indentation = IndentTabs
+	case tp.SpaceIndented > 0:
+		tp.Indentation = IndentSpaces
+	}
+	return tp
+}
+
+// ProfileText computes the [TextProfile] of a string.
+func ProfileText(s string) TextProfile {
+	var tpr = newTextProfiler()
+	tpr.Write([]byte(s))
+	return tpr.profile()
+}
+
+// TextProfile computes the [TextProfile] of a file. If the
+// content is loaded, it is used; otherwise the file is read in
+// chunks (see [ReadChunks]), so that it works for files of any
+// size. Note that if the content was transcoded to UTF-8 (see
+// [EncodingInfo]), then the profile is of the UTF-8.
+// .
+func (p *FSObject) TextProfile() (TextProfile, error) {
+	if p.TypedRaw != nil && (p.inMemory || len(p.Raw) > 0 ||
+		p.FPs.ContentInMemoryIsDirty) {
+		return ProfileText(p.TypedRaw.S()), nil
+	}
+	var tpr = newTextProfiler()
+	_, _, e := p.ReadChunks(NoContentLimits, 0,
+		func(chunk []byte) error {
+			tpr.Write(chunk)
+			return nil
+		})
+	if e != nil {
+		return TextProfile{}, e
+	}
+	return tpr.profile(), nil
+}
+
+// TextPolicy is how [NormalizeText] rewrites text. The zero
+// value changes nothing.
+type TextPolicy struct {
+	// LineEnding is the line break to use, or
+	// LineEndNone ("") to leave them as they are.
+	LineEnding LineEnding
+	// FinalNewline adds a line break at the end of
+	// a non-empty text that does not have one.
+	FinalNewline bool
+	// Indentation converts the leading whitespace of each line
+	// to IndentTabs or IndentSpaces, using TabWidth; spaces that
+	// do not fill a tab stop stay as spaces.
+	Indentation Indentation
+	// TabWidth is for Indentation; zero means 8.
+	TabWidth int
+	// TrimTrailingSpace removes whitespace at the end of lines.
+	TrimTrailingSpace bool
+	// DropControls removes control characters (as
+	// counted by [TextProfile.ControlBytes]).
+	DropControls bool
+	// DryRun makes [NormalizeFile] and [NormalizeTree]
+	// report what they would change, but not write it.
+	DryRun bool
+}
+
+// NormalizeText rewrites the text per the policy.
+func NormalizeText(s string, pol TextPolicy) string {
+	var tabW = pol.TabWidth
+	if tabW <= 0 {
+		tabW = 8
+	}
+	var sb S.Builder
+	sb.Grow(len(s) + len(s)/16)
+	var rest = s
+	for rest != "" {
+		var line, eol string
+		var i = S.IndexAny(rest, "\r\n")
+		if i < 0 {
+			line, rest = rest, ""
+		} else {
+			line = rest[:i]
+			if S.HasPrefix(rest[i:], "\r\n") {
+				eol, rest = "\r\n", rest[i+2:]
+			} else {
+				eol, rest = rest[i:i+1], rest[i+1:]
+			}
+		}
+		if pol.DropControls {
+			line = dropControls(line)
+		}
+		if pol.TrimTrailingSpace {
+			line = S.TrimRight(line, " \t")
+		}
+		if pol.Indentation == IndentTabs || pol.Indentation == IndentSpaces {
+			line = reindent(line, pol.Indentation, tabW)
+		}
+		sb.WriteString(line)
+		if eol != "" && pol.LineEnding.Sequence() != "" {
+			eol = pol.LineEnding.Sequence()
+		}
+		sb.WriteString(eol)
+		if rest == "" && eol == "" && pol.FinalNewline && sb.Len() > 0 {
+			var nl = pol.LineEnding.Sequence()
+			if nl == "" {
+				nl = ProfileText(s).LineEnding.Sequence()
+			}
+			if nl == "" {
+				nl = "\n"
+			}
+			sb.WriteString(nl)
+		}
+	}
+	return sb.String()
+}
+
+// dropControls removes C0 control characters (except tab and
+// form feed) and DEL. It works on bytes, not runes, so that text
+// that is not valid UTF-8 is otherwise left as it is; since these
+// are all ASCII, none of them is part of a multibyte rune.
+func dropControls(line string) string {
+	var bb []byte
+	for i := 0; i < len(line); i++ {
+		var c = line[i]
+		if (c < 0x20 && c != '\t' && c != '\f') || c == 0x7F {
+			if bb == nil {
+				bb = append(make([]byte, 0, len(line)), line[:i]...)
+			}
+			continue
+		}
+		if bb != nil {
+			bb = append(bb, c)
+		}
+	}
+	if bb == nil {
+		return line
+	}
+	return string(bb)
+}
+
+// reindent converts the leading whitespace of a line.
+func reindent(line string, to Indentation, tabW int) string {
+	var i, col int
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t') {
+		if line[i] == '\t' {
+			col += tabW - col%tabW
+		} else {
+			col++
+		}
+		i++
+	}
+	if i == 0 || i == len(line) {
+		// No indentation, or a blank line
+		return line
+	}
+	var ind string
+	if to == IndentSpaces {
+		ind = S.Repeat(" ", col)
+	} else {
+		ind = S.Repeat("\t", col/tabW) + S.Repeat(" ", col%tabW)
+	}
+	return ind + line[i:]
+}
+
+// ErrBinaryContent is returned when asked to normalize
+// content that is not text (it contains NULs).
+var ErrBinaryContent = errors.New("content is binary, not text")
+
+// Normalize rewrites the FSObject's content per the policy and saves
+// it (see [Save], which is atomic and detects conflicts, and which
+// preserves the encoding and the permissions). It returns whether
+// the content changed. Binary content is refused.
+// .
+func (p *FSObject) Normalize(pol TextPolicy) (bool, error) {
+	s, e := p.Contents()
+	if e != nil {
+		return false, e
+	}
+	if !p.IsFile() {
+		return false, &fs.PathError{Op: "fso.normalize",
+			Path: p.FPs.ShortFP, Err: ErrNotAFile}
+	}
+	if S.IndexByte(s, 0) >= 0 {
+		return false, &fs.PathError{Op: "fso.normalize",
+			Path: p.FPs.ShortFP, Err: ErrBinaryContent}
+	}
+	var ns = NormalizeText(s, pol)
+	if ns == s {
+		return false, nil
+	}
+	if pol.DryRun {
+		return true, nil
+	}
+	if e = p.SetContents(ns); e != nil {
+		return false, e
+	}
+	return true, p.Save()
+}
+
+// NormalizeFile is [FSObject.Normalize] for a path.
+func NormalizeFile(path string, pol TextPolicy) (bool, error) {
+	var p = NewFSObject(path)
+	if p.FileInfo == nil {
+		return false, p.GetError()
+	}
+	return p.Normalize(pol)
+}
+
+// NormalizeTree applies [NormalizeFile] to every text file in the
+// tree. A file is text if its MIME type (see [SniffMimeType]) is
+// text and it has no NULs; other files are skipped. The filter (if
+// not nil) can exclude a path; excluding a directory skips it all.
+// Symlinks are not followed. It returns the paths that changed (or
+// with DryRun, that would change), plus all errors, joined.
+// .
+func NormalizeTree(root string, pol TextPolicy,
+	filter func(path string, d fs.DirEntry) bool) ([]string, error) {
+	var changed []string
+	var errs []error
+	e := FP.WalkDir(root, func(path string, d fs.DirEntry, e error) error {
+		if e != nil {
+			errs = append(errs, e)
+			return nil
+		}
+		if filter != nil && path != root && !filter(path, d) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		var p = NewFSObject(path)
+		if p.FileInfo == nil {
+			errs = append(errs, p.GetError())
+			return nil
+		}
+		if mt, e := p.DetectMimeType(); e != nil || !IsTextMimeType(mt) {
+			return nil
+		}
+		did, e := p.Normalize(pol)
+		if e != nil {
+			if !errors.Is(e, ErrBinaryContent) {
+				errs = append(errs, e)
+			}
+			return nil
+		}
+		if did {
+			changed = append(changed, path)
+		}
+		return nil
+	})
+	if e != nil {
+		errs = append(errs, e)
+	}
+	return changed, errors.Join(errs...)
+}
@@ -0,0 +1,232 @@
+package fileutils
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfileText(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want TextProfile
+	}{
+		{"empty", "", TextProfile{}},
+		{"lf", "a\nbb\n", TextProfile{Size: 5, Lines: 2, LFs: 2,
+			LineEnding: LineEndLF, FinalNewline: true,
+			LongestLine: 2, LongestLineNr: 2}},
+		{"crlf no final", "a\r\nb", TextProfile{Size: 4, Lines: 2, CRLFs: 1,
+			LineEnding: LineEndCRLF, LongestLine: 1, LongestLineNr: 1}},
+		{"cr", "a\rb\r", TextProfile{Size: 4, Lines: 2, CRs: 2,
+			LineEnding: LineEndCR, FinalNewline: true,
+			LongestLine: 1, LongestLineNr: 1}},
+		{"mixed", "a\nb\r\n", TextProfile{Size: 5, Lines: 2, LFs: 1, CRLFs: 1,
+			LineEnding: LineEndMixed, FinalNewline: true,
+			LongestLine: 1, LongestLineNr: 1}},
+		{"tabs", "\tx\n\ty\n", TextProfile{Size: 6, Lines: 2, LFs: 2,
+			LineEnding: LineEndLF, FinalNewline: true, TabIndented: 2,
+			Indentation: IndentTabs, LongestLine: 2, LongestLineNr: 1}},
+		{"tabs and spaces", "\tx\n  y\n", TextProfile{Size: 7, Lines: 2, LFs: 2,
+			LineEnding: LineEndLF, FinalNewline: true, TabIndented: 1,
+			SpaceIndented: 1, Indentation: IndentMixed,
+			LongestLine: 3, LongestLineNr: 2}},
+		{"both on a line", "\t x\n", TextProfile{Size: 4, Lines: 1, LFs: 1,
+			LineEnding: LineEndLF, FinalNewline: true, MixedIndented: 1,
+			Indentation: IndentMixed, LongestLine: 3, LongestLineNr: 1}},
+		// A blank line is not indented, but its whitespace trails.
+		{"trailing", "x \n  \n", TextProfile{Size: 6, Lines: 2, LFs: 2,
+			LineEnding: LineEndLF, FinalNewline: true, TrailingSpace: 2,
+			LongestLine: 2, LongestLineNr: 1}},
+		{"controls", "a\x00b\x01\x7f\f\t", TextProfile{Size: 7, Lines: 1,
+			NULs: 1, ControlBytes: 3, TrailingSpace: 1,
+			LongestLine: 7, LongestLineNr: 1}},
+		// Lengths are in runes.
+		{"runes", "ab\nééé\n", TextProfile{Size: 10, Lines: 2, LFs: 2,
+			LineEnding: LineEndLF, FinalNewline: true,
+			LongestLine: 3, LongestLineNr: 2}},
+	}
+	for _, tc := range tests {
+		if got := ProfileText(tc.text); got != tc.want {
+			t.Errorf("%s:\n got %+v\nwant %+v", tc.name, got, tc.want)
+		}
+	}
+	if !ProfileText("a\x00").IsBinary() || ProfileText("a\x01").IsBinary() {
+		t.Error("IsBinary")
+	}
+	if s := ProfileText("a\r\n").String(); !strings.HasPrefix(s,
+		"lines:1 eol:crlf final-nl:Y indent:none") {
+		t.Errorf("String: %q", s)
+	}
+}
+
+// TestProfileChunked checks that the profile does not depend on
+// where the chunks split the text, e.g. between a CR and its LF.
+func TestProfileChunked(t *testing.T) {
+	for _, s := range []string{"a\r\nb\r\n", "a\r\rb\r", "\t é\r\n  x \r",
+		"\r", "x\r\n\n\ry"} {
+		var tpr = newTextProfiler()
+		for i := range len(s) {
+			tpr.Write([]byte{s[i]})
+		}
+		if got, want := tpr.profile(), ProfileText(s); got != want {
+			t.Errorf("%q:\n got %+v\nwant %+v", s, got, want)
+		}
+	}
+}
+
+func TestFSObjectTextProfile(t *testing.T) {
+	var text = "one\r\ntwo\r\n\tthree"
+	var p = NewFSObject(writeTestFile(t, t.TempDir(), "a.txt", text))
+	// Not loaded, so it is read in chunks.
+	tp, e := p.TextProfile()
+	if e != nil || tp != ProfileText(text) || p.TypedRaw != nil {
+		t.Errorf("file: got %+v, %v", tp, e)
+	}
+	if e = p.SetContents("x\n"); e != nil {
+		t.Fatal(e)
+	}
+	if tp, e = p.TextProfile(); e != nil || tp.LineEnding != LineEndLF {
+		t.Errorf("dirty: got %+v, %v", tp, e)
+	}
+	tp, e = NewFSObjectFromContent("a\rb", "").TextProfile()
+	if e != nil || tp.LineEnding != LineEndCR || tp.FinalNewline {
+		t.Errorf("in memory: got %+v, %v", tp, e)
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	var tests = []struct {
+		name, text string
+		pol        TextPolicy
+		want       string
+	}{
+		{"zero policy", "a\r\nb\r \t\x01", TextPolicy{}, "a\r\nb\r \t\x01"},
+		{"to lf", "a\r\nb\rc\n", TextPolicy{LineEnding: LineEndLF}, "a\nb\nc\n"},
+		{"to crlf", "a\nb", TextPolicy{LineEnding: LineEndCRLF}, "a\r\nb"},
+		// The final newline is in the style of the text,
+		{"final", "a\r\nb", TextPolicy{FinalNewline: true}, "a\r\nb\r\n"},
+		// or of the policy,
+		{"final lf", "a\r\nb", TextPolicy{LineEnding: LineEndLF,
+			FinalNewline: true}, "a\nb\n"},
+		// or else LF.
+		{"final none", "a", TextPolicy{FinalNewline: true}, "a\n"},
+		{"final empty", "", TextPolicy{FinalNewline: true}, ""},
+		{"final already", "a\n", TextPolicy{FinalNewline: true}, "a\n"},
+		{"trim", "a  \t\nb \n", TextPolicy{TrimTrailingSpace: true}, "a\nb\n"},
+		{"to spaces", "\tx\n\t  y\n", TextPolicy{Indentation: IndentSpaces,
+			TabWidth: 4}, "    x\n      y\n"},
+		{"to spaces default", "\tx", TextPolicy{Indentation: IndentSpaces},
+			"        x"},
+		// Spaces that do not fill a tab stop are kept.
+		{"to tabs", "      x\n    \n", TextPolicy{Indentation: IndentTabs,
+			TabWidth: 4}, "\t  x\n    \n"},
+		{"controls", "a\x01b\x7f\tc\f\r\n", TextPolicy{DropControls: true},
+			"ab\tc\f\r\n"},
+		// Bytes that are not valid UTF-8 are left as they are.
+		{"controls not utf-8", "caf\xe9\x01 \x80\xff\n", TextPolicy{DropControls: true},
+			"caf\xe9 \x80\xff\n"},
+		{"not utf-8", "caf\xe9  \r\n", TextPolicy{LineEnding: LineEndLF,
+			TrimTrailingSpace: true}, "caf\xe9\n"},
+	}
+	for _, tc := range tests {
+		if got := NormalizeText(tc.text, tc.pol); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	var dir = t.TempDir()
+	var pol = TextPolicy{LineEnding: LineEndLF, FinalNewline: true}
+	var path = writeTestFile(t, dir, "a.txt", "a\r\nb")
+	os.Chmod(path, 0600)
+
+	pol.DryRun = true
+	if did, e := NormalizeFile(path, pol); !did || e != nil {
+		t.Errorf("dry run: got %v, %v", did, e)
+	}
+	if bb, _ := os.ReadFile(path); string(bb) != "a\r\nb" {
+		t.Errorf("dry run wrote %q", bb)
+	}
+	pol.DryRun = false
+	if did, e := NormalizeFile(path, pol); !did || e != nil {
+		t.Errorf("got %v, %v", did, e)
+	}
+	if bb, _ := os.ReadFile(path); string(bb) != "a\nb\n" {
+		t.Errorf("wrote %q", bb)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0600 {
+		t.Errorf("mode %v", fi.Mode())
+	}
+	if did, e := NormalizeFile(path, pol); did || e != nil {
+		t.Errorf("again: got %v, %v", did, e)
+	}
+	// Text that is not UTF-8 keeps its bytes.
+	var latin1 = writeTestFile(t, dir, "l.txt", "caf\xe9 au lait\x01\r\n")
+	if did, e := NormalizeFile(latin1, TextPolicy{LineEnding: LineEndLF,
+		DropControls: true}); !did || e != nil {
+		t.Errorf("latin-1: got %v, %v", did, e)
+	}
+	if bb, _ := os.ReadFile(latin1); string(bb) != "caf\xe9 au lait\n" {
+		t.Errorf("latin-1: wrote %q", bb)
+	}
+	var bin = writeTestFile(t, dir, "b.txt", "a\x00\r\n")
+	if _, e := NormalizeFile(bin, pol); !errors.Is(e, ErrBinaryContent) {
+		t.Errorf("binary: got %v", e)
+	}
+	if _, e := NormalizeFile(FP.Join(dir, "nope"), pol); e == nil {
+		t.Error("missing: no error")
+	}
+}
+
+func TestNormalizeTree(t *testing.T) {
+	var dir = t.TempDir()
+	var files = map[string]string{
+		"a.txt":       "a\r\n",
+		"b.txt":       "b\n",
+		"sub/c.md":    "# c\r\n",
+		"skip/d.txt":  "d\r\n",
+		"nul.txt":     "x\x00\r\n",
+		"pic.png":     "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\r\n",
+		"sub/e.xml":   "<a/>",
+		"sub/.hidden": "h\r\n",
+	}
+	for name, s := range files {
+		writeTestFile(t, dir, name, s)
+	}
+	var filter = func(path string, d fs.DirEntry) bool {
+		return d.Name() != "skip"
+	}
+	var pol = TextPolicy{LineEnding: LineEndLF, DryRun: true}
+	var want = []string{FP.Join(dir, "a.txt"), FP.Join(dir, "sub", ".hidden"),
+		FP.Join(dir, "sub", "c.md")}
+	changed, e := NormalizeTree(dir, pol, filter)
+	if e != nil || !reflect.DeepEqual(changed, want) {
+		t.Errorf("dry run: got %v, %v", changed, e)
+	}
+	if bb, _ := os.ReadFile(want[0]); string(bb) != "a\r\n" {
+		t.Errorf("dry run wrote %q", bb)
+	}
+	pol.DryRun = false
+	if changed, e = NormalizeTree(dir, pol, filter); e != nil ||
+		!reflect.DeepEqual(changed, want) {
+		t.Errorf("got %v, %v", changed, e)
+	}
+	for name, s := range files {
+		var path = FP.Join(dir, FP.FromSlash(name))
+		var was = s
+		for _, w := range want {
+			if w == path {
+				was = strings.ReplaceAll(s, "\r\n", "\n")
+			}
+		}
+		if bb, _ := os.ReadFile(path); string(bb) != was {
+			t.Errorf("%s: got %q, want %q", name, bb, was)
+		}
+	}
+}
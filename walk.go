@@ -0,0 +1,667 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"runtime"
+	"sort"
+	S "strings"
+	"sync"
+)
+
+// WalkErrorPolicy says what [WalkTree] does when an item
+// fails: when [NewFSObject] returns an error for it (for
+// example, it has vanished), or when it is a directory
+// that cannot be read (for example, permission denied).
+// Every failure is recorded, whatever the policy.
+type WalkErrorPolicy int
+
+const (
+	// WalkContinue keeps the failed item in the results with
+	// its error (see [Errer]), and for a directory, walks the
+	// entries that could be read before the error (if any).
+	WalkContinue WalkErrorPolicy = iota
+	// WalkSkip leaves the failed item (and, for a
+	// directory, all of its contents) out of the results.
+	WalkSkip
+	// WalkAbort stops the walk at the first failure, and
+	// [WalkTree] returns it (as a [*WalkError]).
+	WalkAbort
+)
+
+func (wep WalkErrorPolicy) String() string {
+	switch wep {
+	case WalkContinue:
+		return "continue"
+	case WalkSkip:
+		return "skip"
+	case WalkAbort:
+		return "abort"
+	}
+	return fmt.Sprintf("WalkErrorPolicy(%d)", int(wep))
+}
+
+// WalkError is a failure in [WalkTree], recorded against
+// the path of the item that failed. The FSObject for the
+// item is Item, which has the same error (see [Errer]).
+type WalkError struct {
+	Path string
+	Err  error
+	Item *FSObject
+}
+
+func (we *WalkError) Error() string {
+	return "walk: " + we.Path + ": " + we.Err.Error()
+}
+
+func (we *WalkError) Unwrap() error {
+	return we.Err
+}
+
+// WalkOptions configures [WalkTree]. The zero value (or a
+// nil pointer) is a valid configuration.
+type WalkOptions struct {
+	// Workers is the maximum number of goroutines that create
+	// FSObjects concurrently. If it is <= 0, [runtime.GOMAXPROCS]
+	// is used. Listing the directories is done by one goroutine.
+	Workers int
+	// Ordered makes the results come out in the same order as
+	// for [fs.WalkDir] (depth-first, lexical). Otherwise they
+	// come out in whatever order the workers finish them.
+	Ordered bool
+	// Yield, if set, is called (serially) for each FSObject as it
+	// is finished, and then the FSObjects are NOT collected in
+	// [WalkResult.Items]. If it returns an error, the walk stops
+	// and [WalkTree] returns that error (unless it is
+	// [fs.SkipAll], which stops the walk without an error).
+	Yield func(*FSObject) error
+	// OnError is the [WalkErrorPolicy]; the default is WalkContinue.
+	OnError WalkErrorPolicy
+	// FollowSymlinks makes the walk descend into symlinked
+	// directories, safely: see [SymlinkDisposition]. Every
+	// symlink is then reported in [WalkResult.Symlinks].
+	FollowSymlinks bool
+	// Confine is the directory that symlink targets must be in
+	// (after all links in the target path are resolved). If it is
+	// "", it is root. It is used only with FollowSymlinks, and if
+	// it cannot be resolved, WalkTree fails (rather than walking
+	// without confinement).
+	Confine string
+	// FollowEscapes makes the walk follow a symlink whose target
+	// is outside Confine; it is still flagged (see [SymlinkInfo]).
+	FollowEscapes bool
+	// MaxDepth limits how deep the walk goes: root is at depth 0,
+	// its entries at depth 1, and so on, and directories at depth
+	// MaxDepth are not walked. If it is <= 0, there is no limit.
+	MaxDepth int
+	// Prune, if set, is called for each directory (incl. a symlink
+	// that is to be followed) before it is walked; if it returns
+	// true, the directory is in the results but its contents are
+	// not. It is not called for root.
+	Prune WalkFilter
+	// Include, if set, is called for each item; if it returns false,
+	// the item is left out of the results (incl. [WalkResult.Stats]),
+	// but a directory is still walked (unless Prune says not to).
+	// It is not called for root. To use an exclusion function like
+	// [ExcludeFilepath_m5], see [ExcludeFunc].
+	Include WalkFilter
+	// SameDevice keeps the walk on root's device (like the
+	// -xdev option of find(1)): a directory on another device
+	// (i.e. a mount point) is in the results but is not walked.
+	// It has no effect where the OS does not provide device IDs.
+	SameDevice bool
+	// SkipRoot leaves root itself out of the results (unless it
+	// cannot be walked, so that the error is not lost).
+	SkipRoot bool
+}
+
+// WalkFilter is a predicate for [WalkOptions.Prune] and
+// [WalkOptions.Include]. rel is the item's path relative to
+// the walk's root, with slashes (not [os.PathSeparator]),
+// and d is its [fs.DirEntry], which describes a symlink
+// itself, not its target.
+type WalkFilter func(rel string, d fs.DirEntry) bool
+
+// ExcludeFunc is an exclusion function like [ExcludeFilepath_m5],
+// which returns true (plus a reason) for a path to exclude.
+type ExcludeFunc func(string) (bool, string)
+
+// Include adapts the ExcludeFunc for [WalkOptions.Include]. For
+// a directory, it passes the path with a trailing slash.
+func (f ExcludeFunc) Include() WalkFilter {
+	return func(rel string, d fs.DirEntry) bool {
+		var excl, _ = f(relForExclude(rel, d))
+		return !excl
+	}
+}
+
+// Prune adapts the ExcludeFunc for [WalkOptions.Prune], so that
+// an excluded directory is not walked. The directory itself is
+// still in the results, unless it is also excluded by Include.
+func (f ExcludeFunc) Prune() WalkFilter {
+	return func(rel string, d fs.DirEntry) bool {
+		var excl, _ = f(relForExclude(rel, d))
+		return excl
+	}
+}
+
+func relForExclude(rel string, d fs.DirEntry) string {
+	if d != nil && d.IsDir() {
+		return rel + "/"
+	}
+	return rel
+}
+
+// SymlinkDisposition is what [WalkTree] did with a symlink
+// when [WalkOptions.FollowSymlinks] is set. Only a link to a
+// directory is ever followed, and only if it is inside the
+// confining directory (see [WalkOptions.Confine]), and it
+// does not lead to a directory that has already been walked.
+// Directories are identified by device and inode (where the
+// OS provides them), so a cycle is found however it is made.
+//
+// The directory that a followed link leads to is opened and then
+// checked (by device and inode) against the one that the link was
+// checked for, so a link that is changed in between is caught (see
+// [LinkChanged]). But below a followed link, paths go thru the
+// link, and they are not re-checked, so if the link is changed
+// while its subtree is being walked, the rest of the subtree comes
+// from the new target. So confinement is not a defence against a
+// tree that is being changed by someone untrusted, and on an OS
+// that does not provide device and inode, there is no re-check.
+// .
+type SymlinkDisposition string
+
+const (
+	// LinkFollowed is a link to a directory that was walked.
+	LinkFollowed SymlinkDisposition = "followed"
+	// LinkNotDir is a link to something else (such as a file).
+	LinkNotDir SymlinkDisposition = "not-a-dir"
+	// LinkDangling is a link to something that does not exist.
+	LinkDangling SymlinkDisposition = "dangling"
+	// LinkUnresolvable is a link that cannot be resolved for
+	// another reason (such as a chain of links that is too
+	// long, or permission denied); see [SymlinkInfo.Err].
+	LinkUnresolvable SymlinkDisposition = "unresolvable"
+	// LinkCycle is a link to the directory that contains
+	// it, or to a directory that contains that one.
+	LinkCycle SymlinkDisposition = "cycle"
+	// LinkRevisit is a link to a directory that has already
+	// been walked. Note that a directory reached by its real
+	// path is always walked, so it can appear twice: below a
+	// link to it, and (later in the walk) at its real path.
+	LinkRevisit SymlinkDisposition = "already-walked"
+	// LinkEscapes is a link whose target is outside the
+	// confining directory, and that was not followed.
+	LinkEscapes SymlinkDisposition = "escapes-root"
+	// LinkChanged is a link that was to be followed, but that
+	// was changed (to lead somewhere else) before the directory
+	// was opened, which is reported as an error ([ErrLinkChanged]).
+	LinkChanged SymlinkDisposition = "changed"
+)
+
+// ErrLinkChanged is the error for [LinkChanged].
+var ErrLinkChanged = errors.New("symlink changed during walk")
+
+// SymlinkInfo is a symlink found by [WalkTree].
+type SymlinkInfo struct {
+	// Path is the path of the link, as walked.
+	Path string
+	// Target is the link's content (see [os.Readlink]).
+	Target string
+	// Resolved is the absolute path of the final target,
+	// with all links resolved, or "" if it cannot be.
+	Resolved    string
+	Disposition SymlinkDisposition
+	// Escapes is set if Resolved is outside [WalkOptions.Confine],
+	// whether or not the link was followed.
+	Escapes bool
+	Err     error
+	// key is of Resolved, when the link was checked.
+	key dirKey
+}
+
+func (li *SymlinkInfo) String() string {
+	var s = li.Path + " -> " + li.Target + " [" + string(li.Disposition) + "]"
+	if li.Escapes && li.Disposition != LinkEscapes {
+		s += " (escapes root)"
+	}
+	return s
+}
+
+// WalkResult is the result of [WalkTree].
+type WalkResult struct {
+	// Root is the path as it was passed to WalkTree.
+	Root string
+	// Items is every item found, starting with Root itself,
+	// unless [WalkOptions.Yield] was used.
+	Items []*FSObject
+	// Stats counts every item, whether or not it was collected,
+	// but not an item that was skipped (see [WalkSkip]).
+	Stats FSObjectSummaryStats
+	// Errors is every failure, in the same order as the items,
+	// including items that were skipped.
+	Errors []*WalkError
+	// Symlinks is every symlink, in the same order as the items,
+	// if [WalkOptions.FollowSymlinks] is set.
+	Symlinks []*SymlinkInfo
+}
+
+// WalkTree is a concurrent alternative to [GatherDirTreeList]
+// followed by [NewFSObjectSliceFromFilepathSlice]. It walks the
+// tree at root, and a pool of workers calls [NewFSObject] for each
+// item, so the results are fully populated FSObjects (but their
+// content is not loaded). Each item's path is root joined with
+// its path relative to root, so its [Filepaths] are the same as
+// if NewFSObject had been called on it directly.
+//
+// As for GatherDirTreeList, if root is a symlink to a directory,
+// it is followed, but no other symlink is, unless option
+// [WalkOptions.FollowSymlinks] is set. Below a followed link,
+// paths go thru the link, not to its target.
+//
+// Errors do not make the walk fail (unless [WalkOptions.OnError]
+// is [WalkAbort]): each one is recorded in [WalkResult.Errors],
+// and the item's FSObject gets the error (see [Errer]). If ctx
+// is cancelled, the walk stops, and WalkTree returns what it has
+// so far, plus ctx.Err().
+// .
+func WalkTree(ctx context.Context, root string, opts *WalkOptions) (*WalkResult, error) {
+	if root == "" {
+		return nil, &fs.PathError{Op: "walktree", Path: root, Err: fs.ErrInvalid}
+	}
+	var o WalkOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Workers <= 0 {
+		o.Workers = runtime.GOMAXPROCS(0)
+	}
+	var parent = ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var jobs = make(chan walkJob, o.Workers)
+	var dones = make(chan walkJob, o.Workers)
+	var w = treeWalker{ctx: ctx, jobs: jobs,
+		skipBadDirs: o.OnError == WalkSkip,
+		maxDepth:    o.MaxDepth, prune: o.Prune, include: o.Include,
+		skipRoot: o.SkipRoot}
+	if o.FollowSymlinks {
+		if e := w.initFollow(root, o); e != nil {
+			return nil, e
+		}
+	}
+	go func() {
+		defer close(jobs)
+		var n = walkNode{path: root, rel: "."}
+		if o.SameDevice {
+			if fi, e := os.Stat(root); e == nil {
+				var st StatInfo
+				st, w.sameDev = statInfoOf(fi)
+				w.rootDev = st.Dev
+			}
+		}
+		if !o.FollowSymlinks {
+			w.visit(n, w.isDirOrLinkToDir(root), nil)
+			return
+		}
+		if fi, e := os.Lstat(root); e == nil && fi.Mode()&fs.ModeSymlink != 0 {
+			var li, descend = w.checkLink(root)
+			w.visit(n, descend, li)
+		} else {
+			w.visit(n, w.isDirOrLinkToDir(root), nil)
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < o.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				j.fso = NewFSObject(j.path)
+				if j.err != nil && !j.fso.HasError() {
+					j.fso.SetError(j.err)
+				}
+				select {
+				case dones <- j:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(dones)
+	}()
+
+	// Collect (and maybe reorder) the results
+	// serially, so that no locking is needed.
+	var pWR = &WalkResult{Root: root}
+	// stopErr is from Yield, or is a *WalkError for WalkAbort.
+	var stopErr error
+	var emit = func(j walkJob) {
+		if stopErr != nil {
+			return
+		}
+		if j.link != nil {
+			pWR.Symlinks = append(pWR.Symlinks, j.link)
+		}
+		if j.fso.HasError() {
+			var we = &WalkError{Path: j.path, Err: j.fso.Err, Item: j.fso}
+			pWR.Errors = append(pWR.Errors, we)
+			switch o.OnError {
+			case WalkSkip:
+				return
+			case WalkAbort:
+				stopErr = we
+				cancel()
+				return
+			}
+		}
+		pWR.Stats.AddIn(j.fso)
+		if o.Yield == nil {
+			pWR.Items = append(pWR.Items, j.fso)
+		} else if stopErr = o.Yield(j.fso); stopErr != nil {
+			cancel()
+		}
+	}
+	var pending = make(map[int]walkJob)
+	var next int
+	for j := range dones {
+		if !o.Ordered {
+			emit(j)
+			continue
+		}
+		pending[j.seq] = j
+		for j, ok := pending[next]; ok; j, ok = pending[next] {
+			delete(pending, next)
+			next++
+			emit(j)
+		}
+	}
+	if stopErr != nil && stopErr != fs.SkipAll {
+		return pWR, stopErr
+	}
+	if e := parent.Err(); e != nil {
+		return pWR, e
+	}
+	return pWR, nil
+}
+
+// walkJob is an item found by the [treeWalker], on its
+// way to (and then back from) a worker in [WalkTree].
+type walkJob struct {
+	seq  int
+	path string
+	// err is from reading the directory
+	err  error
+	link *SymlinkInfo
+	fso  *FSObject
+}
+
+// treeWalker lists the directories for [WalkTree].
+type treeWalker struct {
+	ctx  context.Context
+	jobs chan<- walkJob
+	seq  int
+	// skipBadDirs is for [WalkSkip]: the entries that
+	// were read before an error are not walked.
+	skipBadDirs bool
+	maxDepth    int
+	prune       WalkFilter
+	include     WalkFilter
+	skipRoot    bool
+	// sameDev is for [WalkOptions.SameDevice], and
+	// is not set if the root device is unknown.
+	sameDev bool
+	rootDev uint64
+
+	// The rest is for [WalkOptions.FollowSymlinks].
+	follow        bool
+	followEscapes bool
+	// confine is absolute and resolved, or "" if unknown.
+	confine string
+	// visited is every directory walked, and onPath
+	// is the directories that contain the current one.
+	visited, onPath map[dirKey]bool
+}
+
+// dirKey identifies a directory. Where the OS does not
+// provide a device and inode, the resolved path is used.
+type dirKey struct {
+	dev, ino uint64
+	path     string
+}
+
+// initFollow fails if the confining directory cannot be
+// resolved, because then no link could be checked against it.
+func (w *treeWalker) initFollow(root string, o WalkOptions) error {
+	w.follow = true
+	w.followEscapes = o.FollowEscapes
+	w.visited = make(map[dirKey]bool)
+	w.onPath = make(map[dirKey]bool)
+	var confine = o.Confine
+	if confine == "" {
+		confine = root
+	}
+	s, e := FP.EvalSymlinks(confine)
+	if e == nil {
+		w.confine, e = FP.Abs(s)
+	}
+	if e != nil {
+		return &fs.PathError{Op: "walktree:confine", Path: confine, Err: e}
+	}
+	return nil
+}
+
+// keyOf returns the dirKey of the directory at path (which
+// can be a link to it), or false if it cannot be stat'd.
+func (w *treeWalker) keyOf(path string) (dirKey, bool) {
+	fi, e := os.Stat(path)
+	if e != nil {
+		return dirKey{}, false
+	}
+	return w.keyOfInfo(path, fi)
+}
+
+// keyOfInfo is [keyOf] where fi has already been got (for
+// example, from the open directory) and so is not re-read.
+func (w *treeWalker) keyOfInfo(path string, fi fs.FileInfo) (dirKey, bool) {
+	if st, ok := statInfoOf(fi); ok {
+		return dirKey{dev: st.Dev, ino: st.Ino}, true
+	}
+	s, e := FP.EvalSymlinks(path)
+	if e != nil {
+		return dirKey{}, false
+	}
+	s, _ = FP.Abs(s)
+	return dirKey{path: s}, true
+}
+
+// checkLink decides what to do with the symlink at
+// path, and returns true if it is to be followed.
+func (w *treeWalker) checkLink(path string) (*SymlinkInfo, bool) {
+	var li = &SymlinkInfo{Path: path}
+	li.Target, li.Err = os.Readlink(path)
+	if li.Err != nil {
+		li.Disposition = LinkUnresolvable
+		return li, false
+	}
+	s, e := FP.EvalSymlinks(path)
+	if e != nil {
+		li.Err = e
+		li.Disposition = LinkUnresolvable
+		if errors.Is(e, fs.ErrNotExist) {
+			li.Disposition = LinkDangling
+		}
+		return li, false
+	}
+	li.Resolved, _ = FP.Abs(s)
+	if w.confine != "" && !isWithin(w.confine, li.Resolved) {
+		li.Escapes = true
+		if !w.followEscapes {
+			li.Disposition = LinkEscapes
+			return li, false
+		}
+	}
+	fi, e := os.Stat(path)
+	if e != nil {
+		li.Err = e
+		li.Disposition = LinkUnresolvable
+		return li, false
+	}
+	if !fi.IsDir() {
+		li.Disposition = LinkNotDir
+		return li, false
+	}
+	// The key is of the target that was checked, so
+	// that the directory can be re-checked when opened.
+	key, ok := w.keyOf(li.Resolved)
+	li.key = key
+	switch {
+	case !ok:
+		li.Disposition = LinkUnresolvable
+		return li, false
+	case w.onPath[key]:
+		li.Disposition = LinkCycle
+		return li, false
+	case w.visited[key]:
+		li.Disposition = LinkRevisit
+		return li, false
+	}
+	li.Disposition = LinkFollowed
+	return li, true
+}
+
+// isWithin is true if path is dir or is inside it. Both
+// must be absolute and clean, with no symlinks.
+func isWithin(dir, path string) bool {
+	rel, e := FP.Rel(dir, path)
+	return e == nil && rel != ".." &&
+		!S.HasPrefix(rel, ".."+string(FP.Separator))
+}
+
+// isDirOrLinkToDir follows a symlink.
+func (w *treeWalker) isDirOrLinkToDir(path string) bool {
+	fi, e := os.Stat(path)
+	return e == nil && fi.IsDir()
+}
+
+// walkNode is an item as the [treeWalker] sees it. For root,
+// de is nil, and rel is ".".
+type walkNode struct {
+	path, rel string
+	de        fs.DirEntry
+	depth     int
+}
+
+// visit sends the item (unless it is not included), and then
+// if descend is set (and it is not pruned, etc.), its descendants
+// (depth-first, lexical). It returns false if the walk has been
+// cancelled. link is set if the item is a symlink and links are
+// being followed.
+func (w *treeWalker) visit(n walkNode, descend bool, link *SymlinkInfo) bool {
+	var des []os.DirEntry
+	var e error
+	if descend && w.maxDepth > 0 && n.depth >= w.maxDepth {
+		descend = false
+	}
+	if descend && n.de != nil && w.prune != nil && w.prune(n.rel, n.de) {
+		descend = false
+	}
+	if descend {
+		var key dirKey
+		des, key, descend, e = w.readDir(n.path, link)
+		if descend && w.follow && key != (dirKey{}) {
+			w.visited[key] = true
+			w.onPath[key] = true
+			defer delete(w.onPath, key)
+		}
+		if e != nil && w.skipBadDirs {
+			des = nil
+		}
+	}
+	var send bool
+	if n.de == nil {
+		send = !w.skipRoot || e != nil || !descend
+	} else {
+		send = w.include == nil || w.include(n.rel, n.de)
+	}
+	if send {
+		if !w.send(n.path, e, link) {
+			return false
+		}
+	}
+	for _, de := range des {
+		var child = walkNode{path: FP.Join(n.path, de.Name()),
+			rel: de.Name(), de: de, depth: n.depth + 1}
+		if n.rel != "." {
+			child.rel = n.rel + "/" + de.Name()
+		}
+		var ok bool
+		if w.follow && de.Type()&fs.ModeSymlink != 0 {
+			var li, descend = w.checkLink(child.path)
+			ok = w.visit(child, descend, li)
+		} else {
+			ok = w.visit(child, de.IsDir(), nil)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// readDir opens the directory and then checks it (using the open
+// directory, so that it cannot be swapped for another one in
+// between): that it is on the root's device, if that is asked
+// for, and if it is reached by a link being followed, that it is
+// the directory that the link was checked for. It returns false
+// if the directory is not to be walked, and for a link, also the
+// dirKey. On a read error, des is what was read before it.
+// .
+func (w *treeWalker) readDir(path string, link *SymlinkInfo) (des []os.DirEntry, key dirKey, ok bool, e error) {
+	pF, e := os.Open(path)
+	if e != nil {
+		return nil, key, true, e
+	}
+	defer pF.Close()
+	if w.follow || w.sameDev {
+		var fi fs.FileInfo
+		if fi, e = pF.Stat(); e != nil {
+			return nil, key, true, e
+		}
+		var got bool
+		if key, got = w.keyOfInfo(path, fi); got {
+			if w.sameDev && key.path == "" && key.dev != w.rootDev {
+				return nil, key, false, nil
+			}
+			if link != nil && link.key != (dirKey{}) && key != link.key {
+				link.Disposition = LinkChanged
+				link.Err = ErrLinkChanged
+				return nil, key, false, &fs.PathError{
+					Op: "walktree", Path: path, Err: ErrLinkChanged}
+			}
+		}
+	}
+	des, e = pF.ReadDir(-1)
+	sort.Slice(des, func(i, j int) bool {
+		return des[i].Name() < des[j].Name()
+	})
+	return des, key, true, e
+}
+
+func (w *treeWalker) send(path string, e error, link *SymlinkInfo) bool {
+	select {
+	case w.jobs <- walkJob{seq: w.seq, path: path, err: e, link: link}:
+		w.seq++
+		return true
+	case <-w.ctx.Done():
+		return false
+	}
+}
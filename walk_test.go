@@ -0,0 +1,630 @@
+package fileutils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	FP "path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// walkRels is the paths of the items, relative
+// to root and with slashes, in the same order.
+func walkRels(t *testing.T, root string, items []*FSObject) []string {
+	t.Helper()
+	var rels = []string{}
+	for _, p := range items {
+		rel, e := FP.Rel(root, trimPathSepSuffix(p.FPs.AbsFP))
+		if e != nil {
+			t.Fatal(e)
+		}
+		rels = append(rels, FP.ToSlash(rel))
+	}
+	return rels
+}
+
+// walkDirRels is the paths that [fs.WalkDir] finds,
+// which are the ones that an ordered WalkTree should.
+func walkDirRels(t *testing.T, root string) []string {
+	t.Helper()
+	var rels []string
+	e := fs.WalkDir(os.DirFS(root), ".", func(path string, d fs.DirEntry, e error) error {
+		rels = append(rels, path)
+		return e
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	return rels
+}
+
+var testTreeFiles = map[string]string{"a/x.txt": "", "a/y/z.txt": "", "a/y/zz/": "",
+	"b.txt": "", "c/": "", "d/e/f/g.txt": "", "d/e/h.txt": "", "d/i.txt": ""}
+
+func TestWalkTreeOrdered(t *testing.T) {
+	var root = writeTestTree(t, "", testTreeFiles)
+	for _, workers := range []int{0, 1, 3, 16} {
+		pWR, e := WalkTree(context.Background(), root,
+			&WalkOptions{Workers: workers, Ordered: true})
+		if e != nil {
+			t.Fatal(e)
+		}
+		var want = walkDirRels(t, root)
+		if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got, want) {
+			t.Errorf("%d workers:\n got %v\nwant %v", workers, got, want)
+		}
+		var st = pWR.Stats
+		if st.NrItems != 14 || st.NrDirs != 8 || st.NrFiles != 6 || st.NrErrors != 0 ||
+			len(pWR.Errors) != 0 || pWR.Root != root {
+			t.Errorf("%d workers: stats %+v, errors %v", workers, st, pWR.Errors)
+		}
+	}
+}
+
+// TestWalkTreeItems checks that the items are the same
+// as if NewFSObject had been called on each path.
+func TestWalkTreeItems(t *testing.T) {
+	var root = writeTestTree(t, "", testTreeFiles)
+	pWR, e := WalkTree(context.Background(), root, nil)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, p := range pWR.Items {
+		var q = NewFSObject(trimPathSepSuffix(p.FPs.AbsFP))
+		if p.FPs.AbsFP != q.FPs.AbsFP || p.FPs.RelFP != q.FPs.RelFP ||
+			p.FSO_type != q.FSO_type || p.Size() != q.Size() ||
+			p.Stat.Ino != q.Stat.Ino || p.TypedRaw != nil {
+			t.Errorf("got %+v, want %+v", p.FPs, q.FPs)
+		}
+	}
+	// Unordered, the items are the same, in some order.
+	var got = walkRels(t, root, pWR.Items)
+	var want = walkDirRels(t, root)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unordered:\n got %v\nwant %v", got, want)
+	}
+}
+
+// TestWalkTreeMany is for the race detector.
+func TestWalkTreeMany(t *testing.T) {
+	var root = t.TempDir()
+	for i := range 10 {
+		for j := range 20 {
+			writeTestFile(t, root, fmt.Sprintf("d%d/f%02d", i, j), "")
+		}
+	}
+	pWR, e := WalkTree(context.Background(), root,
+		&WalkOptions{Workers: 8, Ordered: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got, walkDirRels(t, root)) {
+		t.Errorf("got %v", got)
+	}
+	if pWR.Stats.NrFiles != 200 || pWR.Stats.NrDirs != 11 {
+		t.Errorf("stats %+v", pWR.Stats)
+	}
+}
+
+func TestWalkTreeYield(t *testing.T) {
+	var root = writeTestTree(t, "", testTreeFiles)
+	var got []*FSObject
+	var opts = &WalkOptions{Ordered: true, Workers: 4,
+		Yield: func(p *FSObject) error {
+			got = append(got, p)
+			return nil
+		}}
+	pWR, e := WalkTree(context.Background(), root, opts)
+	if e != nil || pWR.Items != nil || pWR.Stats.NrItems != 14 {
+		t.Fatalf("got %v, %d items, %+v", e, len(pWR.Items), pWR.Stats)
+	}
+	if rels := walkRels(t, root, got); !reflect.DeepEqual(rels, walkDirRels(t, root)) {
+		t.Errorf("yielded %v", rels)
+	}
+	// SkipAll stops the walk, but is not an error.
+	got = nil
+	opts.Yield = func(p *FSObject) error {
+		got = append(got, p)
+		if len(got) == 3 {
+			return fs.SkipAll
+		}
+		return nil
+	}
+	if pWR, e = WalkTree(context.Background(), root, opts); e != nil ||
+		len(got) != 3 || pWR.Stats.NrItems != 3 {
+		t.Errorf("SkipAll: got %v, %d yielded, %+v", e, len(got), pWR.Stats)
+	}
+	var errStop = errors.New("stop")
+	got = nil
+	opts.Yield = func(p *FSObject) error {
+		got = append(got, p)
+		return errStop
+	}
+	if _, e = WalkTree(context.Background(), root, opts); e != errStop || len(got) != 1 {
+		t.Errorf("error: got %v, %d yielded", e, len(got))
+	}
+}
+
+func TestWalkTreeCancel(t *testing.T) {
+	var root = writeTestTree(t, "", testTreeFiles)
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, e := WalkTree(ctx, root, nil); !errors.Is(e, context.Canceled) {
+		t.Errorf("cancelled: got %v", e)
+	}
+	// Cancelled part way thru.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var n int
+	pWR, e := WalkTree(ctx, root, &WalkOptions{Ordered: true,
+		Yield: func(p *FSObject) error {
+			if n++; n == 2 {
+				cancel()
+			}
+			return nil
+		}})
+	if !errors.Is(e, context.Canceled) || pWR == nil || pWR.Stats.NrItems >= 14 {
+		t.Errorf("got %v, %+v", e, pWR)
+	}
+}
+
+func TestWalkTreeRoots(t *testing.T) {
+	var dir = writeTestTree(t, "", map[string]string{"tree/a.txt": "", "tree/b/c.txt": ""})
+	if _, e := WalkTree(context.Background(), "", nil); !errors.Is(e, fs.ErrInvalid) {
+		t.Errorf("empty root: got %v", e)
+	}
+	// A file is a tree of one.
+	pWR, e := WalkTree(context.Background(), FP.Join(dir, "tree", "a.txt"), nil)
+	if e != nil || len(pWR.Items) != 1 || !pWR.Items[0].IsFile() {
+		t.Errorf("file: got %v, %v", e, pWR.Items)
+	}
+	// A symlink root is followed, and paths go thru it.
+	var link = FP.Join(dir, "link")
+	if e := os.Symlink("tree", link); e != nil {
+		t.Skip(e)
+	}
+	pWR, e = WalkTree(context.Background(), link, &WalkOptions{Ordered: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	var got []string
+	for _, p := range pWR.Items {
+		got = append(got, trimPathSepSuffix(p.FPs.AbsFP))
+	}
+	var want = []string{link, FP.Join(link, "a.txt"), FP.Join(link, "b"),
+		FP.Join(link, "b", "c.txt")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("link root:\n got %v\nwant %v", got, want)
+	}
+}
+
+// walkWithFailures walks a tree in which, during the walk,
+// directory a/y is replaced by a file (so that it cannot be
+// read) and file b.txt is removed (so that it cannot be stat'd).
+func walkWithFailures(t *testing.T, policy WalkErrorPolicy) (string, *WalkResult, error) {
+	t.Helper()
+	var root = writeTestTree(t, "", testTreeFiles)
+	var opts = &WalkOptions{Ordered: true, Workers: 4, OnError: policy,
+		Prune: func(rel string, d fs.DirEntry) bool {
+			if rel == "a/y" {
+				var path = FP.Join(root, "a", "y")
+				os.RemoveAll(path)
+				writeTestFile(t, root, "a/y", "not a dir")
+			}
+			return false
+		},
+		Include: func(rel string, d fs.DirEntry) bool {
+			if rel == "b.txt" {
+				os.Remove(FP.Join(root, "b.txt"))
+			}
+			return true
+		}}
+	pWR, e := WalkTree(context.Background(), root, opts)
+	return root, pWR, e
+}
+
+func TestWalkTreeErrors(t *testing.T) {
+	var tests = []struct {
+		policy WalkErrorPolicy
+		items  []string
+	}{
+		{WalkContinue, []string{".", "a", "a/x.txt", "a/y", "b.txt", "c",
+			"d", "d/e", "d/e/f", "d/e/f/g.txt", "d/e/h.txt", "d/i.txt"}},
+		{WalkSkip, []string{".", "a", "a/x.txt", "c",
+			"d", "d/e", "d/e/f", "d/e/f/g.txt", "d/e/h.txt", "d/i.txt"}},
+	}
+	for _, tc := range tests {
+		root, pWR, e := walkWithFailures(t, tc.policy)
+		if e != nil {
+			t.Fatalf("%s: %v", tc.policy, e)
+		}
+		var got []string
+		for _, p := range pWR.Items {
+			if p.HasError() {
+				// A vanished file has no paths, but the error has.
+				got = append(got, "")
+				continue
+			}
+			got = append(got, walkRels(t, root, []*FSObject{p})[0])
+		}
+		var errPaths []string
+		for _, we := range pWR.Errors {
+			rel, _ := FP.Rel(root, we.Path)
+			errPaths = append(errPaths, FP.ToSlash(rel))
+			if we.Item == nil || we.Item.GetError() == nil || we.Err == nil {
+				t.Errorf("%s: %s: no error on the item", tc.policy, rel)
+			}
+		}
+		if !reflect.DeepEqual(errPaths, []string{"a/y", "b.txt"}) {
+			t.Errorf("%s: errors %v", tc.policy, pWR.Errors)
+		}
+		if !errors.Is(pWR.Errors[1], fs.ErrNotExist) {
+			t.Errorf("%s: got %v", tc.policy, pWR.Errors[1])
+		}
+		if len(got) != len(tc.items) || pWR.Stats.NrItems != len(tc.items) {
+			t.Errorf("%s: got %v, want %v", tc.policy, got, tc.items)
+		}
+		var nErrs = map[WalkErrorPolicy]int{WalkContinue: 2}[tc.policy]
+		if pWR.Stats.NrErrors != nErrs {
+			t.Errorf("%s: stats %+v", tc.policy, pWR.Stats)
+		}
+	}
+}
+
+func TestWalkTreeAbort(t *testing.T) {
+	root, pWR, e := walkWithFailures(t, WalkAbort)
+	var we *WalkError
+	if !errors.As(e, &we) || we.Path != FP.Join(root, "a", "y") {
+		t.Fatalf("got %v", e)
+	}
+	if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got,
+		[]string{".", "a", "a/x.txt"}) || len(pWR.Errors) != 1 {
+		t.Errorf("got %v, errors %v", got, pWR.Errors)
+	}
+	if s := we.Error(); s != "walk: "+we.Path+": "+we.Err.Error() {
+		t.Errorf("Error: %q", s)
+	}
+	if WalkAbort.String() != "abort" || WalkErrorPolicy(9).String() != "WalkErrorPolicy(9)" {
+		t.Error("String")
+	}
+}
+
+// TestWalkTreeMissingRoot is an error that does not depend on
+// timing or on who runs the test: the root itself is missing.
+func TestWalkTreeMissingRoot(t *testing.T) {
+	var root = FP.Join(t.TempDir(), "missing")
+	for _, policy := range []WalkErrorPolicy{WalkContinue, WalkSkip, WalkAbort} {
+		pWR, e := WalkTree(context.Background(), root,
+			&WalkOptions{Ordered: true, OnError: policy})
+		if len(pWR.Errors) != 1 || pWR.Errors[0].Path != root {
+			t.Fatalf("%s: errors %v", policy, pWR.Errors)
+		}
+		var nItems = map[WalkErrorPolicy]int{WalkContinue: 1}[policy]
+		if len(pWR.Items) != nItems || pWR.Stats.NrErrors != nItems {
+			t.Errorf("%s: %d items, stats %+v", policy, len(pWR.Items), pWR.Stats)
+		}
+		var we *WalkError
+		if (policy == WalkAbort) != errors.As(e, &we) {
+			t.Errorf("%s: got %v", policy, e)
+		}
+	}
+}
+
+// TestWalkTreePermission needs a user that permissions apply to.
+func TestWalkTreePermission(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("permissions do not apply to root")
+	}
+	var root = writeTestTree(t, "", map[string]string{"a/x.txt": "", "b/y.txt": ""})
+	var locked = FP.Join(root, "a")
+	if e := os.Chmod(locked, 0); e != nil {
+		t.Skip(e)
+	}
+	defer os.Chmod(locked, 0755)
+	pWR, e := WalkTree(context.Background(), root, &WalkOptions{Ordered: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(pWR.Errors) != 1 || pWR.Errors[0].Path != locked ||
+		!errors.Is(pWR.Errors[0], fs.ErrPermission) {
+		t.Errorf("errors %v", pWR.Errors)
+	}
+	if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got,
+		[]string{".", "a", "b", "b/y.txt"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+// linkTreeFiles is a tree (at root) with every kind of
+// symlink, and a directory outside it (outside).
+var linkTreeFiles = map[string]string{"outside/o.txt": "",
+	"root/a/x.txt": "", "root/b/y.txt": "", "root/z/w.txt": "",
+	"root/a/up -> ..": "", "root/a/self -> .": "", "root/lb -> b": "",
+	"root/lnk -> z": "", "root/file.lnk -> b/y.txt": "",
+	"root/dang -> nowhere": "", "root/out -> ../outside": ""}
+
+// linkDispositions is the Disposition of each symlink, by relative path.
+func linkDispositions(t *testing.T, root string, pWR *WalkResult) map[string]SymlinkDisposition {
+	t.Helper()
+	var m = make(map[string]SymlinkDisposition)
+	for _, li := range pWR.Symlinks {
+		rel, _ := FP.Rel(root, li.Path)
+		m[FP.ToSlash(rel)] = li.Disposition
+	}
+	return m
+}
+
+func TestWalkTreeFollowSymlinks(t *testing.T) {
+	var root = FP.Join(writeTestTree(t, "", linkTreeFiles), "root")
+	pWR, e := WalkTree(context.Background(), root,
+		&WalkOptions{Ordered: true, FollowSymlinks: true})
+	if e != nil {
+		t.Fatal(e)
+	}
+	var want = map[string]SymlinkDisposition{
+		"a/self": LinkCycle, "a/up": LinkCycle, "dang": LinkDangling,
+		"file.lnk": LinkNotDir, "lb": LinkRevisit, "lnk": LinkFollowed,
+		"out": LinkEscapes,
+	}
+	if got := linkDispositions(t, root, pWR); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v", got)
+	}
+	// The followed link's directory is walked (again) at its real path.
+	var items = walkRels(t, root, pWR.Items)
+	var wantItems = []string{".", "a", "a/self", "a/up", "a/x.txt", "b", "b/y.txt",
+		"dang", "file.lnk", "lb", "lnk", "lnk/w.txt", "out", "z", "z/w.txt"}
+	if !reflect.DeepEqual(items, wantItems) {
+		t.Errorf("items:\n got %v\nwant %v", items, wantItems)
+	}
+	if len(pWR.Errors) != 0 || pWR.Stats.NrSymLs != 7 {
+		t.Errorf("errors %v, stats %+v", pWR.Errors, pWR.Stats)
+	}
+	for _, li := range pWR.Symlinks {
+		switch li.Disposition {
+		case LinkFollowed:
+			if li.Target != "z" || li.Resolved != mustEval(t, FP.Join(root, "z")) {
+				t.Errorf("followed: %+v", li)
+			}
+		case LinkDangling:
+			if li.Target != "nowhere" || li.Resolved != "" || li.Err == nil {
+				t.Errorf("dangling: %+v", li)
+			}
+		case LinkEscapes:
+			if !li.Escapes || li.String() != li.Path+" -> ../outside [escapes-root]" {
+				t.Errorf("escapes: %s", li)
+			}
+		}
+	}
+	// Without FollowSymlinks, the links are items, not walked.
+	pWR, e = WalkTree(context.Background(), root, &WalkOptions{Ordered: true})
+	if e != nil || pWR.Symlinks != nil || len(pWR.Items) != 14 {
+		t.Errorf("not following: got %v, %d items, %v", e, len(pWR.Items), pWR.Symlinks)
+	}
+}
+
+func mustEval(t *testing.T, path string) string {
+	t.Helper()
+	s, e := FP.EvalSymlinks(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	s, _ = FP.Abs(s)
+	return s
+}
+
+func TestWalkTreeConfine(t *testing.T) {
+	var parent = writeTestTree(t, "", linkTreeFiles)
+	var root = FP.Join(parent, "root")
+	var wantOut = []string{"out", "out/o.txt"}
+	var tests = []struct {
+		name string
+		opts WalkOptions
+		disp SymlinkDisposition
+	}{
+		{"escapes followed", WalkOptions{FollowEscapes: true}, LinkFollowed},
+		{"wider confine", WalkOptions{Confine: parent}, LinkFollowed},
+		{"narrower confine", WalkOptions{Confine: FP.Join(root, "a")}, LinkEscapes},
+	}
+	for _, tc := range tests {
+		tc.opts.Ordered, tc.opts.FollowSymlinks = true, true
+		pWR, e := WalkTree(context.Background(), root, &tc.opts)
+		if e != nil {
+			t.Fatalf("%s: %v", tc.name, e)
+		}
+		var out *SymlinkInfo
+		for _, li := range pWR.Symlinks {
+			if FP.Base(li.Path) == "out" {
+				out = li
+			}
+		}
+		if out == nil || out.Disposition != tc.disp ||
+			out.Escapes == (tc.opts.Confine == parent) {
+			t.Errorf("%s: got %v", tc.name, out)
+		}
+		var got []string
+		for _, rel := range walkRels(t, root, pWR.Items) {
+			if rel == "out" || FP.Dir(rel) == "out" {
+				got = append(got, rel)
+			}
+		}
+		if tc.disp == LinkFollowed && !reflect.DeepEqual(got, wantOut) {
+			t.Errorf("%s: got %v", tc.name, got)
+		}
+	}
+	// A followed escape is flagged.
+	pWR, _ := WalkTree(context.Background(), root,
+		&WalkOptions{FollowSymlinks: true, FollowEscapes: true})
+	for _, li := range pWR.Symlinks {
+		if FP.Base(li.Path) == "out" && li.String() != li.Path+
+			" -> ../outside [followed] (escapes root)" {
+			t.Errorf("String: %q", li)
+		}
+	}
+	// A confining directory that cannot be resolved is an error.
+	_, e := WalkTree(context.Background(), root, &WalkOptions{FollowSymlinks: true,
+		Confine: FP.Join(parent, "nope")})
+	var pe *fs.PathError
+	if !errors.As(e, &pe) || pe.Op != "walktree:confine" {
+		t.Errorf("bad confine: got %v", e)
+	}
+}
+
+// TestWalkTreeLinkChanged changes a link after it has been
+// checked, but before its directory is opened, which is
+// when Prune is called.
+func TestWalkTreeLinkChanged(t *testing.T) {
+	var root = FP.Join(writeTestTree(t, "", linkTreeFiles), "root")
+	var lnk = FP.Join(root, "lnk")
+	pWR, e := WalkTree(context.Background(), root, &WalkOptions{Ordered: true,
+		FollowSymlinks: true, Prune: func(rel string, d fs.DirEntry) bool {
+			if rel == "lnk" {
+				os.Remove(lnk)
+				os.Symlink("a", lnk)
+			}
+			return false
+		}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got := linkDispositions(t, root, pWR)["lnk"]; got != LinkChanged {
+		t.Errorf("got %s", got)
+	}
+	if len(pWR.Errors) != 1 || pWR.Errors[0].Path != lnk ||
+		!errors.Is(pWR.Errors[0], ErrLinkChanged) {
+		t.Errorf("errors %v", pWR.Errors)
+	}
+	for _, rel := range walkRels(t, root, pWR.Items) {
+		if FP.Dir(rel) == "lnk" {
+			t.Errorf("walked %s", rel)
+		}
+	}
+}
+
+func TestWalkTreeLimits(t *testing.T) {
+	var root = writeTestTree(t, "", testTreeFiles)
+	var isTxt = func(rel string, d fs.DirEntry) bool {
+		return FP.Ext(rel) == ".txt"
+	}
+	var tests = []struct {
+		name string
+		opts WalkOptions
+		want []string
+	}{
+		{"depth 1", WalkOptions{MaxDepth: 1},
+			[]string{".", "a", "b.txt", "c", "d"}},
+		{"depth 2", WalkOptions{MaxDepth: 2},
+			[]string{".", "a", "a/x.txt", "a/y", "b.txt", "c", "d", "d/e", "d/i.txt"}},
+		{"no limit", WalkOptions{MaxDepth: -1}, walkDirRels(t, root)},
+		// A pruned directory is in the results, but not its contents.
+		{"prune", WalkOptions{Prune: func(rel string, d fs.DirEntry) bool {
+			return d.Name() == "e" || rel == "a"
+		}}, []string{".", "a", "b.txt", "c", "d", "d/e", "d/i.txt"}},
+		// Directories that are not included are still walked,
+		{"include", WalkOptions{Include: isTxt}, []string{".", "a/x.txt",
+			"a/y/z.txt", "b.txt", "d/e/f/g.txt", "d/e/h.txt", "d/i.txt"}},
+		// unless they are pruned.
+		{"include and prune", WalkOptions{Include: isTxt, MaxDepth: 2,
+			Prune: func(rel string, d fs.DirEntry) bool { return rel == "d" }},
+			[]string{".", "a/x.txt", "b.txt"}},
+	}
+	for _, tc := range tests {
+		tc.opts.Ordered = true
+		pWR, e := WalkTree(context.Background(), root, &tc.opts)
+		if e != nil {
+			t.Fatalf("%s: %v", tc.name, e)
+		}
+		if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s:\n got %v\nwant %v", tc.name, got, tc.want)
+		}
+		if pWR.Stats.NrItems != len(tc.want) {
+			t.Errorf("%s: stats %+v", tc.name, pWR.Stats)
+		}
+	}
+}
+
+func TestWalkTreeFilterArgs(t *testing.T) {
+	var root = writeTestTree(t, "", map[string]string{"a/b/c.txt": ""})
+	var prunes, includes []string
+	_, e := WalkTree(context.Background(), root, &WalkOptions{Ordered: true,
+		Prune: func(rel string, d fs.DirEntry) bool {
+			prunes = append(prunes, rel)
+			return false
+		},
+		Include: func(rel string, d fs.DirEntry) bool {
+			if d.Name() != FP.Base(rel) {
+				t.Errorf("%s: entry %s", rel, d.Name())
+			}
+			includes = append(includes, rel)
+			return true
+		}})
+	if e != nil {
+		t.Fatal(e)
+	}
+	// Neither is called for root, and rel has slashes.
+	if !reflect.DeepEqual(prunes, []string{"a", "a/b"}) ||
+		!reflect.DeepEqual(includes, []string{"a", "a/b", "a/b/c.txt"}) {
+		t.Errorf("prune %v, include %v", prunes, includes)
+	}
+}
+
+func TestWalkTreeExcludeFunc(t *testing.T) {
+	var root = writeTestTree(t, "", map[string]string{"_build/out.txt": "", ".git/config": "", "src/a.go": "", "src/a.go~": "", "src/_gen/b.go": ""})
+	var xf = ExcludeFunc(ExcludeFilepath_m5)
+	pWR, e := WalkTree(context.Background(), root, &WalkOptions{Ordered: true,
+		Include: xf.Include(), Prune: xf.Prune()})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if got := walkRels(t, root, pWR.Items); !reflect.DeepEqual(got,
+		[]string{".", "src", "src/a.go"}) {
+		t.Errorf("include and prune: got %v", got)
+	}
+	// Pruned, but included, directories are in the results.
+	pWR, e = WalkTree(context.Background(), root, &WalkOptions{Ordered: true,
+		Prune: xf.Prune()})
+	if got := walkRels(t, root, pWR.Items); e != nil || !reflect.DeepEqual(got,
+		[]string{".", ".git", "_build", "src", "src/_gen", "src/a.go", "src/a.go~"}) {
+		t.Errorf("prune: got %v, %v", got, e)
+	}
+}
+
+// TestWalkTreeSameDevice needs a mount point, so it uses /proc.
+func TestWalkTreeSameDevice(t *testing.T) {
+	var root = writeTestTree(t, "", testTreeFiles)
+	pWR, e := WalkTree(context.Background(), root,
+		&WalkOptions{Ordered: true, SameDevice: true})
+	if got := walkRels(t, root, pWR.Items); e != nil ||
+		!reflect.DeepEqual(got, walkDirRels(t, root)) {
+		t.Errorf("one device: got %v, %v", got, e)
+	}
+	rootFI, e1 := os.Stat("/")
+	procFI, e2 := os.Stat("/proc")
+	if e1 != nil || e2 != nil {
+		t.Skip("no /proc")
+	}
+	var rootSt, ok1 = statInfoOf(rootFI)
+	var procSt, ok2 = statInfoOf(procFI)
+	if !ok1 || !ok2 || rootSt.Dev == procSt.Dev {
+		t.Skip("/proc is not a mount point")
+	}
+	var opts = WalkOptions{MaxDepth: 2,
+		Prune:   func(rel string, d fs.DirEntry) bool { return rel != "proc" },
+		Include: func(rel string, d fs.DirEntry) bool { return rel == "proc" || FP.Dir(rel) == "proc" }}
+	for _, same := range []bool{false, true} {
+		opts.SameDevice = same
+		pWR, e := WalkTree(context.Background(), "/", &opts)
+		if e != nil {
+			t.Fatal(e)
+		}
+		// "/" and "/proc", plus what is in /proc if it is walked.
+		if n := len(pWR.Items); (n > 2) == same {
+			t.Errorf("SameDevice %v: %d items", same, n)
+		}
+	}
+}
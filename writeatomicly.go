@@ -23,9 +23,27 @@ func TempDir(dest string) string {
 	return tempdir
 }
 
-// WriteAtomic is TBS.
+// WriteAtomic writes to a temp file (see [TempDir]) and then
+// renames it to dest, so that dest is never seen half-written.
+// The file is made world-readable (0644).
 func WriteAtomic(dest string, write func(w io.Writer) error) (err error) {
-	f, err := os.CreateTemp(TempDir(dest), "atomic-")
+	return writeAtomicIn(TempDir(dest), dest, 0644, write)
+}
+
+// WriteAtomicMode is [WriteAtomic] with the permissions to
+// give the file, for example to preserve those of a file
+// that is being overwritten. The temp file is always made in
+// the directory of dest (ignoring TMPDIR), so that the rename
+// cannot fail for being across file systems.
+func WriteAtomicMode(dest string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	return writeAtomicIn(filepath.Dir(dest), dest, perm, write)
+}
+
+// writeAtomicIn makes the temp file in dir. The temp file
+// is fsync'd before the rename, so that after a crash dest
+// has either the old content or the new, and not nothing.
+func writeAtomicIn(dir, dest string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	f, err := os.CreateTemp(dir, "atomic-")
 	if err != nil {
 		return err
 	}
@@ -49,9 +67,12 @@ func WriteAtomic(dest string, write func(w io.Writer) error) (err error) {
 	if err := bufw.Flush(); err != nil {
 		return err
 	}
-	// Chmod the file world-readable (TempFile
-	// creates files with mode 0600) before renaming.
-	if err := f.Chmod(0644); err != nil {
+	// Chmod the file (CreateTemp creates
+	// files with mode 0600) before renaming.
+	if err := f.Chmod(perm); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
 		return err
 	}
 	if err := f.Close(); err != nil {
@@ -0,0 +1,168 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrXattrUnsupported is wrapped by the extended attribute functions
+// when the OS or the file system does not support extended attributes
+// (or the namespace of the attribute, such as "user." on a symlink).
+var ErrXattrUnsupported = errors.New("extended attributes not supported")
+
+// ErrNoXattr is wrapped by [GetXattr] and [RemoveXattr]
+// when the object does not have the named attribute.
+var ErrNoXattr = errors.New("no such extended attribute")
+
+// The extended attribute functions do not follow symlinks, so that
+// they agree with [NewFSObject], which uses [os.Lstat]. On Linux, a
+// name needs a namespace prefix: use "user." for application data,
+// e.g. "user.dita.srchash". Errors are [fs.PathError]s, and the
+// underlying [syscall.Errno] can also be tested with [errors.Is].
+
+// ListXattrs returns the names of the extended attributes of
+// the object at the path. No attributes is not an error.
+func ListXattrs(path string) ([]string, error) {
+	ss, e := listXattrs(path)
+	if e != nil {
+		return nil, &fs.PathError{Op: "listxattr", Path: path, Err: e}
+	}
+	return ss, nil
+}
+
+// GetXattr returns the value of the named extended attribute.
+func GetXattr(path, name string) ([]byte, error) {
+	bb, e := getXattr(path, name)
+	if e != nil {
+		return nil, &fs.PathError{Op: "getxattr:" + name, Path: path, Err: e}
+	}
+	return bb, nil
+}
+
+// SetXattr creates or replaces the named extended attribute.
+func SetXattr(path, name string, value []byte) error {
+	if e := setXattr(path, name, value); e != nil {
+		return &fs.PathError{Op: "setxattr:" + name, Path: path, Err: e}
+	}
+	return nil
+}
+
+// RemoveXattr removes the named extended attribute.
+func RemoveXattr(path, name string) error {
+	if e := removeXattr(path, name); e != nil {
+		return &fs.PathError{Op: "removexattr:" + name, Path: path, Err: e}
+	}
+	return nil
+}
+
+// CopyXattrs copies the extended attributes of src to dst that a
+// normal user can set: on Linux, those in the "user." namespace
+// (the "security.", "trusted." and "system." ones need privileges,
+// and often are set by the OS anyway), and on macOS, all of them.
+// It is [CopyXattrsMatching] with that test.
+func CopyXattrs(src, dst string) error {
+	return CopyXattrsMatching(src, dst, isUserXattr)
+}
+
+// CopyXattrsMatching copies the extended attributes of src to dst
+// whose names match (or all of them, if match is nil). If src has
+// none, or its file system does not support them, there is nothing
+// to copy and it is not an error. An attribute that dst refuses as
+// not permitted or not supported is skipped, and the copy goes on;
+// these are then returned together (see [errors.Join]). Any other
+// error stops the copy.
+// .
+func CopyXattrsMatching(src, dst string, match func(name string) bool) error {
+	names, e := ListXattrs(src)
+	if e != nil {
+		if errors.Is(e, ErrXattrUnsupported) {
+			return nil
+		}
+		return e
+	}
+	var skipped []error
+	for _, nm := range names {
+		if match != nil && !match(nm) {
+			continue
+		}
+		val, e := GetXattr(src, nm)
+		if e != nil {
+			// Removed in the meantime ?
+			if errors.Is(e, ErrNoXattr) {
+				continue
+			}
+			return e
+		}
+		if e = SetXattr(dst, nm, val); e != nil {
+			if errors.Is(e, ErrXattrUnsupported) || isXattrDenied(e) {
+				skipped = append(skipped, e)
+				continue
+			}
+			return e
+		}
+	}
+	return errors.Join(skipped...)
+}
+
+// xattrError maps an errno from the xattr syscalls
+// to our errors (while preserving the errno).
+func xattrError(e error) error {
+	switch {
+	case e == nil:
+		return nil
+	case isXattrUnsupported(e):
+		return fmt.Errorf("%w: %w", ErrXattrUnsupported, e)
+	case isNoXattr(e):
+		return fmt.Errorf("%w: %w", ErrNoXattr, e)
+	}
+	return e
+}
+
+// xattrPath is the host path of the object,
+// for the extended attribute methods.
+func (p *FSObject) xattrPath(op string) (string, error) {
+	if p.inMemory || p.fsys != nil || p.FPs.AbsFP == "" {
+		return "", &fs.PathError{Op: op,
+			Path: p.FPs.ShortFP, Err: ErrNoHostPath}
+	}
+	return trimPathSepSuffix(p.FPs.AbsFP), nil
+}
+
+// Xattrs is [ListXattrs] for the FSObject, which must be
+// on the host file system (not in memory or in an [fs.FS]).
+func (p *FSObject) Xattrs() ([]string, error) {
+	path, e := p.xattrPath("listxattr")
+	if e != nil {
+		return nil, e
+	}
+	return ListXattrs(path)
+}
+
+// Xattr is [GetXattr] for the FSObject.
+func (p *FSObject) Xattr(name string) ([]byte, error) {
+	path, e := p.xattrPath("getxattr:" + name)
+	if e != nil {
+		return nil, e
+	}
+	return GetXattr(path, name)
+}
+
+// SetXattr is [SetXattr] for the FSObject. Note that
+// it changes the ctime (see [FSObject.Refresh]).
+func (p *FSObject) SetXattr(name string, value []byte) error {
+	path, e := p.xattrPath("setxattr:" + name)
+	if e != nil {
+		return e
+	}
+	return SetXattr(path, name, value)
+}
+
+// RemoveXattr is [RemoveXattr] for the FSObject.
+func (p *FSObject) RemoveXattr(name string) error {
+	path, e := p.xattrPath("removexattr:" + name)
+	if e != nil {
+		return e
+	}
+	return RemoveXattr(path, name)
+}
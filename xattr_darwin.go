@@ -0,0 +1,14 @@
+package fileutils
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+func isNoXattr(e error) bool {
+	return errors.Is(e, unix.ENOATTR)
+}
+
+// isUserXattr is always true, since macOS has no namespaces.
+func isUserXattr(name string) bool { return true }
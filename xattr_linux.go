@@ -0,0 +1,16 @@
+package fileutils
+
+import (
+	"errors"
+	S "strings"
+
+	"golang.org/x/sys/unix"
+)
+
+func isNoXattr(e error) bool {
+	return errors.Is(e, unix.ENODATA)
+}
+
+func isUserXattr(name string) bool {
+	return S.HasPrefix(name, "user.")
+}
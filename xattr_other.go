@@ -0,0 +1,27 @@
+//go:build !(linux || darwin)
+
+package fileutils
+
+func listXattrs(path string) ([]string, error) {
+	return nil, ErrXattrUnsupported
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, ErrXattrUnsupported
+}
+
+func setXattr(path, name string, value []byte) error {
+	return ErrXattrUnsupported
+}
+
+func removeXattr(path, name string) error {
+	return ErrXattrUnsupported
+}
+
+func isXattrUnsupported(e error) bool { return false }
+
+func isNoXattr(e error) bool { return false }
+
+func isXattrDenied(e error) bool { return false }
+
+func isUserXattr(name string) bool { return true }
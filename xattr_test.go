@@ -0,0 +1,154 @@
+//go:build linux || darwin
+
+package fileutils
+
+import (
+	"errors"
+	"os"
+	FP "path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+)
+
+// skipWithoutXattrs skips the test if the file's
+// file system has no extended attributes.
+func skipWithoutXattrs(t *testing.T, path string) {
+	t.Helper()
+	if e := SetXattr(path, "user.probe", nil); errors.Is(e, ErrXattrUnsupported) {
+		t.Skip("no extended attributes on ", FP.Dir(path))
+	}
+	RemoveXattr(path, "user.probe")
+}
+
+func TestXattrs(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "x")
+	skipWithoutXattrs(t, path)
+	if ss, e := ListXattrs(path); e != nil || len(ss) != 0 {
+		t.Errorf("none: got %v, %v", ss, e)
+	}
+	if e := SetXattr(path, "user.dita.srchash", []byte("abc")); e != nil {
+		t.Fatal(e)
+	}
+	if e := SetXattr(path, "user.empty", []byte{}); e != nil {
+		t.Fatal(e)
+	}
+	ss, e := ListXattrs(path)
+	slices.Sort(ss)
+	if e != nil || !slices.Equal(ss, []string{"user.dita.srchash", "user.empty"}) {
+		t.Errorf("list: got %v, %v", ss, e)
+	}
+	if bb, e := GetXattr(path, "user.dita.srchash"); e != nil || string(bb) != "abc" {
+		t.Errorf("get: got %q, %v", bb, e)
+	}
+	if bb, e := GetXattr(path, "user.empty"); e != nil || len(bb) != 0 {
+		t.Errorf("get empty: got %q, %v", bb, e)
+	}
+	if e := RemoveXattr(path, "user.empty"); e != nil {
+		t.Error(e)
+	}
+	if _, e := GetXattr(path, "user.empty"); !errors.Is(e, ErrNoXattr) {
+		t.Errorf("removed: got %v, want ErrNoXattr", e)
+	}
+	if e := RemoveXattr(path, "user.empty"); !errors.Is(e, ErrNoXattr) {
+		t.Errorf("remove again: got %v, want ErrNoXattr", e)
+	}
+}
+
+func TestFSObjectXattrs(t *testing.T) {
+	var path = writeTestFile(t, t.TempDir(), "f.txt", "x")
+	skipWithoutXattrs(t, path)
+	var p = NewFSObject(path)
+	if e := p.SetXattr("user.k", []byte("v")); e != nil {
+		t.Fatal(e)
+	}
+	if bb, e := p.Xattr("user.k"); e != nil || string(bb) != "v" {
+		t.Errorf("got %q, %v", bb, e)
+	}
+	if ss, e := p.Xattrs(); e != nil || len(ss) != 1 {
+		t.Errorf("got %v, %v", ss, e)
+	}
+	if e := p.RemoveXattr("user.k"); e != nil {
+		t.Error(e)
+	}
+	var pMem = NewFSObjectFromContent("x", "")
+	if _, e := pMem.Xattrs(); !errors.Is(e, ErrNoHostPath) {
+		t.Errorf("in memory: got %v, want ErrNoHostPath", e)
+	}
+}
+
+func TestCopyXattrs(t *testing.T) {
+	var dir = t.TempDir()
+	var src = writeTestFile(t, dir, "src", "x")
+	skipWithoutXattrs(t, src)
+	var dst = writeTestFile(t, dir, "dst", "")
+	SetXattr(src, "user.a", []byte("1"))
+	SetXattr(src, "user.b", []byte("2"))
+	if e := CopyXattrs(src, dst); e != nil {
+		t.Fatal(e)
+	}
+	ss, _ := ListXattrs(dst)
+	slices.Sort(ss)
+	if !slices.Equal(ss, []string{"user.a", "user.b"}) {
+		t.Errorf("got %v", ss)
+	}
+	var dst2 = writeTestFile(t, dir, "dst2", "")
+	if e := CopyXattrsMatching(src, dst2, func(nm string) bool {
+		return nm == "user.b"
+	}); e != nil {
+		t.Fatal(e)
+	}
+	if ss, _ = ListXattrs(dst2); !slices.Equal(ss, []string{"user.b"}) {
+		t.Errorf("matching: got %v", ss)
+	}
+	if e := CopyXattrs(dst2+"-not", dst2); e == nil {
+		t.Error("missing src: no error")
+	}
+	// Nothing to copy is not an error.
+	if e := CopyXattrs(writeTestFile(t, dir, "bare", ""), dst2); e != nil {
+		t.Error(e)
+	}
+}
+
+// TestCopyXattrsSkipsRefused uses a symlink as the destination,
+// because Linux refuses "user." attributes on a symlink (EPERM).
+func TestCopyXattrsSkipsRefused(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("needs Linux")
+	}
+	var dir = t.TempDir()
+	var src = writeTestFile(t, dir, "src", "x")
+	skipWithoutXattrs(t, src)
+	SetXattr(src, "user.a", []byte("1"))
+	SetXattr(src, "user.b", []byte("2"))
+	var dst = FP.Join(dir, "link")
+	if e := os.Symlink("src", dst); e != nil {
+		t.Fatal(e)
+	}
+	var e = CopyXattrs(src, dst)
+	if e == nil {
+		t.Fatal("no error")
+	}
+	// Both were tried, and both are reported.
+	var joined interface{ Unwrap() []error }
+	if !errors.As(e, &joined) || len(joined.Unwrap()) != 2 {
+		t.Errorf("got %v", e)
+	}
+	if !isXattrDenied(e) && !errors.Is(e, ErrXattrUnsupported) {
+		t.Errorf("not a refusal: %v", e)
+	}
+}
+
+func TestCopyFileFromToWithXattrs(t *testing.T) {
+	var dir = t.TempDir()
+	var src = writeTestFile(t, dir, "src", "x")
+	skipWithoutXattrs(t, src)
+	SetXattr(src, "user.a", []byte("1"))
+	var dst = FP.Join(dir, "dst")
+	if e := CopyFileFromTo(src, dst, CopyWithXattrs); e != nil {
+		t.Fatal(e)
+	}
+	if bb, e := GetXattr(dst, "user.a"); e != nil || string(bb) != "1" {
+		t.Errorf("got %q, %v", bb, e)
+	}
+}
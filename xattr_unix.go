@@ -0,0 +1,78 @@
+//go:build linux || darwin
+
+package fileutils
+
+import (
+	"bytes"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+func listXattrs(path string) ([]string, error) {
+	var bb []byte
+	for {
+		// Get the size first, and retry if it grew.
+		sz, e := unix.Llistxattr(path, nil)
+		if e != nil {
+			return nil, xattrError(e)
+		}
+		if sz == 0 {
+			return nil, nil
+		}
+		bb = make([]byte, sz)
+		sz, e = unix.Llistxattr(path, bb)
+		if errors.Is(e, unix.ERANGE) {
+			continue
+		}
+		if e != nil {
+			return nil, xattrError(e)
+		}
+		bb = bb[:sz]
+		break
+	}
+	var ss []string
+	for _, b := range bytes.Split(bb, []byte{0}) {
+		if len(b) > 0 {
+			ss = append(ss, string(b))
+		}
+	}
+	return ss, nil
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		sz, e := unix.Lgetxattr(path, name, nil)
+		if e != nil {
+			return nil, xattrError(e)
+		}
+		var bb = make([]byte, sz)
+		if sz == 0 {
+			return bb, nil
+		}
+		sz, e = unix.Lgetxattr(path, name, bb)
+		if errors.Is(e, unix.ERANGE) {
+			continue
+		}
+		if e != nil {
+			return nil, xattrError(e)
+		}
+		return bb[:sz], nil
+	}
+}
+
+func setXattr(path, name string, value []byte) error {
+	return xattrError(unix.Lsetxattr(path, name, value, 0))
+}
+
+func removeXattr(path, name string) error {
+	return xattrError(unix.Lremovexattr(path, name))
+}
+
+func isXattrUnsupported(e error) bool {
+	return errors.Is(e, unix.ENOTSUP) || errors.Is(e, unix.EOPNOTSUPP)
+}
+
+func isXattrDenied(e error) bool {
+	return errors.Is(e, unix.EPERM) || errors.Is(e, unix.EACCES)
+}